@@ -9,6 +9,7 @@ import (
 var jsSamples = []string{
 	"sample_ace.js",
 	"sample_dot.js",
+	"sample_es2020.js",
 	"sample_jquery.js",
 	"sample_jqueryui.js",
 	"sample_moment.js",
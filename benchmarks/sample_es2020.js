@@ -0,0 +1,228 @@
+#!/usr/bin/env node
+/*! es2020 sample | MIT License */
+'use strict';
+
+const DEFAULT_OPTIONS = Object.freeze({
+    retries: 3,
+    timeout: 1_000,
+    maxSize: 0x7f_ff_ff_ff,
+    separator: /\s*[,;]\s*/,
+});
+
+// Emitter with private fields and static members
+class Emitter {
+    #listeners = new Map();
+    static instances = 0;
+
+    constructor(name = 'emitter') {
+        this.name = name;
+        Emitter.instances++;
+    }
+
+    get size() {
+        let n = 0;
+        for (const [, list] of this.#listeners) n += list.length;
+        return n;
+    }
+
+    on(type, fn, { once = false } = {}) {
+        const list = this.#listeners.get(type) ?? [];
+        list.push({ fn, once });
+        this.#listeners.set(type, list);
+        return () => this.off(type, fn);
+    }
+
+    off(type, fn) {
+        const list = this.#listeners.get(type);
+        if (!list) return false;
+        const i = list.findIndex(l => l.fn === fn);
+        if (i < 0) return false;
+        list.splice(i, 1);
+        return true;
+    }
+
+    emit(type, ...args) {
+        const list = this.#listeners.get(type)?.slice() ?? [];
+        for (const { fn, once } of list) {
+            if (once) this.off(type, fn);
+            fn.apply(this, args);
+        }
+        return list.length > 0;
+    }
+
+    static isEmitter(obj) {
+        return #listeners in obj;
+    }
+}
+
+class Store extends Emitter {
+    #state;
+
+    constructor(initial) {
+        super('store');
+        this.#state = { ...initial };
+    }
+
+    get state() { return this.#state }
+
+    set(path, value) {
+        const keys = path.split('.');
+        const last = keys.pop();
+        let obj = this.#state;
+        for (const key of keys) obj = obj[key] ??= {};
+        const prev = obj[last];
+        obj[last] = value;
+        if (prev !== value) this.emit('change', { path, prev, value });
+    }
+
+    get(path) {
+        return path.split('.').reduce((obj, key) => obj?.[key], this.#state);
+    }
+}
+
+// Template helpers
+const escape = s => String(s).replace(/[&<>"']/g, c => `&#${c.charCodeAt(0)};`);
+
+function html(strings, ...values) {
+    return strings.reduce((out, str, i) => out + str + (i < values.length ? escape(values[i]) : ''), '');
+}
+
+const render = ({ title, items = [] }) => html`<h1>${title}</h1>
+<ul>${items.map(item => `<li>${item}</li>`).join('')}</ul>`;
+
+// Generators and iteration
+function* range(start, end, step = 1) {
+    for (let i = start; i < end; i += step) yield i;
+}
+
+function* tokens(input) {
+    let match;
+    const re = /(\d+)|([a-z]+)|(\S)/gi;
+    while ((match = re.exec(input)) !== null) {
+        if (match[1]) yield { type: 'number', value: +match[1] };
+        else if (match[2]) yield { type: 'word', value: match[2] };
+        else yield /[()]/.test(match[3]) ? { type: 'paren', value: match[3] } : { type: 'op', value: match[3] };
+    }
+}
+
+function* split(text) {
+    yield /,/[Symbol.split](text);
+}
+
+// Async code
+const sleep = ms => new Promise(resolve => setTimeout(resolve, ms));
+
+async function retry(fn, { retries, timeout } = DEFAULT_OPTIONS) {
+    let lastError;
+    for (let attempt = 0; attempt <= retries; attempt++) {
+        try {
+            return await fn(attempt);
+        } catch (err) {
+            lastError = err;
+            await sleep(timeout / 1000 | 0);
+        }
+    }
+    throw lastError;
+}
+
+async function* lines(chunks) {
+    let buffer = '';
+    for await (const chunk of chunks) {
+        buffer += chunk;
+        let i;
+        while ((i = buffer.indexOf('\n')) >= 0) {
+            yield buffer.slice(0, i);
+            buffer = buffer.slice(i + 1);
+        }
+    }
+    if (buffer) yield buffer;
+}
+
+async function matches(text) {
+    return await /^[A-Z]/.test(text);
+}
+
+// Numbers
+const big = 2n ** 64n - 1n;
+const ratio = 3 / 4 / 2;
+const exp = 2 ** -2;
+const half = .5, whole = 5., sci = 1.5e-3, oct = 0o17, bin = 0b1010;
+
+function parseDuration(text) {
+    const units = { ms: 1, s: 1e3, m: 60e3, h: 3_600e3 };
+    let total = 0;
+    for (const [, n, unit] of text.matchAll(/(\d+(?:\.\d+)?)(ms|s|m|h)/g)) total += n * units[unit];
+    return total;
+}
+
+// Control flow with regular expressions after parentheses and braces
+function classify(value) {
+    if (typeof value === 'string') /^\d+$/.test(value) && (value = +value);
+    let kind;
+    switch (typeof value) {
+        case 'number': {
+            kind = Number.isInteger(value) ? 'int' : 'float';
+            break;
+        }
+        case 'string':
+            kind = 'text';
+            break;
+        default:
+            kind = value?.constructor?.name ?? 'null';
+    }
+    return kind;
+}
+
+const labels = [];
+outer: for (const a of range(0, 3)) {
+    for (const b of range(0, 3)) {
+        if (b > a) continue outer;
+        labels.push(`${a}${b}`);
+    }
+}
+
+let counter = 0
+counter++
+;[1, 2, 3].forEach(n => counter += n)
+const after = counter
+-1
+
+// Run everything and print the results
+const store = new Store({ user: { name: 'Ada' } });
+const changes = [];
+const unsubscribe = store.on('change', ({ path, value }) => changes.push(`${path}=${value}`));
+store.set('user.name', 'Grace');
+store.set('settings.theme', 'dark');
+unsubscribe();
+store.set('user.name', 'Linus');
+
+const results = {
+    size: store.size,
+    changes,
+    name: store.get('user.name'),
+    missing: store.get('a.b.c') ?? 'none',
+    isEmitter: Emitter.isEmitter(store) && !Emitter.isEmitter({}),
+    instances: Emitter.instances,
+    html: render({ title: 'Tom & Jerry', items: ['<a>', "it's"] }),
+    range: [...range(0, 10, 3)],
+    tokens: [...tokens('12 * (x + 3)')].map(t => t.value).join(' '),
+    split: [...split('a,b,c')],
+    big: big.toString(16),
+    numbers: [ratio, exp, half, whole, sci, oct, bin, DEFAULT_OPTIONS.maxSize, 1..toFixed(1), 2 .toString()],
+    duration: parseDuration('1h30m15s'),
+    classify: ['42', '4.2', 3, null, []].map(classify),
+    labels,
+    counter,
+    after,
+};
+
+(async () => {
+    results.retry = await retry(async attempt => {
+        if (attempt < 2) throw new Error(`attempt ${attempt}`);
+        return attempt;
+    }, { retries: 3, timeout: 0 });
+    results.lines = [];
+    for await (const line of lines(['a\nb', 'c\n', 'd'])) results.lines.push(line);
+    results.matches = await matches('Hello');
+    console.log(JSON.stringify(results, null, 2));
+})();
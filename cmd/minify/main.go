@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	encjson "encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,6 +17,7 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	humanize "github.com/dustin/go-humanize"
@@ -27,6 +30,7 @@ import (
 	"github.com/tdewolff/minify/v2/json"
 	"github.com/tdewolff/minify/v2/svg"
 	"github.com/tdewolff/minify/v2/xml"
+	"github.com/tdewolff/parse/v2"
 )
 
 var Version = "master"
@@ -48,8 +52,10 @@ var (
 	hidden    bool
 	list      bool
 	m         *min.M
+	bundler   *js.Bundler
 	pattern   *regexp.Regexp
 	recursive bool
+	sourceMap bool
 	verbose   bool
 	version   bool
 	watch     bool
@@ -72,6 +78,17 @@ func main() {
 	filetype := ""
 	match := ""
 	siteurl := ""
+	jsDefines := []string{}
+	jsMangleProps := ""
+	jsNameCache := ""
+	bundleFormat := ""
+	bundleName := ""
+	comments := ""
+	extractLicenses := ""
+	nameMap := ""
+	cssPurgeFrom := []string{}
+	cssPurgeSafelist := ""
+	cssTargets := ""
 
 	cssMinifier := &css.Minifier{}
 	htmlMinifier := &html.Minifier{}
@@ -82,9 +99,10 @@ func main() {
 
 	flag := flag.NewFlagSet("minify", flag.ContinueOnError)
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options] [input]\n\nOptions:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] [input]\n       %s bundle [options] entry\n\nOptions:\n", os.Args[0], os.Args[0])
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nInput:\n  Files or directories, leave blank to use stdin\n")
+		fmt.Fprintf(os.Stderr, "\nBundle:\n  Bundle the ES module entry with the modules it imports by relative paths into a single script and minify it\n")
 	}
 
 	flag.BoolVarP(&help, "help", "h", false, "Show usage")
@@ -97,18 +115,51 @@ func main() {
 	flag.BoolVarP(&list, "list", "l", false, "List all accepted filetypes")
 	flag.BoolVarP(&verbose, "verbose", "v", false, "Verbose")
 	flag.BoolVarP(&watch, "watch", "w", false, "Watch files and minify upon changes")
+	flag.BoolVar(&sourceMap, "source-map", false, "Write a source map for CSS and JS files next to the output file with the .map extension")
 	flag.BoolVarP(&version, "version", "", false, "Version")
 
 	flag.StringVar(&siteurl, "url", "", "URL of file to enable URL minification")
+	flag.StringVar(&bundleFormat, "bundle-format", "iife", "Output format of bundle, iife or esm")
+	flag.StringVar(&bundleName, "bundle-name", "", "Global variable that receives the exports of the entry module for the iife bundle format")
+	flag.StringVar(&comments, "comments", "", "Comments to keep in CSS, HTML, JS and SVG: none, all, license, or a regular expression, by default only /*! comments in CSS and JS")
+	flag.StringVar(&extractLicenses, "extract-licenses", "", "File (eg. LICENSES.txt) to write the kept comments to instead of the output, keeps license comments by default")
+	flag.StringVar(&nameMap, "name-map", "", "JSON file to read and store the short names of class names and ids, renames them consistently in CSS, HTML and SVG")
 	flag.IntVar(&cssMinifier.Decimals, "css-decimals", -1, "Number of decimals to preserve in numbers, -1 is all")
+	flag.BoolVar(&cssMinifier.MergeRules, "css-merge-rules", false, "Merge rulesets with identical selectors or declarations")
+	flag.BoolVar(&cssMinifier.FoldShorthands, "css-fold-shorthands", false, "Collapse longhands declared together into their shorthand")
+	flag.StringSliceVar(&cssPurgeFrom, "css-purge-from", nil, "Comma-separated list of HTML and JS files, rulesets whose selectors cannot match any of their elements are removed")
+	flag.StringVar(&cssPurgeSafelist, "css-purge-safelist", "", "Regular expression matching tag names, class names and ids that are never purged")
+	flag.BoolVar(&cssMinifier.RemoveOverridden, "css-remove-overridden", false, "Remove declarations overridden by a later declaration in the same block")
+	flag.BoolVar(&cssMinifier.InlineImports, "css-inline-imports", false, "Inline @import of local files and rebase their relative URLs")
+	flag.StringVar(&cssMinifier.BaseDir, "css-base-dir", "", "Directory of imports starting with a slash, or when reading from stdin")
+	flag.StringVar(&cssTargets, "css-targets", "", "Browserslist query of the browsers to support (eg. 'defaults' or 'chrome >= 90, safari >= 14'), removes vendor prefixes they don't need")
+	flag.BoolVar(&cssMinifier.Nesting, "css-nesting", false, "Parse and keep nested rules, which are flattened when the CSS targets don't support nesting")
+	flag.BoolVar(&cssMinifier.FlattenNesting, "css-flatten-nesting", false, "Flatten nested rules into rulesets with the equivalent selectors")
+	flag.BoolVar(&cssMinifier.RenameCustomProperties, "css-rename-vars", false, "Rename custom properties to short names consistently across files, requires --name-map")
+	flag.BoolVar(&cssMinifier.InlineCustomProperties, "css-inline-vars", false, "Inline custom properties declared once on :root and never overridden")
 	flag.BoolVar(&htmlMinifier.KeepConditionalComments, "html-keep-conditional-comments", false, "Preserve all IE conditional comments")
 	flag.BoolVar(&htmlMinifier.KeepDefaultAttrVals, "html-keep-default-attrvals", false, "Preserve default attribute values")
 	flag.BoolVar(&htmlMinifier.KeepDocumentTags, "html-keep-document-tags", false, "Preserve html, head and body tags")
 	flag.BoolVar(&htmlMinifier.KeepEndTags, "html-keep-end-tags", false, "Preserve all end tags")
 	flag.BoolVar(&htmlMinifier.KeepWhitespace, "html-keep-whitespace", false, "Preserve whitespace characters but still collapse multiple into one")
+	flag.BoolVar(&jsMinifier.DropDebugger, "js-drop-debugger", false, "Remove debugger statements")
+	flag.StringArrayVar(&jsDefines, "js-define", nil, "Replace a global identifier or member chain by an expression in the form KEY=VALUE, can be repeated")
+	flag.BoolVar(&jsMinifier.FoldConstants, "js-fold-constants", false, "Evaluate constant expressions and write booleans as !0 and !1")
+	flag.BoolVar(&jsMinifier.MangleNames, "js-mangle-names", false, "Rename local variables and function parameters to short names")
+	flag.StringVar(&jsMangleProps, "js-mangle-props", "", "Rename object properties matching the regular expression (eg. ^_) to short names")
+	flag.StringVar(&jsNameCache, "js-name-cache", "", "JSON file to read and store mangled property names, keeps names stable between builds")
+	flag.StringSliceVar(&jsMinifier.PureFuncs, "js-pure-funcs", nil, "Comma-separated list of functions (eg. console.log) whose calls are removed when their result is not used")
+	flag.BoolVar(&jsMinifier.RemoveDeadCode, "js-remove-dead-code", false, "Remove unreachable code and branches with constant conditions")
+	flag.BoolVar(&jsMinifier.RenameSelectors, "js-rename-selectors", false, "Rename class names and ids in strings passed to querySelector, getElementById and classList methods, requires --name-map")
+	flag.StringToStringVar(&jsMinifier.TemplateTags, "js-template-tags", nil, "Comma-separated list of template tags and the filetype or mimetype of their contents in the form TAG=TYPE (eg. html=html,css=css)")
 	flag.IntVar(&svgMinifier.Decimals, "svg-decimals", -1, "Number of decimals to preserve in numbers, -1 is all")
 	flag.BoolVar(&xmlMinifier.KeepWhitespace, "xml-keep-whitespace", false, "Preserve whitespace characters but still collapse multiple into one")
-	if err := flag.Parse(os.Args[1:]); err != nil {
+	args := os.Args[1:]
+	if 0 < len(args) && args[0] == "bundle" {
+		bundler = &js.Bundler{}
+		args = args[1:]
+	}
+	if err := flag.Parse(args); err != nil {
 		fmt.Printf("Error: %v\n\n", err)
 		flag.Usage()
 		os.Exit(2)
@@ -149,6 +200,19 @@ func main() {
 	}
 
 	useStdin := len(rawInputs) == 0
+	if bundler != nil {
+		if useStdin {
+			Error.Fatalln("bundle doesn't work on stdin, specify the entry file")
+		} else if sourceMap {
+			Error.Fatalln("source maps don't work with bundle")
+		} else if bundleFormat != "iife" && bundleFormat != "esm" {
+			Error.Fatalln("bundle-format must be iife or esm:", bundleFormat)
+		}
+		bundler.Format = bundleFormat
+		bundler.Name = bundleName
+		mimetype = filetypeMime["js"]
+	}
+
 	mimetype = getMimetype(mimetype, filetype, useStdin)
 
 	var err error
@@ -159,6 +223,92 @@ func main() {
 		}
 	}
 
+	if 0 < len(jsDefines) {
+		jsMinifier.Define = map[string]string{}
+		for _, define := range jsDefines {
+			i := strings.IndexByte(define, '=')
+			if i == -1 {
+				Error.Fatalln("js-define must be in the form KEY=VALUE:", define)
+			}
+			jsMinifier.Define[define[:i]] = define[i+1:]
+		}
+	}
+
+	if jsMangleProps != "" {
+		jsMinifier.MangleProps, err = regexp.Compile(jsMangleProps)
+		if err != nil {
+			Error.Fatalln(err)
+		}
+	}
+
+	for tag, mediatype := range jsMinifier.TemplateTags {
+		if mimetype, ok := filetypeMime[mediatype]; ok {
+			jsMinifier.TemplateTags[tag] = mimetype
+		}
+	}
+
+	if jsNameCache != "" {
+		jsMinifier.NameCache = js.NewNameCache()
+		if b, err := ioutil.ReadFile(jsNameCache); err == nil {
+			if err := encjson.Unmarshal(b, jsMinifier.NameCache); err != nil {
+				Error.Fatalln("js-name-cache:", err)
+			}
+		} else if !os.IsNotExist(err) {
+			Error.Fatalln(err)
+		}
+	}
+
+	var names *min.Names
+	if nameMap != "" {
+		names = min.NewNames()
+		if b, err := ioutil.ReadFile(nameMap); err == nil {
+			if err := encjson.Unmarshal(b, names); err != nil {
+				Error.Fatalln("name-map:", err)
+			}
+		} else if !os.IsNotExist(err) {
+			Error.Fatalln(err)
+		}
+	}
+
+	var licenses *licenseList
+	if comments != "" || extractLicenses != "" {
+		if comments == "" {
+			comments = "license"
+		}
+		policy, err := min.NewComments(comments)
+		if err != nil {
+			Error.Fatalln("comments:", err)
+		}
+		if extractLicenses != "" {
+			licenses = &licenseList{seen: map[string]bool{}}
+			policy.Extract = licenses.add
+		}
+		cssMinifier.Comments = policy
+		htmlMinifier.Comments = policy
+		jsMinifier.Comments = policy
+		svgMinifier.Comments = policy
+	}
+
+	if 0 < len(cssPurgeFrom) {
+		cssMinifier.Purge = css.NewPurge()
+		if cssPurgeSafelist != "" {
+			if cssMinifier.Purge.Safelist, err = regexp.Compile(cssPurgeSafelist); err != nil {
+				Error.Fatalln("css-purge-safelist:", err)
+			}
+		}
+		for _, filename := range cssPurgeFrom {
+			if err := addPurge(cssMinifier.Purge, filename); err != nil {
+				Error.Fatalln("css-purge-from:", err)
+			}
+		}
+	}
+
+	if cssTargets != "" {
+		if cssMinifier.Targets, err = css.ParseTargets(cssTargets); err != nil {
+			Error.Fatalln("css-targets:", err)
+		}
+	}
+
 	if watch && (useStdin || output == "") {
 		Error.Fatalln("watch doesn't work on stdin and stdout, specify input and output")
 	}
@@ -167,6 +317,10 @@ func main() {
 		Error.Fatalln("recursive minification doesn't work on stdin and stdout, specify input and output")
 	}
 
+	if sourceMap && output == "" {
+		Error.Fatalln("source maps don't work on stdout, specify output")
+	}
+
 	////////////////
 
 	dirDst := false
@@ -185,6 +339,10 @@ func main() {
 		os.Exit(1)
 	}
 
+	if bundler != nil && len(tasks) == 1 && len(tasks[0].srcs) > 1 {
+		Error.Fatalln("bundling multiple entries requires an output directory")
+	}
+
 	if ok = expandOutputs(output, &tasks); !ok {
 		os.Exit(1)
 	}
@@ -194,6 +352,7 @@ func main() {
 	}
 
 	m = min.New()
+	m.Names = names
 	m.Add("text/css", cssMinifier)
 	m.Add("text/html", htmlMinifier)
 	m.Add("image/svg+xml", svgMinifier)
@@ -286,6 +445,35 @@ func main() {
 		fails += <-chanFails
 	}
 
+	if jsNameCache != "" {
+		b, err := encjson.Marshal(jsMinifier.NameCache)
+		if err == nil {
+			err = ioutil.WriteFile(jsNameCache, b, 0666)
+		}
+		if err != nil {
+			Error.Println(err)
+			fails++
+		}
+	}
+
+	if names != nil {
+		b, err := encjson.Marshal(names)
+		if err == nil {
+			err = ioutil.WriteFile(nameMap, b, 0666)
+		}
+		if err != nil {
+			Error.Println(err)
+			fails++
+		}
+	}
+
+	if licenses != nil {
+		if err := licenses.writeFile(extractLicenses); err != nil {
+			Error.Println(err)
+			fails++
+		}
+	}
+
 	if verbose {
 		Info.Println(time.Since(start), "total")
 	}
@@ -295,6 +483,52 @@ func main() {
 	os.Exit(0)
 }
 
+// licenseList collects the distinct comments extracted from all files, it is safe for concurrent use.
+type licenseList struct {
+	mu   sync.Mutex
+	seen map[string]bool
+	list []string
+}
+
+func (l *licenseList) add(comment []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s := string(comment); !l.seen[s] {
+		l.seen[s] = true
+		l.list = append(l.list, s)
+	}
+}
+
+// writeFile writes the comments in sorted order so that the file doesn't depend on the order in which files are minified.
+func (l *licenseList) writeFile(filename string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sort.Strings(l.list)
+	b := []byte{}
+	for _, comment := range l.list {
+		b = append(b, comment...)
+		b = append(b, '\n', '\n')
+	}
+	if 0 < len(b) {
+		b = b[:len(b)-1]
+	}
+	return ioutil.WriteFile(filename, b, 0666)
+}
+
+// addPurge adds the names used by an HTML or JS file, depending on its extension.
+func addPurge(purge *css.Purge, filename string) error {
+	r, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if ext := filepath.Ext(filename); ext == ".js" || ext == ".mjs" {
+		return purge.AddJS(r)
+	}
+	return purge.AddHTML(r)
+}
+
 func minifyWorker(mimetype string, chanTasks <-chan Task, chanFails chan<- int) {
 	fails := 0
 	for task := range chanTasks {
@@ -504,6 +738,15 @@ func openInputFile(input string) (io.ReadCloser, error) {
 	return r, nil
 }
 
+// openBundle bundles the entry module with the modules it imports.
+func openBundle(entry string) (io.ReadCloser, error) {
+	buf := &bytes.Buffer{}
+	if err := bundler.Bundle(buf, entry); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(buf), nil
+}
+
 func openOutputFile(output string) (*os.File, error) {
 	var w *os.File
 	if output == "" {
@@ -524,6 +767,28 @@ func openOutputFile(output string) (*os.File, error) {
 	return w, nil
 }
 
+// sourcePath returns the path of the source file relative to the directory of the output file, which is where the source map is written.
+func sourcePath(dst, src string) string {
+	if src == "" {
+		return "stdin"
+	} else if rel, err := filepath.Rel(filepath.Dir(dst), src); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(src)
+}
+
+func writeSourceMap(filename string, sm *min.SourceMap) error {
+	w, err := openOutputFile(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := sm.WriteTo(w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
 func minify(mimetype string, t Task) bool {
 	if mimetype == "" {
 		for _, src := range t.srcs {
@@ -550,6 +815,7 @@ func minify(mimetype string, t Task) bool {
 	if srcName == "" {
 		srcName = "stdin"
 	}
+	sources := append([]string{}, t.srcs...)
 	dstName := t.dst
 	if dstName == "" {
 		dstName = "stdin"
@@ -571,7 +837,11 @@ func minify(mimetype string, t Task) bool {
 		}
 	}
 
-	fr, err := NewConcatFileReader(t.srcs, openInputFile)
+	opener := openInputFile
+	if bundler != nil {
+		opener = openBundle
+	}
+	fr, err := NewConcatFileReader(t.srcs, opener)
 	if err != nil {
 		Error.Println(err)
 		return false
@@ -594,9 +864,50 @@ func minify(mimetype string, t Task) bool {
 		w = NewCountingWriter(bufio.NewWriter(fw))
 	}
 
+	// the filenames let minifiers resolve relative paths, such as those of CSS imports, and rebase them to the output
+	mime, params := parse.Mediatype([]byte(mimetype))
+	if params == nil {
+		params = map[string]string{}
+	}
+	if len(sources) == 1 {
+		params["filename"] = sources[0]
+	}
+	if t.dst != "" {
+		params["output"] = t.dst
+	}
+
 	success := true
 	startTime := time.Now()
-	if err = m.Minify(mimetype, w, r); err != nil {
+	if mimetype == filetypeMime["css"] && len(sources) > 1 && t.dst != "" {
+		// minify stylesheets one at a time so that their URLs are rebased from their own directory
+		var sm *min.SourceMap
+		if sourceMap {
+			sm = min.NewSourceMap(path.Base(t.dst))
+		}
+		if r.N, err = minifyFiles(m, mime, w, t.srcs, sources, opener, params, sm, path.Base(t.dst)+".map"); err != nil {
+			Error.Println("cannot minify "+srcName+":", err)
+			success = false
+		} else if sm != nil {
+			if err = writeSourceMap(t.dst+".map", sm); err != nil {
+				Error.Println("cannot write source map for "+dstName+":", err)
+				success = false
+			}
+		}
+	} else if sourceMap && t.dst != "" && (mimetype == filetypeMime["css"] || mimetype == filetypeMime["js"]) {
+		sm := min.NewSourceMap(path.Base(t.dst))
+		if err = m.MinifyMimetypeWithSourceMap(mime, w, r, params, sm, path.Base(t.dst)+".map"); err != nil {
+			Error.Println("cannot minify "+srcName+":", err)
+			success = false
+		} else {
+			for i, offset := range fr.Offsets() {
+				sm.AddSource(sourcePath(t.dst, sources[i]), offset)
+			}
+			if err = writeSourceMap(t.dst+".map", sm); err != nil {
+				Error.Println("cannot write source map for "+dstName+":", err)
+				success = false
+			}
+		}
+	} else if err = m.MinifyMimetype(mime, w, r, params); err != nil {
 		Error.Println("cannot minify "+srcName+":", err)
 		success = false
 	}
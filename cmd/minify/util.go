@@ -2,6 +2,8 @@ package main
 
 import (
 	"io"
+
+	min "github.com/tdewolff/minify/v2"
 )
 
 type countingReader struct {
@@ -51,6 +53,8 @@ type concatFileReader struct {
 
 	cur     io.ReadCloser
 	sepLeft int
+	n       int
+	offsets []int
 }
 
 // NewConcatFileReader reads from a list of filenames, and lazily loads files as it needs it.
@@ -58,6 +62,7 @@ type concatFileReader struct {
 // You must call Close to close the last file in the list.
 func NewConcatFileReader(filenames []string, opener func(string) (io.ReadCloser, error)) (*concatFileReader, error) {
 	var cur io.ReadCloser
+	var offsets []int
 	if len(filenames) > 0 {
 		var filename string
 		filename, filenames = filenames[0], filenames[1:]
@@ -66,16 +71,22 @@ func NewConcatFileReader(filenames []string, opener func(string) (io.ReadCloser,
 		if cur, err = opener(filename); err != nil {
 			return nil, err
 		}
+		offsets = append(offsets, 0)
 	} else {
 		cur = eofReader{}
 	}
-	return &concatFileReader{filenames, opener, nil, cur, 0}, nil
+	return &concatFileReader{filenames, opener, nil, cur, 0, 0, offsets}, nil
 }
 
 func (r *concatFileReader) SetSeparator(sep []byte) {
 	r.sep = sep
 }
 
+// Offsets returns the positions in the concatenation at which the files that have been opened so far start.
+func (r *concatFileReader) Offsets() []int {
+	return r.offsets
+}
+
 func (r *concatFileReader) Read(p []byte) (int, error) {
 	m := r.writeSep(p)
 	n, err := r.cur.Read(p[m:])
@@ -93,6 +104,7 @@ func (r *concatFileReader) Read(p []byte) (int, error) {
 			return n, err
 		}
 		r.sepLeft = len(r.sep)
+		r.offsets = append(r.offsets, r.n+n+len(r.sep))
 
 		// if previous read returned (0, io.EOF), read from the new reader
 		if n == 0 {
@@ -101,6 +113,7 @@ func (r *concatFileReader) Read(p []byte) (int, error) {
 			n += r.writeSep(p[n:])
 		}
 	}
+	r.n += n
 	return n, err
 }
 
@@ -116,3 +129,34 @@ func (r *concatFileReader) writeSep(p []byte) int {
 func (r *concatFileReader) Close() error {
 	return r.cur.Close()
 }
+
+// minifyFiles minifies the files one at a time into w, with the filename parameter set to the corresponding name so that the relative
+// URLs of stylesheets are rebased from their own directories to the output parameter. If sm isn't nil the source map is recorded, with
+// the names added as sources, and url is appended as a comment pointing to it. It returns the number of bytes read.
+func minifyFiles(m *min.M, mimetype []byte, w io.Writer, filenames, names []string, opener func(string) (io.ReadCloser, error), params map[string]string, sm *min.SourceMap, url string) (int, error) {
+	n := 0
+	for i, filename := range filenames {
+		r, err := opener(filename)
+		if err != nil {
+			return n, err
+		}
+		cr := NewCountingReader(r)
+		params["filename"] = names[i]
+		if sm != nil {
+			sm.AddSource(sourcePath(params["output"], names[i]), n)
+			fileURL := ""
+			if i == len(filenames)-1 {
+				fileURL = url
+			}
+			err = m.MinifyMimetypeWithSourceMap(mimetype, w, cr, params, sm, fileURL)
+		} else {
+			err = m.MinifyMimetype(mimetype, w, cr, params)
+		}
+		r.Close()
+		n += cr.N
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"testing"
 
+	min "github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
 	"github.com/tdewolff/test"
 )
 
@@ -66,6 +68,19 @@ func TestConcatSep(t *testing.T) {
 	test.Bytes(t, buf[:4+n], []byte("test_test"))
 }
 
+func TestConcatOffsets(t *testing.T) {
+	r, err := NewConcatFileReader([]string{"test", "test", "test"}, testOpener)
+	test.T(t, err, nil)
+	r.SetSeparator([]byte("_"))
+
+	buf, err := ioutil.ReadAll(r)
+	test.T(t, err, nil)
+	test.Bytes(t, buf, []byte("test_test_test"))
+	test.T(t, len(r.Offsets()), 3)
+	test.T(t, r.Offsets()[1], 5)
+	test.T(t, r.Offsets()[2], 10)
+}
+
 func TestConcatSepShort1(t *testing.T) {
 	r, err := NewConcatFileReader([]string{"test", "test"}, testOpener)
 	test.T(t, err, nil)
@@ -150,3 +165,36 @@ func TestConcatSepEmpty(t *testing.T) {
 	test.T(t, err, io.EOF)
 	test.Bytes(t, buf, []byte("_"))
 }
+
+func TestMinifyFiles(t *testing.T) {
+	files := map[string]string{
+		"x/a.css": "a { background: url(a.png) }",
+		"y/b.css": "b { background: url(b.png) }",
+	}
+	opener := func(filename string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewBufferString(files[filename])), nil
+	}
+
+	m := min.New()
+	m.AddFunc("text/css", css.Minify)
+	for _, withSourceMap := range []bool{false, true} {
+		var sm *min.SourceMap
+		if withSourceMap {
+			sm = min.NewSourceMap("all.css")
+		}
+		w := &bytes.Buffer{}
+		params := map[string]string{"output": "out/all.css"}
+		n, err := minifyFiles(m, []byte("text/css"), w, []string{"x/a.css", "y/b.css"}, []string{"x/a.css", "y/b.css"}, opener, params, sm, "all.css.map")
+		test.T(t, err, nil)
+		test.T(t, n, 56)
+		if !withSourceMap {
+			test.String(t, w.String(), "a{background:url(../x/a.png)}b{background:url(../y/b.png)}")
+			continue
+		}
+		test.String(t, w.String(), "a{background:url(../x/a.png)}b{background:url(../y/b.png)}\n/*# sourceMappingURL=all.css.map */")
+
+		b, err := sm.MarshalJSON()
+		test.T(t, err, nil)
+		test.String(t, string(b), `{"version":3,"file":"all.css","sources":["../x/a.css","../y/b.css"],"names":[],"mappings":"AAAA,EAAI,2BCAJ,EAAI"}`)
+	}
+}
@@ -0,0 +1,53 @@
+package minify // import "github.com/tdewolff/minify"
+
+import (
+	"regexp"
+)
+
+// licenseComment matches comments that contain a license, such as /*! ... */ or those with a @license, @preserve or @cc_on directive.
+var licenseComment = regexp.MustCompile(`^(/\*|//|<!--)!|@(license|preserve|cc_on)\b`)
+
+// Comments is a comment retention policy shared by the CSS, HTML, JS and SVG minifiers. It decides which comments are kept in the output,
+// the minifiers use their default policy when it is nil.
+type Comments struct {
+	pattern *regexp.Regexp // nil matches no comments
+	all     bool
+
+	// Extract receives the comments that match the policy, which are then removed from the output. This allows collecting licenses in a separate file.
+	// The comment must be copied if it is used after Extract returns.
+	Extract func(comment []byte)
+}
+
+// NewComments returns a comment retention policy. The policy is either none, all, license, or a regular expression that matches the comments to keep,
+// including their delimiters such as /* */ or <!-- -->.
+func NewComments(policy string) (*Comments, error) {
+	switch policy {
+	case "none":
+		return &Comments{}, nil
+	case "all":
+		return &Comments{all: true}, nil
+	case "license":
+		return &Comments{pattern: licenseComment}, nil
+	}
+	pattern, err := regexp.Compile(policy)
+	if err != nil {
+		return nil, err
+	}
+	return &Comments{pattern: pattern}, nil
+}
+
+// Match returns true if the comment, including its delimiters, matches the policy.
+func (c *Comments) Match(comment []byte) bool {
+	return c.all || c.pattern != nil && c.pattern.Match(comment)
+}
+
+// Keep returns true if the comment, including its delimiters, must be written to the output. Matching comments are passed to Extract instead when it is set.
+func (c *Comments) Keep(comment []byte) bool {
+	if !c.Match(comment) {
+		return false
+	} else if c.Extract != nil {
+		c.Extract(comment)
+		return false
+	}
+	return true
+}
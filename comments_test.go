@@ -0,0 +1,50 @@
+package minify // import "github.com/tdewolff/minify"
+
+import (
+	"testing"
+
+	"github.com/tdewolff/test"
+)
+
+func TestComments(t *testing.T) {
+	commentTests := []struct {
+		policy   string
+		comment  string
+		expected bool
+	}{
+		{"none", "/*! MIT */", false},
+		{"all", "/* x */", true},
+		{"all", "<!-- x -->", true},
+		{"license", "/*! MIT */", true},
+		{"license", "//! MIT", true},
+		{"license", "<!--! MIT -->", true},
+		{"license", "/** @license MIT */", true},
+		{"license", "// @preserve", true},
+		{"license", "/*@cc_on @*/", true},
+		{"license", "/* x */", false},
+		{"license", "/* @licenses */", false},
+		{"^/\\*\\*", "/** x */", true},
+		{"^/\\*\\*", "/* x */", false},
+	}
+	for _, tt := range commentTests {
+		t.Run(tt.policy+" "+tt.comment, func(t *testing.T) {
+			comments, err := NewComments(tt.policy)
+			test.Error(t, err)
+			test.That(t, comments.Keep([]byte(tt.comment)) == tt.expected)
+		})
+	}
+
+	_, err := NewComments("(")
+	test.That(t, err != nil)
+}
+
+func TestCommentsExtract(t *testing.T) {
+	extracted := []string{}
+	comments, _ := NewComments("license")
+	comments.Extract = func(comment []byte) {
+		extracted = append(extracted, string(comment))
+	}
+	test.That(t, !comments.Keep([]byte("/*! MIT */")))
+	test.That(t, !comments.Keep([]byte("/* x */")))
+	test.That(t, len(extracted) == 1 && extracted[0] == "/*! MIT */")
+}
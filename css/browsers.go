@@ -0,0 +1,220 @@
+package css // import "github.com/tdewolff/minify/css"
+
+// browserAliases are the browser names accepted in target queries, which are case-insensitive.
+var browserAliases = map[string]string{
+	"android":        "android",
+	"and_chr":        "and_chr",
+	"and_ff":         "and_ff",
+	"chrome":         "chrome",
+	"chromeandroid":  "and_chr",
+	"edge":           "edge",
+	"explorer":       "ie",
+	"ff":             "firefox",
+	"firefox":        "firefox",
+	"firefoxandroid": "and_ff",
+	"ie":             "ie",
+	"ios":            "ios_saf",
+	"ios_saf":        "ios_saf",
+	"opera":          "opera",
+	"safari":         "safari",
+	"samsung":        "samsung",
+}
+
+// browserVersions are the released versions of each browser, oldest first. Like browserslist, the Android browser and the Android versions
+// of Chrome and Firefox only list their latest version once they follow the desktop releases.
+var browserVersions = map[string][]float64{
+	"android": {2.1, 2.2, 2.3, 3, 4, 4.1, 4.2, 4.3, 4.4, 133},
+	"and_chr": {133},
+	"and_ff":  {135},
+	"chrome":  versionRange(nil, 4, 133),
+	"edge":    versionRange(versionRange(nil, 12, 18), 79, 133),
+	"firefox": versionRange([]float64{2, 3, 3.5, 3.6}, 4, 135),
+	"ie":      {5.5, 6, 7, 8, 9, 10, 11},
+	"ios_saf": {3.2, 4, 4.2, 5, 6, 7, 8, 9, 9.3, 10, 10.3, 11, 11.3, 12, 12.2, 13, 13.4, 14, 14.5, 15, 15.1, 15.2, 15.4, 15.5, 15.6,
+		16, 16.1, 16.2, 16.3, 16.4, 16.5, 16.6, 17, 17.1, 17.2, 17.3, 17.4, 17.5, 17.6, 18, 18.1, 18.2, 18.3},
+	"opera": versionRange([]float64{9, 9.5, 10, 10.5, 10.6, 11, 11.1, 11.5, 11.6, 12, 12.1}, 15, 116),
+	"safari": {3.1, 3.2, 4, 5, 5.1, 6, 6.1, 7, 7.1, 8, 9, 9.1, 10, 10.1, 11, 11.1, 12, 12.1, 13, 13.1, 14, 14.1, 15, 15.1, 15.2, 15.4, 15.5, 15.6,
+		16, 16.1, 16.2, 16.3, 16.4, 16.5, 16.6, 17, 17.1, 17.2, 17.3, 17.4, 17.5, 17.6, 18, 18.1, 18.2, 18.3},
+	"samsung": {4, 5, 6.2, 7.2, 8.2, 9.2, 10.1, 11.1, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27},
+}
+
+// firefoxESR are the Firefox versions with extended support.
+var firefoxESR = []float64{115, 128}
+
+// samsungChromium are the Chromium versions of the Samsung Internet versions.
+var samsungChromium = [][2]float64{
+	{4, 44}, {5, 51}, {6.2, 56}, {7.2, 59}, {8.2, 63}, {9.2, 67}, {10.1, 71}, {11.1, 75}, {12, 79}, {13, 83}, {14, 87}, {15, 90}, {16, 92},
+	{17, 96}, {18, 99}, {19, 102}, {20, 106}, {21, 110}, {22, 111}, {23, 115}, {24, 117}, {25, 121}, {26, 122}, {27, 125},
+}
+
+func versionRange(versions []float64, from, to int) []float64 {
+	for v := from; v <= to; v++ {
+		versions = append(versions, float64(v))
+	}
+	return versions
+}
+
+// isDead returns true for browser versions that haven't been updated for years.
+func isDead(browser string, version float64) bool {
+	switch browser {
+	case "ie":
+		return true
+	case "edge":
+		return version < 79
+	case "opera":
+		return version < 15
+	case "android":
+		return version < 37
+	case "samsung":
+		return version < 5
+	}
+	return false
+}
+
+// compatTable has the first version of each browser that supports a feature without vendor prefix, where a missing browser doesn't
+// support it. Browsers based on Chromium use the Chrome versions, so that opera, edge and android are the versions from before they were
+// based on Chromium.
+var compatTable = map[string]map[string]float64{
+	"animations":      {"chrome": 43, "firefox": 16, "safari": 9, "ios_saf": 9, "ie": 10, "edge": 12, "opera": 12.1},
+	"appearance":      {"chrome": 84, "firefox": 80, "safari": 15.4, "ios_saf": 15.4},
+	"backdrop-filter": {"chrome": 76, "firefox": 103, "safari": 18, "ios_saf": 18, "edge": 17},
+	"backface":        {"chrome": 36, "firefox": 16, "safari": 15.4, "ios_saf": 15.4, "ie": 10, "edge": 12},
+	"border-radius":   {"chrome": 5, "firefox": 4, "safari": 5, "ios_saf": 4.2, "ie": 9, "edge": 12, "opera": 10.5, "android": 2.2},
+	"box-decoration":  {"chrome": 130, "firefox": 32},
+	"box-shadow":      {"chrome": 10, "firefox": 4, "safari": 5.1, "ios_saf": 5, "ie": 9, "edge": 12, "opera": 10.5, "android": 4},
+	"box-sizing":      {"chrome": 10, "firefox": 29, "safari": 5.1, "ios_saf": 6, "ie": 8, "edge": 12, "android": 4},
+	"calc":            {"chrome": 26, "firefox": 16, "safari": 7, "ios_saf": 7, "ie": 9, "edge": 12},
+	"clip-path":       {"chrome": 55, "firefox": 54, "safari": 13.1, "ios_saf": 13.4},
+	"color-alpha":     {"chrome": 65, "firefox": 52, "safari": 12.1, "ios_saf": 12.2},
+	"column-span":     {"chrome": 50, "firefox": 71, "safari": 9, "ios_saf": 9, "ie": 10, "edge": 12, "opera": 11.1},
+	"filter":          {"chrome": 53, "firefox": 35, "safari": 9.1, "ios_saf": 9.3, "edge": 13},
+	"fit-content":     {"chrome": 46, "firefox": 94, "safari": 11, "ios_saf": 11},
+	"flexbox":         {"chrome": 29, "firefox": 28, "safari": 9, "ios_saf": 9, "ie": 11, "edge": 12, "opera": 12.1, "android": 4.4},
+	"font-features":   {"chrome": 48, "firefox": 34, "safari": 9.1, "ios_saf": 9.3, "ie": 10, "edge": 12},
+	"font-kerning":    {"chrome": 33, "firefox": 32, "safari": 9, "ios_saf": 9},
+	"gradients":       {"chrome": 26, "firefox": 16, "safari": 7, "ios_saf": 7, "ie": 10, "edge": 12, "opera": 12.1, "android": 4.4},
+	"grab-cursor":     {"chrome": 68, "firefox": 27, "safari": 11},
+	"hex-alpha":       {"chrome": 62, "firefox": 49, "safari": 10, "ios_saf": 10},
+	"hyphens":         {"chrome": 88, "firefox": 43, "safari": 17, "ios_saf": 17},
+	"image-set":       {"chrome": 113, "firefox": 88, "safari": 17, "ios_saf": 17},
+	"intrinsic-width": {"chrome": 46, "firefox": 66, "safari": 11, "ios_saf": 11},
+	"masks":           {"chrome": 120, "firefox": 53, "safari": 15.4, "ios_saf": 15.4},
+	"nesting":         {"chrome": 120, "firefox": 117, "safari": 17.2, "ios_saf": 17.2},
+	"multicolumn":     {"chrome": 50, "firefox": 52, "safari": 9, "ios_saf": 9, "ie": 10, "edge": 12, "opera": 11.1},
+	"sticky":          {"chrome": 56, "firefox": 32, "safari": 13, "ios_saf": 13, "edge": 16},
+	"tab-size":        {"chrome": 21, "firefox": 91, "safari": 7, "ios_saf": 7},
+	"text-decoration": {"chrome": 57, "firefox": 36, "safari": 12.1, "ios_saf": 12.2},
+	"text-emphasis":   {"chrome": 99, "firefox": 46, "safari": 7, "ios_saf": 7},
+	"transforms2d":    {"chrome": 36, "firefox": 16, "safari": 9, "ios_saf": 9, "ie": 10, "edge": 12, "opera": 12.1},
+	"transforms3d":    {"chrome": 36, "firefox": 16, "safari": 9, "ios_saf": 9, "edge": 12},
+	"transitions":     {"chrome": 26, "firefox": 16, "safari": 7, "ios_saf": 7, "ie": 10, "edge": 12, "opera": 12.1, "android": 4.4},
+	"user-select":     {"chrome": 54, "firefox": 69},
+	"writing-mode":    {"chrome": 48, "firefox": 41, "safari": 10.1, "ios_saf": 10.3, "edge": 12},
+	"zoom-cursor":     {"chrome": 37, "firefox": 24, "safari": 9, "edge": 12},
+}
+
+// prefixedProperties are the features of properties that have vendor prefixed versions, other prefixed properties are always kept.
+var prefixedProperties = map[string]string{
+	"align-content":              "flexbox",
+	"align-items":                "flexbox",
+	"align-self":                 "flexbox",
+	"animation":                  "animations",
+	"animation-delay":            "animations",
+	"animation-direction":        "animations",
+	"animation-duration":         "animations",
+	"animation-fill-mode":        "animations",
+	"animation-iteration-count":  "animations",
+	"animation-name":             "animations",
+	"animation-play-state":       "animations",
+	"animation-timing-function":  "animations",
+	"appearance":                 "appearance",
+	"backdrop-filter":            "backdrop-filter",
+	"backface-visibility":        "backface",
+	"border-bottom-left-radius":  "border-radius",
+	"border-bottom-right-radius": "border-radius",
+	"border-radius":              "border-radius",
+	"border-top-left-radius":     "border-radius",
+	"border-top-right-radius":    "border-radius",
+	"box-decoration-break":       "box-decoration",
+	"box-shadow":                 "box-shadow",
+	"box-sizing":                 "box-sizing",
+	"clip-path":                  "clip-path",
+	"column-count":               "multicolumn",
+	"column-fill":                "multicolumn",
+	"column-gap":                 "multicolumn",
+	"column-rule":                "multicolumn",
+	"column-rule-color":          "multicolumn",
+	"column-rule-style":          "multicolumn",
+	"column-rule-width":          "multicolumn",
+	"column-span":                "column-span",
+	"column-width":               "multicolumn",
+	"columns":                    "multicolumn",
+	"filter":                     "filter",
+	"flex":                       "flexbox",
+	"flex-basis":                 "flexbox",
+	"flex-direction":             "flexbox",
+	"flex-flow":                  "flexbox",
+	"flex-grow":                  "flexbox",
+	"flex-shrink":                "flexbox",
+	"flex-wrap":                  "flexbox",
+	"font-feature-settings":      "font-features",
+	"font-kerning":               "font-kerning",
+	"hyphens":                    "hyphens",
+	"justify-content":            "flexbox",
+	"mask":                       "masks",
+	"mask-clip":                  "masks",
+	"mask-image":                 "masks",
+	"mask-origin":                "masks",
+	"mask-position":              "masks",
+	"mask-repeat":                "masks",
+	"mask-size":                  "masks",
+	"order":                      "flexbox",
+	"perspective":                "transforms3d",
+	"perspective-origin":         "transforms3d",
+	"tab-size":                   "tab-size",
+	"text-decoration-color":      "text-decoration",
+	"text-decoration-line":       "text-decoration",
+	"text-decoration-style":      "text-decoration",
+	"text-emphasis":              "text-emphasis",
+	"text-emphasis-color":        "text-emphasis",
+	"text-emphasis-position":     "text-emphasis",
+	"text-emphasis-style":        "text-emphasis",
+	"transform":                  "transforms2d",
+	"transform-origin":           "transforms2d",
+	"transform-style":            "transforms3d",
+	"transition":                 "transitions",
+	"transition-delay":           "transitions",
+	"transition-duration":        "transitions",
+	"transition-property":        "transitions",
+	"transition-timing-function": "transitions",
+	"user-select":                "user-select",
+	"writing-mode":               "writing-mode",
+}
+
+// prefixedValues are the features of keywords and functions that have vendor prefixed versions, property names in values such as
+// -webkit-transform in transition use prefixedProperties.
+var prefixedValues = map[string]string{
+	"calc(":                      "calc",
+	"fit-content":                "fit-content",
+	"flex":                       "flexbox",
+	"flexbox":                    "flexbox",
+	"grab":                       "grab-cursor",
+	"grabbing":                   "grab-cursor",
+	"image-set(":                 "image-set",
+	"inline-flex":                "flexbox",
+	"inline-flexbox":             "flexbox",
+	"linear-gradient(":           "gradients",
+	"max-content":                "intrinsic-width",
+	"min-content":                "intrinsic-width",
+	"radial-gradient(":           "gradients",
+	"repeating-linear-gradient(": "gradients",
+	"repeating-radial-gradient(": "gradients",
+	"sticky":                     "sticky",
+	"zoom-in":                    "zoom-cursor",
+	"zoom-out":                   "zoom-cursor",
+}
+
+// prefixedAtRules are the features of at-rules that have vendor prefixed versions.
+var prefixedAtRules = map[string]string{
+	"keyframes": "animations",
+}
@@ -0,0 +1,408 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/css"
+)
+
+// gamutEpsilon is how far a color may be outside of the sRGB gamut to be written as hex, which is less than the precision of hex.
+const gamutEpsilon = 0.5 / 255.0
+
+// isColorFunction returns true for the color functions, given with their opening parenthesis such as rgb(.
+func isColorFunction(fun []byte) bool {
+	switch string(parse.ToLower(parse.Copy(fun))) {
+	case "rgb(", "rgba(", "hsl(", "hsla(", "hwb(", "lab(", "lch(", "oklab(", "oklch(", "color(":
+		return true
+	}
+	return false
+}
+
+// colorArgs returns the arguments and alpha of a color function, which are either separated by commas or by whitespace followed by
+// a slash and the alpha, such as rgb(255,0,0,.5) or rgb(255 0 0/50%). It returns false for arguments that aren't numbers or identifiers.
+func colorArgs(values []css.Token) ([]css.Token, *css.Token, bool, bool) {
+	legacy := false
+	for _, val := range values {
+		if val.TokenType == css.CommaToken {
+			legacy = true
+		}
+	}
+
+	args := []css.Token{}
+	var alpha *css.Token
+	sep := true // a separator precedes the argument
+	slash := false
+	for i, val := range values {
+		switch val.TokenType {
+		case css.WhitespaceToken:
+			if !legacy {
+				sep = true
+			}
+		case css.CommaToken:
+			if sep {
+				return nil, nil, false, false
+			}
+			sep = true
+		case css.DelimToken:
+			if legacy || slash || val.Data[0] != '/' {
+				return nil, nil, false, false
+			}
+			slash = true
+			sep = true
+		case css.NumberToken, css.PercentageToken, css.DimensionToken, css.IdentToken:
+			if !sep || alpha != nil {
+				return nil, nil, false, false
+			} else if slash {
+				alpha = &values[i]
+			} else {
+				args = append(args, val)
+			}
+			sep = false
+		default:
+			return nil, nil, false, false
+		}
+	}
+	if sep || slash && alpha == nil {
+		return nil, nil, false, false
+	} else if legacy && len(args) == 4 {
+		alpha = &args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+	return args, alpha, legacy, true
+}
+
+// colorNumber returns the value of a number, or of a percentage where 100% equals max.
+func colorNumber(t css.Token, max float64) (float64, bool) {
+	if t.TokenType == css.NumberToken {
+		f, err := strconv.ParseFloat(string(t.Data), 64)
+		return f, err == nil
+	} else if t.TokenType == css.PercentageToken {
+		f, err := strconv.ParseFloat(string(t.Data[:len(t.Data)-1]), 64)
+		return f / 100.0 * max, err == nil
+	}
+	return 0.0, false
+}
+
+// colorHue returns the hue in degrees of a number or angle.
+func colorHue(t css.Token) (float64, bool) {
+	if t.TokenType == css.NumberToken {
+		return colorNumber(t, 0.0)
+	} else if t.TokenType != css.DimensionToken {
+		return 0.0, false
+	}
+	n := parse.Number(t.Data)
+	f, err := strconv.ParseFloat(string(t.Data[:n]), 64)
+	if err != nil {
+		return 0.0, false
+	}
+	switch string(parse.ToLower(parse.Copy(t.Data[n:]))) {
+	case "deg":
+		return f, true
+	case "grad":
+		return f * 0.9, true
+	case "rad":
+		return f * 180.0 / math.Pi, true
+	case "turn":
+		return f * 360.0, true
+	}
+	return 0.0, false
+}
+
+// colorRGB converts the color function to gamma-encoded sRGB, where channels outside of [0,1] are outside of the sRGB gamut.
+// It returns false for colors with missing components (none), unknown color spaces, and invalid arguments.
+func colorRGB(fun string, args []css.Token) ([3]float64, bool) {
+	rgb := [3]float64{}
+	if fun == "color" {
+		if len(args) != 4 || args[0].TokenType != css.IdentToken {
+			return rgb, false
+		}
+		var c [3]float64
+		for i, arg := range args[1:] {
+			var ok bool
+			if c[i], ok = colorNumber(arg, 1.0); !ok {
+				return rgb, false
+			}
+		}
+		switch string(parse.ToLower(parse.Copy(args[0].Data))) {
+		case "srgb":
+			return c, true
+		case "srgb-linear":
+			return gammaRGB(c), true
+		case "display-p3":
+			return gammaRGB(mulMatrix(xyzToLinearRGB, mulMatrix(linearP3ToXYZ, linearRGB(c)))), true
+		case "xyz", "xyz-d65":
+			return gammaRGB(mulMatrix(xyzToLinearRGB, c)), true
+		case "xyz-d50":
+			return gammaRGB(mulMatrix(xyzToLinearRGB, mulMatrix(d50ToD65, c))), true
+		}
+		return rgb, false
+	} else if len(args) != 3 {
+		return rgb, false
+	}
+
+	var a, b, c float64
+	var ok [3]bool
+	switch fun {
+	case "rgb", "rgba":
+		a, ok[0] = colorNumber(args[0], 255.0)
+		b, ok[1] = colorNumber(args[1], 255.0)
+		c, ok[2] = colorNumber(args[2], 255.0)
+		rgb = [3]float64{clamp(a/255.0, 0.0, 1.0), clamp(b/255.0, 0.0, 1.0), clamp(c/255.0, 0.0, 1.0)}
+	case "hsl", "hsla", "hwb":
+		a, ok[0] = colorHue(args[0])
+		b, ok[1] = colorNumber(args[1], 100.0)
+		c, ok[2] = colorNumber(args[2], 100.0)
+		a = math.Mod(a, 360.0)
+		if a < 0.0 {
+			a += 360.0
+		}
+		b, c = clamp(b/100.0, 0.0, 1.0), clamp(c/100.0, 0.0, 1.0)
+		if fun == "hwb" {
+			if 1.0 <= b+c {
+				gray := b / (b + c)
+				rgb = [3]float64{gray, gray, gray}
+			} else {
+				rgb[0], rgb[1], rgb[2] = css.HSL2RGB(a/360.0, 1.0, 0.5)
+				for i := range rgb {
+					rgb[i] = rgb[i]*(1.0-b-c) + b
+				}
+			}
+		} else {
+			rgb[0], rgb[1], rgb[2] = css.HSL2RGB(a/360.0, b, c)
+		}
+	case "lab", "lch":
+		a, ok[0] = colorNumber(args[0], 100.0)
+		if fun == "lab" {
+			b, ok[1] = colorNumber(args[1], 125.0)
+			c, ok[2] = colorNumber(args[2], 125.0)
+		} else {
+			var chroma, hue float64
+			chroma, ok[1] = colorNumber(args[1], 150.0)
+			hue, ok[2] = colorHue(args[2])
+			b, c = chroma*math.Cos(hue*math.Pi/180.0), chroma*math.Sin(hue*math.Pi/180.0)
+		}
+		rgb = gammaRGB(mulMatrix(xyzToLinearRGB, mulMatrix(d50ToD65, labToXYZ(a, b, c))))
+	case "oklab", "oklch":
+		a, ok[0] = colorNumber(args[0], 1.0)
+		if fun == "oklab" {
+			b, ok[1] = colorNumber(args[1], 0.4)
+			c, ok[2] = colorNumber(args[2], 0.4)
+		} else {
+			var chroma, hue float64
+			chroma, ok[1] = colorNumber(args[1], 0.4)
+			hue, ok[2] = colorHue(args[2])
+			b, c = chroma*math.Cos(hue*math.Pi/180.0), chroma*math.Sin(hue*math.Pi/180.0)
+		}
+		rgb = gammaRGB(oklabToLinearRGB(a, b, c))
+	default:
+		return rgb, false
+	}
+	return rgb, ok[0] && ok[1] && ok[2]
+}
+
+func clamp(f, min, max float64) float64 {
+	if f < min {
+		return min
+	} else if max < f {
+		return max
+	}
+	return f
+}
+
+// matrices of the conversions between color spaces, from https://www.w3.org/TR/css-color-4/#color-conversion-code
+var (
+	xyzToLinearRGB = [3][3]float64{
+		{3.2409699419045226, -1.537383177570094, -0.4986107602930034},
+		{-0.9692436362808796, 1.8759675015077202, 0.04155505740717559},
+		{0.05563007969699366, -0.20397695888897652, 1.0569715142428786},
+	}
+	linearP3ToXYZ = [3][3]float64{
+		{0.4865709486482162, 0.26566769316909306, 0.1982172852343625},
+		{0.2289745640697488, 0.6917385218365064, 0.079286914093745},
+		{0.0, 0.04511338185890264, 1.043944368900976},
+	}
+	d50ToD65 = [3][3]float64{
+		{0.955473421488075, -0.02309845494876471, 0.06325924320057072},
+		{-0.0283697093338637, 1.0099953980813041, 0.021041441191917323},
+		{0.012314014864481998, -0.020507649298898964, 1.330365926242124},
+	}
+)
+
+func mulMatrix(m [3][3]float64, v [3]float64) [3]float64 {
+	return [3]float64{
+		m[0][0]*v[0] + m[0][1]*v[1] + m[0][2]*v[2],
+		m[1][0]*v[0] + m[1][1]*v[1] + m[1][2]*v[2],
+		m[2][0]*v[0] + m[2][1]*v[1] + m[2][2]*v[2],
+	}
+}
+
+// linearRGB removes the gamma encoding of sRGB, which is also used by display-p3.
+func linearRGB(c [3]float64) [3]float64 {
+	for i, v := range c {
+		if abs := math.Abs(v); abs <= 0.04045 {
+			c[i] = v / 12.92
+		} else {
+			c[i] = math.Copysign(math.Pow((abs+0.055)/1.055, 2.4), v)
+		}
+	}
+	return c
+}
+
+// gammaRGB applies the gamma encoding of sRGB.
+func gammaRGB(c [3]float64) [3]float64 {
+	for i, v := range c {
+		if abs := math.Abs(v); abs <= 0.0031308 {
+			c[i] = v * 12.92
+		} else {
+			c[i] = math.Copysign(1.055*math.Pow(abs, 1.0/2.4)-0.055, v)
+		}
+	}
+	return c
+}
+
+// labToXYZ converts CIE Lab to XYZ with a D50 white point.
+func labToXYZ(l, a, b float64) [3]float64 {
+	const kappa = 24389.0 / 27.0
+	const epsilon = 216.0 / 24389.0
+	fy := (l + 16.0) / 116.0
+	fx := fy + a/500.0
+	fz := fy - b/200.0
+
+	xyz := [3]float64{(116.0*fx - 16.0) / kappa, l / kappa, (116.0*fz - 16.0) / kappa}
+	if fx*fx*fx > epsilon {
+		xyz[0] = fx * fx * fx
+	}
+	if l > kappa*epsilon {
+		xyz[1] = fy * fy * fy
+	}
+	if fz*fz*fz > epsilon {
+		xyz[2] = fz * fz * fz
+	}
+	xyz[0] *= 0.3457 / 0.3585
+	xyz[2] *= (1.0 - 0.3457 - 0.3585) / 0.3585
+	return xyz
+}
+
+// oklabToLinearRGB converts OKLab to linear sRGB, from https://bottosson.github.io/posts/oklab/
+func oklabToLinearRGB(l, a, b float64) [3]float64 {
+	l_ := l + 0.3963377774*a + 0.2158037573*b
+	m_ := l - 0.1055613458*a - 0.0638541728*b
+	s_ := l - 0.0894841775*a - 1.2914855480*b
+	l_, m_, s_ = l_*l_*l_, m_*m_*m_, s_*s_*s_
+	return [3]float64{
+		4.0767416621*l_ - 3.3077115913*m_ + 0.2309699292*s_,
+		-1.2684380046*l_ + 2.6097574011*m_ - 0.3413193965*s_,
+		-0.0041960863*l_ - 0.7034186147*m_ + 1.7076147010*s_,
+	}
+}
+
+// minifyColor writes the shortest form of a color function, which is a color name or hex color when the color is inside of the
+// sRGB gamut, and otherwise the function with minified arguments. It returns false if the arguments are invalid, such as var().
+func (c *cssMinifier) minifyColor(values []css.Token) (bool, error) {
+	if len(values) < 3 || values[len(values)-1].TokenType != css.RightParenthesisToken {
+		return false, nil
+	}
+	fun := string(parse.ToLower(parse.Copy(values[0].Data[:len(values[0].Data)-1])))
+	args, alphaToken, legacy, ok := colorArgs(values[1 : len(values)-1])
+	if !ok || legacy && (fun != "rgb" && fun != "rgba" && fun != "hsl" && fun != "hsla") {
+		return false, nil
+	}
+	for i, arg := range args {
+		if arg.TokenType == css.IdentToken && (0 < i || fun != "color") && !parse.EqualFold(arg.Data, []byte("none")) {
+			return false, nil
+		}
+	}
+
+	alpha := 1.0
+	missingAlpha := false // none, which takes the alpha of the other color in gradients
+	if alphaToken != nil {
+		if alpha, ok = colorNumber(*alphaToken, 1.0); !ok && (alphaToken.TokenType != css.IdentToken || !parse.EqualFold(alphaToken.Data, []byte("none"))) {
+			return false, nil
+		}
+		missingAlpha = !ok
+		alpha = clamp(alpha, 0.0, 1.0)
+	}
+
+	if rgb, ok := colorRGB(fun, args); ok && !missingAlpha && (alpha == 1.0 || !c.keepCSS2 || alpha < minify.Epsilon) {
+		inGamut := true
+		for _, v := range rgb {
+			if v < -gamutEpsilon || 1.0+gamutEpsilon < v {
+				inGamut = false
+			}
+		}
+		if inGamut {
+			a := byte(alpha*255.0 + 0.5)
+			if a == 0 && c.keepCSS2 {
+				_, err := c.w.Write(transparentBytes)
+				return true, err
+			} else if a == 0 {
+				_, err := c.w.Write([]byte("#0000"))
+				return true, err
+			}
+			rgba := [4]byte{}
+			for i, v := range rgb {
+				rgba[i] = byte(clamp(v, 0.0, 1.0)*255.0 + 0.5)
+			}
+			if a == 255 {
+				return true, c.minifyColorAsHex([3]byte{rgba[0], rgba[1], rgba[2]})
+			} else if !c.keepCSS2 {
+				rgba[3] = a
+				val := make([]byte, 9)
+				val[0] = '#'
+				hex.Encode(val[1:], rgba[:])
+				_, val = c.shortenToken(0, css.HashToken, val)
+				_, err := c.w.Write(val)
+				return true, err
+			}
+		}
+	}
+
+	// keep the function with minified arguments
+	b := []byte(fun)
+	if !c.keepCSS2 && (fun == "rgba" || fun == "hsla") {
+		b = b[:3] // rgb and hsl accept an alpha
+	}
+	b = append(b, '(')
+	for i, arg := range args {
+		if 0 < i {
+			if legacy {
+				b = append(b, ',')
+			} else {
+				b = append(b, ' ')
+			}
+		}
+		_, data := c.shortenToken(0, arg.TokenType, parse.Copy(arg.Data))
+		if arg.TokenType == css.IdentToken {
+			data = parse.ToLower(data)
+		}
+		b = append(b, data...)
+	}
+	if alphaToken != nil && (alpha != 1.0 || missingAlpha) {
+		if legacy {
+			b = append(b, ',')
+		} else {
+			b = append(b, '/')
+		}
+		b = append(b, c.minifyAlpha(*alphaToken, alpha)...)
+	}
+	_, err := c.w.Write(append(b, ')'))
+	return true, err
+}
+
+// minifyAlpha returns the shortest of the alpha as a number or as a percentage.
+func (c *cssMinifier) minifyAlpha(t css.Token, alpha float64) []byte {
+	if t.TokenType == css.IdentToken {
+		return parse.ToLower(parse.Copy(t.Data))
+	}
+	_, data := c.shortenToken(0, t.TokenType, parse.Copy(t.Data))
+	if t.TokenType == css.PercentageToken {
+		if _, num := c.shortenToken(0, css.NumberToken, []byte(strconv.FormatFloat(alpha, 'f', -1, 64))); len(num) < len(data) {
+			return num
+		}
+	}
+	return data
+}
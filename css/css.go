@@ -6,10 +6,11 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"strconv"
+	"path/filepath"
 
 	"github.com/tdewolff/minify/v2"
 	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/buffer"
 	"github.com/tdewolff/parse/v2/css"
 )
 
@@ -31,7 +32,16 @@ type cssMinifier struct {
 	p *css.Parser
 	o *Minifier
 
+	mapper       minify.Mapper // nil when no source map is recorded
 	valuesBuffer []Token
+	keepNames    bool // selectors are renamed after the structural passes, which may depend on the original names
+	keepCSS2     bool // KeepCSS2 or derived from Targets
+
+	filename string // absolute path of the stylesheet, empty when unknown
+	rootDir  string // directory that relative URLs in the output are relative to, which is that of the output or of the stylesheet
+	rebase   string // directory of the stylesheet relative to rootDir, by which its relative URLs are prefixed
+
+	selectors [][]byte // minified selectors of the ruleset, which are written together to remove duplicates
 }
 
 ////////////////////////////////////////////////////////////////
@@ -43,6 +53,45 @@ var DefaultMinifier = &Minifier{Decimals: -1, KeepCSS2: false}
 type Minifier struct {
 	Decimals int
 	KeepCSS2 bool
+	Comments *minify.Comments // nil keeps /*! comments
+
+	// MergeRules merges rulesets with identical selectors, and joins the selectors of rulesets with identical declarations,
+	// when the cascade order allows it. Rulesets are never merged across at-rules such as @media.
+	MergeRules bool
+
+	// FoldShorthands collapses longhands that are declared together in a block into their shorthand, such as margin-top, margin-right,
+	// margin-bottom and margin-left into margin.
+	FoldShorthands bool
+
+	// RemoveOverridden removes declarations that are overridden by a later declaration in the same block, such as color:red;color:blue,
+	// unless they may be a fallback for a value that isn't supported by all browsers.
+	RemoveOverridden bool
+
+	// Purge removes the selectors that reference tag names, class names or ids that aren't used by the documents, see NewPurge.
+	Purge *Purge
+
+	// InlineImports replaces @import rules of local stylesheets by their contents, wrapped in @media, @supports and @layer rules for
+	// their conditions, and rebases their relative URLs. Imports are resolved relative to the filename parameter, or to BaseDir.
+	InlineImports bool
+	BaseDir       string                                // directory of imports when the filename is unknown, and of imports starting with a slash
+	ReadFile      func(filename string) ([]byte, error) // reads imported stylesheets, defaults to ioutil.ReadFile
+
+	// Targets are the browsers to support, see ParseTargets. Declarations and at-rules with vendor prefixes that none of them need are
+	// removed, and KeepCSS2 is derived from the targets instead.
+	Targets *Targets
+
+	// Nesting parses nested style rules and keeps them, otherwise the parser treats them as invalid declarations. FlattenNesting
+	// replaces them by flat rulesets for browsers without nesting support, which is implied when Targets include any such browser.
+	Nesting        bool
+	FlattenNesting bool
+
+	// RenameCustomProperties renames custom properties such as --main-color to short names with the Names of M, consistently across files.
+	// Custom properties that are set or read by scripts must be left out of the stylesheets.
+	RenameCustomProperties bool
+
+	// InlineCustomProperties replaces the var() references to custom properties that are declared once on :root by their value, and
+	// removes their declaration. Custom properties that are set elsewhere, such as in style attributes or by scripts, must be left out.
+	InlineCustomProperties bool
 }
 
 // Minify minifies CSS data, it reads from r and writes to w.
@@ -51,195 +100,410 @@ func Minify(m *minify.M, w io.Writer, r io.Reader, params map[string]string) err
 }
 
 // Minify minifies CSS data, it reads from r and writes to w.
+// The filename and output parameters are the paths of the stylesheet and of the minified file, when both are given the relative URLs are
+// rebased so that they point to the same files from the output.
 func (o *Minifier) Minify(m *minify.M, w io.Writer, r io.Reader, params map[string]string) error {
 	isInline := params != nil && params["inline"] == "1"
 	c := &cssMinifier{
 		m: m,
 		w: w,
-		p: css.NewParser(r, isInline),
 		o: o,
+
+		keepCSS2: o.KeepCSS2,
+	}
+	if o.Targets != nil {
+		c.keepCSS2 = o.Targets.keepCSS2()
+	}
+	if params != nil && params["filename"] != "" {
+		c.filename = params["filename"]
+		if abs, err := filepath.Abs(c.filename); err == nil {
+			c.filename = abs
+		}
+	}
+	c.rootDir = c.dir()
+	if abs, err := filepath.Abs(c.rootDir); err == nil {
+		c.rootDir = abs
+	}
+	if c.filename != "" && params["output"] != "" {
+		// relative URLs are rebased from the directory of the stylesheet to that of the output
+		if output, err := filepath.Abs(params["output"]); err == nil {
+			c.rootDir = filepath.Dir(output)
+			c.rebase = relDir(c.rootDir, c.filename)
+		}
+	}
+	c.mapper, _ = w.(minify.Mapper)
+
+	if o.structural() {
+		c.keepNames = true
+		list, err := c.parse(r, isInline)
+		if err != nil {
+			return err
+		}
+		if o.InlineImports {
+			filename := c.filename
+			if filename == "" {
+				filename = filepath.Join(c.rootDir, "<stdin>") // so that it cannot import itself as <stdin>
+			}
+			list = c.inlineImports(list, []string{filename})
+		}
+		if o.flattenNesting() {
+			list = flattenNesting(list)
+		}
+		if o.Purge != nil {
+			list = o.Purge.purge(list)
+		}
+		if m != nil && m.Names != nil {
+			renameSelectors(m.Names, list)
+		}
+		if o.Targets != nil {
+			list = o.Targets.prune(list)
+		}
+		if o.InlineCustomProperties {
+			list = inlineCustomProperties(list)
+		}
+		if o.RemoveOverridden {
+			list = removeOverridden(list)
+		}
+		if o.FoldShorthands {
+			list = c.foldShorthands(list)
+		}
+		list = mergeMedia(list)
+		if o.MergeRules {
+			list = mergeRulesets(list)
+		}
+		return c.writeTree(list)
 	}
-	defer c.p.Restore()
 
+	c.p = css.NewParser(r, isInline)
+	defer c.p.Restore()
 	if err := c.minifyGrammar(); err != nil && err != io.EOF {
 		return err
 	}
 	return nil
 }
 
+// structural returns true if any of the options that change the structure of the stylesheet is set.
+func (o *Minifier) structural() bool {
+	return o.MergeRules || o.FoldShorthands || o.RemoveOverridden || o.Purge != nil || o.InlineImports || o.Targets != nil ||
+		o.Nesting || o.FlattenNesting || o.InlineCustomProperties
+}
+
+// flattenNesting returns true if nested rules are flattened, either by request or because some of the targets do not support nesting.
+func (o *Minifier) flattenNesting() bool {
+	return o.FlattenNesting || o.Nesting && o.Targets != nil && !o.Targets.supportsAll("nesting")
+}
+
 func (c *cssMinifier) minifyGrammar() error {
 	semicolonQueued := false
+	preludes := [][]byte{} // minified preludes of the open blocks, nil for rulesets
+	var mediaEnd []byte    // prelude of the closed @media rule whose closing brace is delayed to merge it with the next rule
 	for {
 		gt, _, data := c.p.Next()
-		switch gt {
-		case css.ErrorGrammar:
-			if perr, ok := c.p.Err().(*parse.Error); ok && perr.Message == "unexpected token in declaration" {
-				if semicolonQueued {
-					if _, err := c.w.Write(semicolonBytes); err != nil {
-						return err
-					}
-				}
-
-				// write out the offending declaration
-				if _, err := c.w.Write(data); err != nil {
-					return err
-				}
-				vals := c.p.Values()
-				if len(vals) > 0 && vals[len(vals)-1].TokenType == css.SemicolonToken {
-					vals = vals[:len(vals)-1]
-					semicolonQueued = true
-				}
-				for _, val := range vals {
-					if _, err := c.w.Write(val.Data); err != nil {
-						return err
-					}
-				}
-				continue
-			}
-			return c.p.Err()
-		case css.EndAtRuleGrammar, css.EndRulesetGrammar:
+		if mediaEnd != nil && gt != css.BeginAtRuleGrammar {
 			if _, err := c.w.Write(rightBracketBytes); err != nil {
 				return err
 			}
-			semicolonQueued = false
-			continue
-		}
-
-		if semicolonQueued {
-			if _, err := c.w.Write(semicolonBytes); err != nil {
-				return err
-			}
-			semicolonQueued = false
+			mediaEnd = nil
 		}
 
 		switch gt {
-		case css.AtRuleGrammar:
-			if _, err := c.w.Write(data); err != nil {
-				return err
+		case css.ErrorGrammar:
+			if !isDeclarationError(c.p.Err()) {
+				return c.p.Err()
 			}
-			values := c.p.Values()
-			if css.ToHash(data[1:]) == css.Import && len(values) == 2 && values[1].TokenType == css.URLToken {
-				url := values[1].Data
-				if url[4] != '"' && url[4] != '\'' {
-					url = url[3:]
-					url[0] = '"'
-					url[len(url)-1] = '"'
-				} else {
-					url = url[4 : len(url)-1]
-				}
-				values[1].Data = url
+		case css.EndAtRuleGrammar, css.EndRulesetGrammar:
+			var prelude []byte
+			if 0 < len(preludes) {
+				prelude = preludes[len(preludes)-1]
+				preludes = preludes[:len(preludes)-1]
 			}
-			for _, val := range values {
-				if _, err := c.w.Write(val.Data); err != nil {
-					return err
-				}
+			if gt == css.EndAtRuleGrammar && isMedia(prelude) {
+				mediaEnd = prelude
+			} else if _, err := c.w.Write(rightBracketBytes); err != nil {
+				return err
 			}
-			semicolonQueued = true
+			semicolonQueued = false
+			continue
+		case css.BeginRulesetGrammar:
+			preludes = append(preludes, nil)
 		case css.BeginAtRuleGrammar:
-			if _, err := c.w.Write(data); err != nil {
+			// minify the prelude first to merge consecutive @media rules with the same media queries
+			w := c.w
+			buf := buffer.NewWriter(make([]byte, 0, 64))
+			c.w = buf
+			_, err := c.minifyItem(gt, data)
+			c.w = w
+			if err != nil {
 				return err
 			}
-			for _, val := range c.p.Values() {
-				if _, err := c.w.Write(val.Data); err != nil {
+			prelude := buf.Bytes()
+			preludes = append(preludes, prelude)
+			if mediaEnd != nil && bytes.Equal(mediaEnd, prelude) {
+				mediaEnd = nil
+				continue
+			} else if mediaEnd != nil {
+				if _, err := c.w.Write(rightBracketBytes); err != nil {
+					return err
+				}
+				mediaEnd = nil
+			} else if semicolonQueued {
+				if _, err := c.w.Write(semicolonBytes); err != nil {
 					return err
 				}
 			}
-			if _, err := c.w.Write(leftBracketBytes); err != nil {
-				return err
-			}
-		case css.QualifiedRuleGrammar:
-			if err := c.minifySelectors(data, c.p.Values()); err != nil {
+			c.mapData(c.grammarStart(gt, data))
+			if _, err := c.w.Write(prelude); err != nil {
 				return err
 			}
-			if _, err := c.w.Write(commaBytes); err != nil {
+			semicolonQueued = false
+			continue
+		}
+
+		if semicolonQueued {
+			if _, err := c.w.Write(semicolonBytes); err != nil {
 				return err
 			}
-		case css.BeginRulesetGrammar:
-			if err := c.minifySelectors(data, c.p.Values()); err != nil {
-				return err
+		}
+		if (gt != css.QualifiedRuleGrammar && gt != css.BeginRulesetGrammar) || len(c.selectors) == 0 {
+			c.mapData(c.grammarStart(gt, data)) // selectors are written together with the last one
+		}
+
+		var err error
+		if semicolonQueued, err = c.minifyItem(gt, data); err != nil {
+			return err
+		}
+	}
+}
+
+// isDeclarationError returns true for errors of invalid declarations, which are written out as is.
+func isDeclarationError(err error) bool {
+	perr, ok := err.(*parse.Error)
+	return ok && perr.Message == "unexpected token in declaration"
+}
+
+// grammarStart returns the slice of the input where the grammar starts for source maps, or nil if it isn't mapped.
+func (c *cssMinifier) grammarStart(gt css.GrammarType, data []byte) []byte {
+	switch gt {
+	case css.AtRuleGrammar, css.BeginAtRuleGrammar, css.DeclarationGrammar, css.CustomPropertyGrammar:
+		return data
+	case css.QualifiedRuleGrammar, css.BeginRulesetGrammar:
+		if values := c.p.Values(); 0 < len(values) {
+			return values[0].Data
+		}
+	}
+	return nil
+}
+
+// minifyItem writes the grammar except for the closing brace of blocks, and returns whether a semicolon must be written before the next item.
+func (c *cssMinifier) minifyItem(gt css.GrammarType, data []byte) (bool, error) {
+	switch gt {
+	case css.ErrorGrammar:
+		// write out the offending declaration
+		if _, err := c.w.Write(data); err != nil {
+			return false, err
+		}
+		semicolon := false
+		vals := c.p.Values()
+		if len(vals) > 0 && vals[len(vals)-1].TokenType == css.SemicolonToken {
+			vals = vals[:len(vals)-1]
+			semicolon = true
+		}
+		for _, val := range vals {
+			if _, err := c.w.Write(val.Data); err != nil {
+				return false, err
 			}
-			if _, err := c.w.Write(leftBracketBytes); err != nil {
-				return err
+		}
+		return semicolon, nil
+	case css.AtRuleGrammar:
+		if _, err := c.w.Write(data); err != nil {
+			return false, err
+		}
+		values := c.p.Values()
+		if c.renameProperties() {
+			c.renameValues(values)
+		}
+		if css.ToHash(data[1:]) == css.Import && c.rebase != "" {
+			rebaseImport(values, c.rebase)
+		}
+		if css.ToHash(data[1:]) == css.Import && len(values) == 2 && values[1].TokenType == css.URLToken {
+			url := values[1].Data
+			if url[4] != '"' && url[4] != '\'' {
+				url = url[3:]
+				url[0] = '"'
+				url[len(url)-1] = '"'
+			} else {
+				url = url[4 : len(url)-1]
 			}
-		case css.DeclarationGrammar:
-			if _, err := c.w.Write(data); err != nil {
-				return err
+			values[1].Data = url
+		}
+		for _, val := range values {
+			if _, err := c.w.Write(val.Data); err != nil {
+				return false, err
 			}
-			if _, err := c.w.Write(colonBytes); err != nil {
-				return err
+		}
+		return true, nil
+	case css.BeginAtRuleGrammar:
+		if _, err := c.w.Write(data); err != nil {
+			return false, err
+		}
+		values := c.p.Values()
+		if c.renameProperties() {
+			c.renameValues(values)
+		}
+		switch css.ToHash(parse.ToLower(parse.Copy(data[1:]))) {
+		case css.Media:
+			values = c.minifyMediaQueries(values)
+		case css.Supports:
+			values = c.minifyConditions(values, false)
+		}
+		for _, val := range values {
+			if _, err := c.w.Write(val.Data); err != nil {
+				return false, err
 			}
-			if err := c.minifyDeclaration(data, c.p.Values()); err != nil {
-				return err
+		}
+		if _, err := c.w.Write(leftBracketBytes); err != nil {
+			return false, err
+		}
+	case css.QualifiedRuleGrammar:
+		if err := c.addSelector(c.p.Values()); err != nil {
+			return false, err
+		}
+	case css.BeginRulesetGrammar:
+		if err := c.addSelector(c.p.Values()); err != nil {
+			return false, err
+		}
+		for i, selector := range c.selectors {
+			if 0 < i {
+				if _, err := c.w.Write(commaBytes); err != nil {
+					return false, err
+				}
 			}
-			semicolonQueued = true
-		case css.CustomPropertyGrammar:
-			if _, err := c.w.Write(data); err != nil {
-				return err
+			if _, err := c.w.Write(selector); err != nil {
+				return false, err
 			}
-			if _, err := c.w.Write(colonBytes); err != nil {
-				return err
+		}
+		c.selectors = c.selectors[:0]
+		if _, err := c.w.Write(leftBracketBytes); err != nil {
+			return false, err
+		}
+	case css.DeclarationGrammar:
+		if c.rebase != "" {
+			rebaseURLs(c.p.Values(), c.rebase)
+		}
+		if c.renameProperties() {
+			c.renameValues(c.p.Values())
+		}
+		if _, err := c.w.Write(data); err != nil {
+			return false, err
+		}
+		if _, err := c.w.Write(colonBytes); err != nil {
+			return false, err
+		}
+		if err := c.minifyDeclaration(data, c.p.Values()); err != nil {
+			return false, err
+		}
+		return true, nil
+	case css.CustomPropertyGrammar:
+		if c.renameProperties() {
+			data = c.renameProperty(data)
+		}
+		if _, err := c.w.Write(data); err != nil {
+			return false, err
+		}
+		if _, err := c.w.Write(colonBytes); err != nil {
+			return false, err
+		}
+		if _, err := c.w.Write(c.minifyCustomProperty(c.p.Values()[0].Data)); err != nil {
+			return false, err
+		}
+		return true, nil
+	case css.CommentGrammar:
+		isBang := len(data) > 5 && data[1] == '*' && data[2] == '!'
+		if c.o.Comments != nil && c.o.Comments.Keep(data) || c.o.Comments == nil && isBang {
+			n := 2
+			if isBang {
+				n = 3
 			}
-			if _, err := c.w.Write(c.p.Values()[0].Data); err != nil {
-				return err
+			if _, err := c.w.Write(data[:n]); err != nil {
+				return false, err
 			}
-			semicolonQueued = true
-		case css.CommentGrammar:
-			if len(data) > 5 && data[1] == '*' && data[2] == '!' {
-				if _, err := c.w.Write(data[:3]); err != nil {
-					return err
-				}
-				comment := parse.TrimWhitespace(parse.ReplaceMultipleWhitespace(data[3 : len(data)-2]))
-				if _, err := c.w.Write(comment); err != nil {
-					return err
-				}
-				if _, err := c.w.Write(data[len(data)-2:]); err != nil {
-					return err
-				}
+			comment := parse.TrimWhitespace(parse.ReplaceMultipleWhitespace(data[n : len(data)-2]))
+			if _, err := c.w.Write(comment); err != nil {
+				return false, err
 			}
-		default:
-			if _, err := c.w.Write(data); err != nil {
-				return err
+			if _, err := c.w.Write(data[len(data)-2:]); err != nil {
+				return false, err
 			}
 		}
+	default:
+		if _, err := c.w.Write(data); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// mapData records the current output position for the source map, data must be a slice of the input.
+func (c *cssMinifier) mapData(data []byte) {
+	if c.mapper != nil && data != nil {
+		c.mapper.Map(data)
 	}
 }
 
 func (c *cssMinifier) minifySelectors(property []byte, values []css.Token) error {
 	inAttr := false
 	isClass := false
-	for _, val := range c.p.Values() {
+	var attr []byte // attribute selector without brackets, which is renamed as a whole
+	for _, val := range values {
 		if !inAttr {
 			if val.TokenType == css.IdentToken {
 				if !isClass {
 					parse.ToLower(val.Data)
+				} else if c.m != nil && c.m.Names != nil && !c.keepNames {
+					val.Data = c.m.Names.Class(unescapeIdent(val.Data))
 				}
 				isClass = false
+			} else if val.TokenType == css.HashToken && c.m != nil && c.m.Names != nil && !c.keepNames {
+				val.Data = append([]byte{'#'}, c.m.Names.ID(unescapeIdent(val.Data[1:]))...)
+			} else if val.TokenType == css.CustomPropertyNameToken && c.renameProperties() {
+				val.Data = c.renameProperty(val.Data) // such as ::view-transition-group(--main)
 			} else if val.TokenType == css.DelimToken && val.Data[0] == '.' {
 				isClass = true
 			} else if val.TokenType == css.LeftBracketToken {
 				inAttr = true
+				attr = attr[:0]
 			}
 		} else {
 			if val.TokenType == css.StringToken && len(val.Data) > 2 {
-				s := val.Data[1 : len(val.Data)-1]
-				if css.IsIdent(s) {
-					if _, err := c.w.Write(s); err != nil {
-						return err
-					}
+				if s := val.Data[1 : len(val.Data)-1]; css.IsIdent(s) {
+					attr = append(attr, s...)
 					continue
 				}
 			} else if val.TokenType == css.RightBracketToken {
-				inAttr = false
-			} else if val.TokenType == css.IdentToken && len(val.Data) == 1 && (val.Data[0] == 'i' || val.Data[0] == 'I') {
-				if _, err := c.w.Write(spaceBytes); err != nil {
+				if c.m != nil && c.m.Names != nil && !c.keepNames {
+					attr = renameAttrSelector(c.m.Names, attr)
+				}
+				if _, err := c.w.Write(attr); err != nil {
 					return err
 				}
+				inAttr = false
+			} else if val.TokenType == css.IdentToken && len(val.Data) == 1 && (val.Data[0] == 'i' || val.Data[0] == 'I') {
+				attr = append(attr, ' ')
+			}
+			if inAttr {
+				attr = append(attr, val.Data...)
+				continue
 			}
 		}
 		if _, err := c.w.Write(val.Data); err != nil {
 			return err
 		}
 	}
+	if inAttr {
+		if _, err := c.w.Write(attr); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -310,7 +574,7 @@ func (c *cssMinifier) minifyDeclaration(property []byte, components []css.Token)
 			j := i + 1
 			level := 0
 			for ; j < len(components); j++ {
-				if components[j].TokenType == css.LeftParenthesisToken {
+				if components[j].TokenType == css.LeftParenthesisToken || components[j].TokenType == css.FunctionToken {
 					level++
 				} else if components[j].TokenType == css.RightParenthesisToken {
 					if level == 0 {
@@ -362,6 +626,9 @@ func (c *cssMinifier) minifyDeclaration(property []byte, components []css.Token)
 	}
 
 	for i := range values {
+		if values[i].TokenType == css.FunctionToken && isMathFunction(values[i].Data) {
+			values[i] = c.minifyMath(values[i])
+		}
 		values[i].TokenType, values[i].Data = c.shortenToken(prop, values[i].TokenType, values[i].Data)
 	}
 	if len(values) > 0 {
@@ -491,7 +758,7 @@ func (c *cssMinifier) minifyProperty(prop css.Hash, values []Token) []Token {
 				values[0].Data = []byte("700")
 			}
 		}
-	case css.Margin, css.Padding, css.Border_Width:
+	case css.Margin, css.Padding, css.Border_Width, css.Border_Style, css.Border_Color:
 		switch len(values) {
 		case 2:
 			if values[0].Equal(values[1]) {
@@ -525,6 +792,21 @@ func (c *cssMinifier) minifyProperty(prop css.Hash, values []Token) []Token {
 		if len(values) == 0 {
 			values = []Token{{css.IdentToken, []byte("none"), nil}}
 		}
+	case css.List_Style:
+		nones := 0
+		for i := 0; i < len(values); i++ {
+			if values[i].TokenType == css.IdentToken {
+				if bytes.Equal(values[i].Data, []byte("outside")) && 1 < len(values) {
+					values = append(values[:i], values[i+1:]...)
+					i--
+				} else if css.ToHash(values[i].Data) == css.None {
+					if nones++; nones == 2 {
+						values = append(values[:i], values[i+1:]...)
+						i--
+					}
+				}
+			}
+		}
 	case css.Outline:
 		for i := 0; i < len(values); i++ {
 			if values[i].TokenType == css.IdentToken {
@@ -580,7 +862,8 @@ func (c *cssMinifier) minifyProperty(prop css.Hash, values []Token) []Token {
 						}
 						continue
 					}
-				} else if h == css.None || h == css.Scroll || h == css.Transparent {
+				}
+				if h == css.None || h == css.Scroll || h == css.Transparent || h == css.Repeat {
 					values = append(values[:i], values[i+1:]...)
 					i--
 					continue
@@ -744,84 +1027,9 @@ func (c *cssMinifier) minifyColorAsHex(rgba [3]byte) error {
 func (c *cssMinifier) minifyFunction(values []css.Token) error {
 	if n := len(values); n > 2 {
 		fun := css.ToHash(values[0].Data[0 : len(values[0].Data)-1])
-		if fun == css.Rgb || fun == css.Rgba || fun == css.Hsl || fun == css.Hsla {
-			valid := true
-			vals := make([]*css.Token, 0, 4)
-			for i, value := range values[1 : n-1] {
-				numeric := value.TokenType == css.NumberToken || value.TokenType == css.PercentageToken
-				separator := value.TokenType == css.CommaToken || i != 5 && value.TokenType == css.WhitespaceToken || i == 5 && value.TokenType == css.DelimToken && value.Data[0] == '/'
-				if i%2 == 0 && !numeric || i%2 == 1 && !separator {
-					valid = false
-				} else if numeric {
-					vals = append(vals, &values[i+1])
-				}
-			}
-
-			if valid {
-				for _, val := range vals {
-					val.TokenType, val.Data = c.shortenToken(0, val.TokenType, val.Data)
-				}
-
-				a := byte(255)
-				if len(vals) == 4 {
-					d, _ := strconv.ParseFloat(string(values[7].Data), 32) // can never fail because if valid == true than this is a NumberToken or PercentageToken
-					if d < minify.Epsilon {                                // zero or less
-						_, err := c.w.Write(transparentBytes)
-						return err
-					} else if d >= 1.0 {
-						values = values[:7]
-					} else {
-						a = byte(d*255.0 + 0.5)
-					}
-				}
-
-				if a == 255 { // only minify color if fully opaque
-					if (fun == css.Rgb || fun == css.Rgba) && (len(vals) == 3 || len(vals) == 4) {
-						rgb := [3]byte{}
-
-						for j, val := range vals[:3] {
-							if val.TokenType == css.NumberToken {
-								d, _ := strconv.ParseInt(string(val.Data), 10, 32)
-								if d < 0 {
-									d = 0
-								} else if d > 255 {
-									d = 255
-								}
-								rgb[j] = byte(d)
-							} else if val.TokenType == css.PercentageToken {
-								d, _ := strconv.ParseFloat(string(val.Data[:len(val.Data)-1]), 32)
-								if d < 0.0 {
-									d = 0.0
-								} else if d > 100.0 {
-									d = 100.0
-								}
-								rgb[j] = byte((d / 100.0 * 255.0) + 0.5)
-							}
-						}
-						return c.minifyColorAsHex(rgb)
-					} else if (fun == css.Hsl || fun == css.Hsla) && (len(vals) == 3 || len(vals) == 4) && vals[0].TokenType == css.NumberToken && vals[1].TokenType == css.PercentageToken && vals[2].TokenType == css.PercentageToken {
-						h, _ := strconv.ParseFloat(string(vals[0].Data), 32)
-						s, _ := strconv.ParseFloat(string(vals[1].Data[:len(vals[1].Data)-1]), 32)
-						l, _ := strconv.ParseFloat(string(vals[2].Data[:len(vals[2].Data)-1]), 32)
-						for h > 360.0 {
-							h -= 360.0
-						}
-						if s < 0.0 {
-							s = 0.0
-						} else if s > 100.0 {
-							s = 100.0
-						}
-						if l < 0.0 {
-							l = 0.0
-						} else if l > 100.0 {
-							l = 100.0
-						}
-
-						r, g, b := css.HSL2RGB(h/360.0, s/100.0, l/100.0)
-						rgb := [3]byte{byte((r * 255.0) + 0.5), byte((g * 255.0) + 0.5), byte((b * 255.0) + 0.5)}
-						return c.minifyColorAsHex(rgb)
-					}
-				}
+		if isColorFunction(values[0].Data) {
+			if ok, err := c.minifyColor(values); ok || err != nil {
+				return err
 			}
 		} else if fun == css.Local && n == 3 {
 			data := values[1].Data
@@ -835,8 +1043,40 @@ func (c *cssMinifier) minifyFunction(values []css.Token) error {
 		}
 	}
 
-	for _, value := range values {
-		if _, err := c.w.Write(value.Data); err != nil {
+	for i := 0; i < len(values); i++ {
+		if 0 < i && values[i].TokenType == css.FunctionToken && isMathFunction(values[i].Data) {
+			if end := matchingParenthesis(values, i); end != -1 {
+				// math functions in arguments, such as translate(calc(10px + 5px))
+				t := c.minifyMath(Token{css.FunctionToken, values[i].Data, values[i : end+1]})
+				if t.TokenType != css.FunctionToken {
+					t.TokenType, t.Data = c.shortenToken(0, t.TokenType, t.Data)
+				}
+				if t.Components == nil {
+					if _, err := c.w.Write(t.Data); err != nil {
+						return err
+					}
+				} else {
+					for _, value := range t.Components {
+						if _, err := c.w.Write(value.Data); err != nil {
+							return err
+						}
+					}
+				}
+				i = end
+				continue
+			}
+		} else if 0 < i && values[i].TokenType == css.FunctionToken && isColorFunction(values[i].Data) {
+			// colors in arguments, such as linear-gradient(rgba(0,0,0,.5),#000)
+			if end := matchingParenthesis(values, i); end != -1 {
+				if ok, err := c.minifyColor(values[i : end+1]); err != nil {
+					return err
+				} else if ok {
+					i = end
+					continue
+				}
+			}
+		}
+		if _, err := c.w.Write(values[i].Data); err != nil {
 			return err
 		}
 	}
@@ -857,7 +1097,7 @@ func (c *cssMinifier) shortenToken(prop css.Hash, tt css.TokenType, data []byte)
 		}
 		dim := data[n:]
 		parse.ToLower(dim)
-		if !c.o.KeepCSS2 {
+		if !c.keepCSS2 {
 			data = minify.Number(data[:n], c.o.Decimals)
 		} else {
 			data = minify.Decimal(data[:n], c.o.Decimals) // don't use exponents
@@ -918,6 +1158,9 @@ func (c *cssMinifier) shortenToken(prop css.Hash, tt css.TokenType, data []byte)
 				data = append(append(append([]byte("url("), delim), uri...), delim, ')')
 			}
 		}
+		if c.m != nil && c.m.Names != nil {
+			data = renameURLFragment(c.m.Names, data)
+		}
 	}
 	return tt, data
 }
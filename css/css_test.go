@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	"github.com/tdewolff/minify/v2"
@@ -35,8 +37,29 @@ func TestCSS(t *testing.T) {
 		{"@MEDIA all{}", "@media all{}"},
 		{"@media only screen and (max-width : 800px){}", "@media only screen and (max-width:800px){}"},
 		{"@media (-webkit-min-device-pixel-ratio:1.5),(min-resolution:1.5dppx){}", "@media(-webkit-min-device-pixel-ratio:1.5),(min-resolution:1.5dppx){}"},
+		{"@media all and (min-width:0.50em) , print{}", "@media(min-width:.5em),print{}"},
+		{"@media print , ALL AND (MIN-WIDTH:+100.0PX){}", "@media print,(min-width:100px){}"},
+		{"@media not all and (monochrome){}", "@media not all and (monochrome){}"},
+		{"@media all{}", "@media all{}"},
+		{"@media ( 400px <= width <= 700px ){}", "@media(400px<=width<=700px){}"},
+		{"@media (min-width:0px) and (--Custom){}", "@media(min-width:0) and (--Custom){}"},
+		{"@supports ( display : grid ) and (not (display:inline-grid)){}", "@supports(display:grid) and (not (display:inline-grid)){}"},
+		{"@supports (width:10.0px) and selector( a:nth-child(2n+1) ){}", "@supports(width:10px) and selector(a:nth-child(2n+1)){}"},
+		{"@media screen{a{color:red}}@media screen{b{color:red}}", "@media screen{a{color:red}b{color:red}}"},
+		{"@media screen{a{color:red}}@media print{b{color:red}}@media print{c{color:red}}", "@media screen{a{color:red}}@media print{b{color:red}c{color:red}}"},
+		{"@media screen{@media (min-width:1px){a{color:red}}@media (min-width:1px){b{color:red}}}", "@media screen{@media(min-width:1px){a{color:red}b{color:red}}}"},
+		{"@media screen{a{color:red}}b{color:red}@media screen{c{color:red}}", "@media screen{a{color:red}}b{color:red}@media screen{c{color:red}}"},
+		{"@supports (display:grid){a{color:red}}@supports (display:grid){b{color:red}}", "@supports(display:grid){a{color:red}}@supports(display:grid){b{color:red}}"},
 		{"[class^=icon-] i[class^=icon-],i[class*=\" icon-\"]{x:y}", "[class^=icon-] i[class^=icon-],i[class*=\" icon-\"]{x:y}"},
 		{"html{line-height:1;}html{line-height:1;}", "html{line-height:1}html{line-height:1}"},
+		{"li:nth-child(2n+1),li:nth-child( 2n + 0 ),li:NTH-CHILD(even),li:nth-child(2n-1){x:y}", "li:nth-child(odd),li:nth-child(2n){x:y}"},
+		{"li:nth-child(1),li:nth-last-child(-n+1),p:nth-of-type(0n+1),p:nth-last-of-type(1){x:y}", "li:first-child,li:last-child,p:first-of-type,p:last-of-type{x:y}"},
+		{"li:nth-child(+1n+3),li:nth-last-child(-n+3),li:nth-child(1 of .a),li:nth-child( 2n+1 of .a ){x:y}", "li:nth-child(n+3),li:nth-last-child(-n+3),li:nth-child(1 of .a),li:nth-child(odd of .a){x:y}"},
+		{"*.a,*#b,*[c],*:hover,* .d,ns|*.e,*{x:y}", ".a,#b,[c],:hover,* .d,ns|*.e,*{x:y}"},
+		{"a::before,a::AFTER,p::first-line,p::first-letter,p::selection,::placeholder{x:y}", "a:before,a:after,p:first-line,p:first-letter,p::selection,::placeholder{x:y}"},
+		{"a,b,a,*.c,.c{x:y}", "a,b,.c{x:y}"},
+		{"col || td,:is( .a , .b ),:not( .c ){x:y}", "col||td,:is(.a,.b),:not(.c){x:y}"},
+		{":is(.a,.b),:is(.a,.b),[x=','],:is(.a,:not(.b,.c)){x:y}", ":is(.a,.b),[x=','],:is(.a,:not(.b,.c)){x:y}"},
 		{"a { b: 1", "a{b:1}"},
 
 		{":root { --custom-variable:0px; }", ":root{--custom-variable:0px}"},
@@ -94,19 +117,47 @@ func TestCSSInline(t *testing.T) {
 		{"color: rgb(100%,100%,100%);", "color:#fff"},
 		{"color: rgba(255,0,0,1);", "color:red"},
 		{"color: rgba(255,0,0,2);", "color:red"},
-		{"color: rgba(255,0,0,0.5);", "color:rgba(255,0,0,.5)"}, // {"color: rgba(255,0,0,0.5);", "color:#ff000080"},
-		{"color: rgba(255,0,0,-1);", "color:transparent"}, // {"color: rgba(255,0,0,-1);", "color:#0000"},
-		// {"color: rgba(0%,15%,25%,0.2);", "color:rgba(0,15%,25%,.2)"}, TODO: zero percentage to zero // {"color: rgba(0%,15%,25%,0.2);", "color:#00264033"},
-		{"color: rgba(0,0,0,0.5);", "color:rgba(0,0,0,.5)"}, // {"color: rgba(0,0,0,0.5);", "color:#00000080"},
-		{"color: rgba(0,0,0,0.264705882);", "color:rgba(0,0,0,.264705882)"}, // {"color: rgba(0,0,0,0.264705882);", "color:#0004"},
+		{"color: rgba(255,0,0,0.5);", "color:#ff000080"},
+		{"color: rgba(255,0,0,-1);", "color:#0000"},
+		{"color: rgba(0%,15%,25%,0.2);", "color:#00264033"},
+		{"color: rgba(0,0,0,0.5);", "color:#00000080"},
+		{"color: rgba(0,0,0,0.264705882);", "color:#00000043"},
 		{"color: rgb(255 0 0 / 1);", "color:red"},
-		{"color: hsla(5,0%,10%,0.75);", "color:hsla(5,0%,10%,.75)"}, // {"color: hsla(5,0%,10%,0.75);", "color:#1a1a1abf"},
+		{"color: hsla(5,0%,10%,0.75);", "color:#1a1a1abf"},
 		{"color: hsl(0,100%,50%);", "color:red"},
 		{"color: hsla(1,2%,3%,1);", "color:#080807"},
-		{"color: hsla(1,2%,3%,0);", "color:transparent"}, // {"color: hsla(1,2%,3%,0);", "color:#0000"},
+		{"color: hsla(1,2%,3%,0);", "color:#0000"},
 		{"color: hsl(48,100%,50%);", "color:#fc0"},
 		{"color: hsl(0 100% 50% / 1);", "color:red"},
 		{"color: hsl(400, 150%, 150%, 2);", "color:#fff"},
+		{"color: rgb(0 0 0 / 50%);", "color:#00000080"},
+		{"color: rgb(10.5 20 30);", "color:#0b141e"},
+		{"color: rgb(0 0 0 / none);", "color:rgb(0 0 0/none)"},
+		{"color: rgb(none 0 0);", "color:rgb(none 0 0)"},
+		{"color: rgb(var(--r) 0 0);", "color:rgb(var(--r) 0 0)"},
+		{"color: hsl(0.5turn 100 50);", "color:#0ff"},
+		{"color: hwb(0 0% 0%);", "color:red"},
+		{"color: hwb(120 20% 30%);", "color:#33b333"},
+		{"color: hwb(0 60% 60%);", "color:gray"},
+		{"color: hwb(90deg 0% 0% / .5);", "color:#80ff0080"},
+		{"color: lab(100% 0 0);", "color:#fff"},
+		{"color: LAB(50% 0 0);", "color:#777"},
+		{"color: lab(54.29% 80.82 69.9);", "color:red"},
+		{"color: lab(50% 150 -150);", "color:lab(50% 150 -150)"},
+		{"color: lch(54.29% 106.84 40.86);", "color:red"},
+		{"color: lch(50% 0 none);", "color:lch(50% 0 none)"},
+		{"color: lch(50% 200 30 / 0.5);", "color:lch(50% 200 30/.5)"},
+		{"color: oklab(1 0 0);", "color:#fff"},
+		{"color: oklch(62.8% 0.2577 29.23);", "color:red"},
+		{"color: oklch(0.5 0.1 120 / 25%);", "color:#5c6b2140"},
+		{"color: oklch(70% 0.4 150);", "color:oklch(70% .4 150)"},
+		{"color: color(srgb 1 0 0);", "color:red"},
+		{"color: color(srgb 100% 50% 0 / .5);", "color:#ff800080"},
+		{"color: color(xyz 0.9505 1 1.089);", "color:#fff"},
+		{"color: color(display-p3 0 0 0);", "color:#000"},
+		{"color: color(display-p3 1 0 0);", "color:color(display-p3 1 0 0)"},
+		{"color: color(rec2020 1 0 0);", "color:color(rec2020 1 0 0)"},
+		{"background: linear-gradient(rgba(0,0,0,.5),hsl(0 100% 50%));", "background:linear-gradient(#00000080,red)"},
 		{"background-position:top", "background-position:top"},
 		{"background-position:bottom", "background-position:bottom"},
 		{"background-position:center", "background-position:50%"},
@@ -141,6 +192,12 @@ func TestCSSInline(t *testing.T) {
 		{"background:0% 0%", "background:0 0"},
 		{"background:left top", "background:0 0"},
 		{"background:no-repeat repeat", "background:repeat-y"},
+		{"background:url(x) repeat red", "background:url(x) red"},
+		{"list-style:none outside none", "list-style:none"},
+		{"list-style:square outside", "list-style:square"},
+		{"list-style:outside", "list-style:outside"},
+		{"border-color:red red red red", "border-color:red"},
+		{"border-style:solid dashed solid dashed", "border-style:solid dashed"},
 		{"background:top right", "background:100% 0"},
 		{"background:bottom left", "background:0 100%"},
 		{"font-weight: bold; font-weight: normal;", "font-weight:700;font-weight:400"},
@@ -211,11 +268,51 @@ func TestCSSInline(t *testing.T) {
 
 		{"any:0deg 0s 0ms 0dpi 0dpcm 0dppx 0hz 0khz", "any:0 0s 0ms 0dpi 0dpcm 0dppx 0hz 0khz"},
 		{"width:calc(0%-0px)", "width:calc(0%-0px)"},
-		{"margin:calc(10px) calc(20px)", "margin:calc(10px) calc(20px)"},
+		{"margin:calc(10px) calc(20px)", "margin:10px 20px"},
+		{"width:calc(10px + 5px)", "width:15px"},
+		{"width:CALC(1PX + 2PX)", "width:3px"},
+		{"width:calc( 100% - 10px )", "width:calc(100% - 10px)"},
+		{"width:calc(100% - (10px + 5px))", "width:calc(100% - 15px)"},
+		{"width:calc(calc(100% - 10px) - 5px)", "width:calc(100% - 15px)"},
+		{"width:calc(100% - 2 * (5px + 5px))", "width:calc(100% - 20px)"},
+		{"width:calc(100% - -10px)", "width:calc(100% + 10px)"},
+		{"width:calc(var(--a) + 10px + 5px)", "width:calc(var(--a) + 15px)"},
+		{"width:calc(var(--a) / 2 * 4)", "width:calc(2*var(--a))"},
+		{"width:calc(2 * (100% - 10px))", "width:calc(2*(100% - 10px))"},
+		{"width:calc(0.1px + 0.2px)", "width:.3px"},
+		{"width:calc(10px - 10px)", "width:0"},
+		{"width:calc(10px - 20px)", "width:calc(-10px)"},
+		{"z-index:calc(4 / 2)", "z-index:2"},
+		{"z-index:calc(5 / 2)", "z-index:calc(5/2)"},
+		{"z-index:calc(15 / 2)", "z-index:calc(7.5)"},
+		{"width:calc(1 / 3)", "width:calc(1/3)"},
+		{"width:calc(100% - 1px / 3)", "width:calc(100% - 1px/3)"},
+		{"width:calc(1 / 3 * 3px)", "width:1px"},
+		{"width:calc(0.5px / 4)", "width:.125px"},
+		{"width:calc((10px))", "width:10px"},
+		{"width:calc(min(10px, 5%))", "width:min(10px,5%)"},
+		{"width:min(calc(10px + 5%), 20px)", "width:min(10px + 5%,20px)"},
+		{"width:max(10px, 20px, 5px)", "width:20px"},
+		{"width:clamp(10px, 5px, 100px)", "width:10px"},
+		{"width:clamp(10px, 50%, 100px)", "width:clamp(10px,50%,100px)"},
+		{"transform:translate(calc(10px + 5px), 0)", "transform:translate(15px,0)"},
+		{"width:calc(100vw - 100%)", "width:calc(100vw - 100%)"},
+		{"width:calc(var(--a))", "width:calc(var(--a))"},
+		{"width:calc(1px / 0)", "width:calc(1px/0)"},
+		{"width:calc(10px * 2px)", "width:calc(10px*2px)"},
+		{"width:calc(1px+2px)", "width:calc(1px+2px)"},
 		{"border-left:0 none", "border-left:0"},
 		{"--custom-variable:0px;", "--custom-variable:0px"},
-		{"--foo: if(x > 5) this.width = 10", "--foo: if(x > 5) this.width = 10"},
+		{"--foo: if(x > 5) this.width = 10", "--foo:if(x > 5) this.width = 10"},
+		{"--foo: 0.50  ,  1.0px /* comment */ calc( 1px + 2.50% ) ;", "--foo:.5,1.0px calc(1px + 2.5%)"},
+		{"--foo: 10.0 1e3 01 1/**/2", "--foo:10.0 1e3 1 1/**/2"},
+		{"--foo: 'a  b'  url( x.png )", "--foo:'a  b' url( x.png )"},
 		{"--foo: ;", "--foo: "},
+		{"--x:1+2", "--x:1+2"},
+		{"--x:1px+2px", "--x:1px+2px"},
+		{"--x:a+.5", "--x:a+.5"},
+		{"--x:a+0.50 1-0.50 1-0.0", "--x:a+.5 1-.5 1-0.0"},
+		{"--x:1 -0.50px +0.5%", "--x:1 -.5px +.5%"},
 		{"color=blue;", "color=blue"},
 
 		// case sensitivity
@@ -261,6 +358,15 @@ func TestCSSKeepCSS2(t *testing.T) {
 		expected string
 	}{
 		{`margin:5000em`, `margin:5000em`},
+		{`color:rgba(255,0,0,.5)`, `color:rgba(255,0,0,.5)`},
+		{`color:rgba(0,0,0,0)`, `color:transparent`},
+		{`color:rgb(0 0 0 / 50%)`, `color:rgb(0 0 0/.5)`},
+		{`color:rgb(0 0 0 / 100%)`, `color:#000`},
+		{`color:hwb(90deg 0% 0% / .5)`, `color:hwb(90deg 0% 0%/.5)`},
+		{`color:lab(54.29% 80.82 69.9)`, `color:red`},
+		{`color:oklch(0.5 0.1 120 / 25%)`, `color:oklch(.5 .1 120/25%)`},
+		{`color:color(srgb 100% 50% 0 / .5)`, `color:color(srgb 100% 50% 0/.5)`},
+		{`background:linear-gradient(rgba(0,0,0,.5),hsl(0 100% 50%))`, `background:linear-gradient(rgba(0,0,0,.5),red)`},
 	}
 
 	m := minify.New()
@@ -276,6 +382,566 @@ func TestCSSKeepCSS2(t *testing.T) {
 	}
 }
 
+func TestCSSMergeRules(t *testing.T) {
+	tests := []struct {
+		css      string
+		expected string
+	}{
+		{`a{color:red}a{margin:0}`, `a{color:red;margin:0}`},
+		{`a{color:red}b{color:red}`, `a,b{color:red}`},
+		{`a,b{color:red}b,a{margin:0}`, `a,b{color:red;margin:0}`},
+		{`a{color:red}a{color:red}`, `a{color:red}`},
+		{`a{color:red}b{margin:0}a{padding:0}`, `a{color:red;padding:0}b{margin:0}`},
+		{`a{color:red}b{margin:0}c{color:red}`, `a,c{color:red}b{margin:0}`},
+		{`a{color:red}b{color:blue}a{color:green}`, `a{color:red}b{color:blue}a{color:green}`},
+		{`a{margin:0}b{margin-top:1px}a{margin-top:0}`, `a{margin:0}b{margin-top:1px}a{margin-top:0}`},
+		{`.x{color:red}.y{height:10px}.x{block-size:5px}`, `.x{color:red}.y{height:10px}.x{block-size:5px}`},
+		{`.x{color:red}.y{width:10px}.x{inline-size:5px}`, `.x{color:red}.y{width:10px}.x{inline-size:5px}`},
+		{`.x{color:red}.y{min-height:10px}.x{max-block-size:5px}`, `.x{color:red}.y{min-height:10px}.x{max-block-size:5px}`},
+		{`.x{color:red}.y{margin-top:1px}.x{margin-block:0}`, `.x{color:red}.y{margin-top:1px}.x{margin-block:0}`},
+		{`.x{color:red}.y{padding-left:1px}.x{padding-inline-start:0}`, `.x{color:red}.y{padding-left:1px}.x{padding-inline-start:0}`},
+		{`.x{color:red}.y{top:1px}.x{inset-block:0}`, `.x{color:red}.y{top:1px}.x{inset-block:0}`},
+		{`a{font:12px x}b{line-height:1}a{line-height:2}`, `a{font:12px x}b{line-height:1}a{line-height:2}`},
+		{`a{top:0}b{inset:0}a{left:0}`, `a{top:0}b{inset:0}a{left:0}`},
+		{`a{color:red}b{all:unset}a{margin:0}`, `a{color:red}b{all:unset}a{margin:0}`},
+		{`a{--x:1}b{--x:2}a{--y:3}`, `a{--x:1;--y:3}b{--x:2}`},
+		{`a::-moz-selection{color:red}a::selection{color:red}`, `a::-moz-selection{color:red}a::selection{color:red}`},
+		{`a:hover{color:red}a:focus-visible{color:red}`, `a:hover{color:red}a:focus-visible{color:red}`},
+		{`.a\:b{color:red}c{color:red}`, `.a\:b,c{color:red}`},
+		{`a{color:red}@media screen{a{margin:0}a{padding:0}}a{margin:0}`, `a{color:red}@media screen{a{margin:0;padding:0}}a{margin:0}`},
+		{`@media screen{a{color:red}}@media screen{a{margin:0}}`, `@media screen{a{color:red;margin:0}}`},
+		{`a{color:red}/*! x */a{margin:0}`, `a{color:red}/*!x*/a{margin:0}`},
+		{`@keyframes x{from{opacity:0}to{opacity:0}}`, `@keyframes x{from,to{opacity:0}}`},
+		{`a{color:red;foo}a{margin:0}`, `a{color:red;foo}a{margin:0}`},
+	}
+
+	m := minify.New()
+	cssMinifier := &Minifier{Decimals: -1, MergeRules: true}
+	for _, tt := range tests {
+		t.Run(tt.css, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.css)
+			w := &bytes.Buffer{}
+			err := cssMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.css, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestCSSFoldShorthands(t *testing.T) {
+	tests := []struct {
+		css      string
+		expected string
+	}{
+		{`a{margin-top:0;margin-right:0;margin-bottom:0;margin-left:0}`, `a{margin:0}`},
+		{`a{color:red;padding-top:1px;padding-right:2px;padding-bottom:1px;padding-left:2px}`, `a{color:red;padding:1px 2px}`},
+		{`a{margin-top:1px!important;margin-right:2px!important;margin-bottom:1px!important;margin-left:2px!important}`, `a{margin:1px 2px!important}`},
+		{`a{border-top-width:1px;border-top-style:solid;border-top-color:#ff0000}`, `a{border-top:1px solid red}`},
+		{`a{border-top-width:1px;border-right-width:1px;border-bottom-width:1px;border-left-width:1px;border-radius:2px}`, `a{border-width:1px;border-radius:2px}`},
+		{`a{font-style:italic;font-variant:normal;font-weight:bold;font-stretch:normal;font-size:12px;line-height:1.5;font-family:"Helvetica Neue",serif}`, `a{font:italic 700 12px/1.5 helvetica neue,serif}`},
+		{`a{list-style-type:none;list-style-position:outside;list-style-image:none}`, `a{list-style:none}`},
+		{`a{background-image:url(x);background-position:0 0;background-size:auto;background-repeat:no-repeat;background-attachment:scroll;background-origin:padding-box;background-clip:border-box;background-color:red}`, `a{background:url(x) no-repeat red}`},
+		{`@media screen{a{margin-top:0;margin-right:0;margin-bottom:0;margin-left:0}}`, `@media screen{a{margin:0}}`},
+
+		// not all longhands, or declared more than once
+		{`a{margin-top:0;margin-right:0;margin-bottom:0}`, `a{margin-top:0;margin-right:0;margin-bottom:0}`},
+		{`a{margin-top:0;margin-top:calc(1px);margin-right:0;margin-bottom:0;margin-left:0}`, `a{margin-top:0;margin-top:1px;margin-right:0;margin-bottom:0;margin-left:0}`},
+		{`a{margin-top:0;margin-right:0;margin-bottom:0;margin-left:0;margin-inline-start:1px}`, `a{margin-top:0;margin-right:0;margin-bottom:0;margin-left:0;margin-inline-start:1px}`},
+		{`a{margin:1px;margin-top:0;margin-right:0;margin-bottom:0;margin-left:0}`, `a{margin:1px;margin-top:0;margin-right:0;margin-bottom:0;margin-left:0}`},
+		{`a{font-kerning:none;font-style:italic;font-variant:normal;font-weight:700;font-stretch:normal;font-size:12px;line-height:1.5;font-family:serif}`, `a{font-kerning:none;font-style:italic;font-variant:normal;font-weight:700;font-stretch:normal;font-size:12px;line-height:1.5;font-family:serif}`},
+
+		// values that cannot be used in the shorthand
+		{`a{margin-top:0!important;margin-right:0;margin-bottom:0;margin-left:0}`, `a{margin-top:0!important;margin-right:0;margin-bottom:0;margin-left:0}`},
+		{`a{margin-top:var(--x);margin-right:0;margin-bottom:0;margin-left:0}`, `a{margin-top:var(--x);margin-right:0;margin-bottom:0;margin-left:0}`},
+		{`a{margin-top:inherit;margin-right:0;margin-bottom:0;margin-left:0}`, `a{margin-top:inherit;margin-right:0;margin-bottom:0;margin-left:0}`},
+		{`a{font-style:italic;font-variant:all-small-caps;font-weight:700;font-stretch:normal;font-size:12px;line-height:1.5;font-family:serif}`, `a{font-style:italic;font-variant:all-small-caps;font-weight:700;font-stretch:normal;font-size:12px;line-height:1.5;font-family:serif}`},
+	}
+
+	m := minify.New()
+	cssMinifier := &Minifier{Decimals: -1, FoldShorthands: true}
+	for _, tt := range tests {
+		t.Run(tt.css, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.css)
+			w := &bytes.Buffer{}
+			err := cssMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.css, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestCSSRemoveOverridden(t *testing.T) {
+	tests := []struct {
+		css      string
+		expected string
+	}{
+		{`a{color:red;color:blue}`, `a{color:blue}`},
+		{`a{color:red;margin:0;color:red}`, `a{margin:0;color:red}`},
+		{`a{margin-top:1px;padding:0;margin:0}`, `a{padding:0;margin:0}`},
+		{`a{border-top-color:red;border:0}`, `a{border:0}`},
+		{`a{background-color:red;background:url(x)}`, `a{background:url(x)}`},
+		{`a{color:red;color:blue!important}`, `a{color:blue!important}`},
+		{`a{--x:1px;--x:var(--y)}`, `a{--x:var(--y)}`},
+		{`@media screen{a{color:red;color:blue}}`, `@media screen{a{color:blue}}`},
+		{`@font-face{font-family:a;font-family:b}`, `@font-face{font-family:b}`},
+		{`a{display:grid;display:block}`, `a{display:block}`},
+		{`a{width:fit-content;width:auto}`, `a{width:auto}`},
+
+		// not overridden
+		{`a{color:red!important;color:blue}`, `a{color:red!important;color:blue}`},
+		{`a{margin:0;margin-top:1px}`, `a{margin:0;margin-top:1px}`},
+		{`a{margin-left:0;margin-inline-start:1px}`, `a{margin-left:0;margin-inline-start:1px}`},
+		{`a{-webkit-transition:none;transition:none}`, `a{-webkit-transition:none;transition:none}`},
+		{`a{color:red}a{color:blue}`, `a{color:red}a{color:blue}`},
+
+		// fallbacks
+		{`a{display:-webkit-box;display:-ms-flexbox;display:flex}`, `a{display:-webkit-box;display:-ms-flexbox;display:flex}`},
+		{`a{color:red;color:var(--x)}`, `a{color:red;color:var(--x)}`},
+		{`a{height:500px;height:100vh}`, `a{height:500px;height:100vh}`},
+		{`a{width:100px;width:calc(100% - 10px)}`, `a{width:100px;width:calc(100% - 10px)}`},
+		{`abbr[title]{text-decoration:underline;text-decoration:underline dotted}`, `abbr[title]{text-decoration:underline;text-decoration:underline dotted}`},
+		{`a{cursor:pointer;cursor:hand}`, `a{cursor:pointer;cursor:hand}`},
+		{`a{display:block;display:grid}`, `a{display:block;display:grid}`},
+		{`a{position:relative;position:sticky}`, `a{position:relative;position:sticky}`},
+		{`a{width:100px;width:fit-content}`, `a{width:100px;width:fit-content}`},
+		{`a{overflow:hidden;overflow:clip}`, `a{overflow:hidden;overflow:clip}`},
+		{`a{overflow-x:hidden;overflow:clip}`, `a{overflow-x:hidden;overflow:clip}`},
+
+		// Bootstrap
+		{`.dropdown-menu{border:1px solid #ccc;border:1px solid rgba(0,0,0,.15)}`, `.dropdown-menu{border:1px solid #ccc;border:1px solid #00000026}`},
+		{`a:focus{outline:thin dotted;outline:5px auto -webkit-focus-ring-color}`, `a:focus{outline:thin dotted;outline:5px auto -webkit-focus-ring-color}`},
+		{`.progress-bar-striped{background-image:-webkit-linear-gradient(45deg,transparent 25%,transparent);background-image:linear-gradient(45deg,transparent 25%,transparent)}`, `.progress-bar-striped{background-image:-webkit-linear-gradient(45deg,transparent 25%,transparent);background-image:linear-gradient(45deg,transparent 25%,transparent)}`},
+		{`.modal-backdrop{background-color:#000\9;background-color:transparent}`, `.modal-backdrop{background-color:#000\9;background-color:transparent}`},
+
+		// Font Awesome
+		{`@font-face{src:url(fa.eot);src:url(fa.eot?#iefix) format("embedded-opentype"),url(fa.woff2) format("woff2")}`, `@font-face{src:url(fa.eot);src:url(fa.eot?#iefix) format("embedded-opentype"),url(fa.woff2) format("woff2")}`},
+	}
+
+	m := minify.New()
+	cssMinifier := &Minifier{Decimals: -1, RemoveOverridden: true}
+	for _, tt := range tests {
+		t.Run(tt.css, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.css)
+			w := &bytes.Buffer{}
+			err := cssMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.css, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestCSSInlineCustomProperties(t *testing.T) {
+	tests := []struct {
+		css      string
+		expected string
+	}{
+		{`:root{--main:#f00;--gap:4px 8px}a{color:var(--main);margin:var(--gap)}`, `a{color:#f00;margin:4px 8px}`},
+		{`:root{--main:red;color:blue}a{color:var(--main,blue)}b{--other:var(--main)}`, `:root{color:blue}a{color:red}b{--other:red}`},
+		{`:root{--w:10}a{width:calc(var(--w)*1px)}`, `a{width:calc(10*1px)}`},
+		{`a{content:"var(--x)"}:root{--x:1}`, `a{content:"var(--x)"}`},
+
+		// kept when overridden, registered, not at the top level, or of a value that changes var()
+		{`:root{--main:red}a{--main:blue;color:var(--main)}`, `:root{--main:red}a{--main:blue;color:var(--main)}`},
+		{`:root{--main:red}@media print{:root{--main:#000}}a{color:var(--main)}`, `:root{--main:red}@media print{:root{--main:#000}}a{color:var(--main)}`},
+		{`@property --main{syntax:'<color>';inherits:true;initial-value:red}:root{--main:blue}a{color:var(--main)}`, `@property --main{syntax:'<color>';inherits:true;initial-value:red}:root{--main:blue}a{color:var(--main)}`},
+		{`@media screen{:root{--main:red}}a{color:var(--main)}`, `@media screen{:root{--main:red}}a{color:var(--main)}`},
+		{`:root,.dark{--main:red}a{color:var(--main)}`, `:root,.dark{--main:red}a{color:var(--main)}`},
+		{`:root{--main:initial}a{color:var(--main,red)}`, `:root{--main:initial}a{color:var(--main,red)}`},
+		{`:root{--main:red!important}a{color:var(--main)}`, `:root{--main:red!important}a{color:var(--main)}`},
+		{`:root{--a:red;--b:var(--a)}a{color:var(--b)}`, `:root{--b:red}a{color:var(--b)}`},
+		{`:root{--w:10}a{width:var(--w)px}`, `:root{--w:10}a{width:var(--w)px}`},
+	}
+
+	m := minify.New()
+	cssMinifier := &Minifier{Decimals: -1, InlineCustomProperties: true}
+	for _, tt := range tests {
+		t.Run(tt.css, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.css)
+			w := &bytes.Buffer{}
+			err := cssMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.css, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestCSSPurge(t *testing.T) {
+	purge := NewPurge()
+	if err := purge.AddHTML(bytes.NewBufferString(`<!DOCTYPE html><div id="main" class="row  col-6"><p class='lead'>x</p><button onclick="this.classList.add('active')"></button></div><script>el.className = "js-open";</script>`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := purge.AddJS(bytes.NewBufferString(`document.getElementById("modal").classList.toggle("in")`)); err != nil {
+		t.Fatal(err)
+	}
+	purge.Safelist = regexp.MustCompile(`^fa-`)
+
+	tests := []struct {
+		css      string
+		expected string
+	}{
+		{`div{color:red}span{color:red}`, `div{color:red}`},
+		{`.row{margin:0}.table{margin:0}`, `.row{margin:0}`},
+		{`#main{margin:0}#footer{margin:0}`, `#main{margin:0}`},
+		{`.row .lead,.table .lead,.row>p{color:red}`, `.row .lead,.row>p{color:red}`},
+		{`.row.col-6:hover{color:red}.row.col-4:hover{color:red}`, `.row.col-6:hover{color:red}`},
+		{`.active,.js-open,.in,#modal{color:red}`, `.active,.js-open,.in,#modal{color:red}`},
+		{`.fa-check:before{content:""}.glyphicon-ok:before{content:""}`, `.fa-check:before{content:""}`},
+		{`DIV,HTML,body{margin:0}`, `div,html,body{margin:0}`},
+		{`@media screen{.table{margin:0}}a{color:red}`, ``},
+		{`@media screen{.row{margin:0}.table{margin:0}}`, `@media screen{.row{margin:0}}`},
+		{`@keyframes x{from{color:red}to{color:blue}}`, `@keyframes x{from{color:red}to{color:blue}}`},
+
+		// selectors that may match
+		{`:not(.table){color:red}`, `:not(.table){color:red}`},
+		{`[class*=col-]{color:red}`, `[class*=col-]{color:red}`},
+		{`*{margin:0}`, `*{margin:0}`},
+		{`.col-xs-1\/2{margin:0}`, `.col-xs-1\/2{margin:0}`},
+	}
+
+	m := minify.New()
+	cssMinifier := &Minifier{Decimals: -1, Purge: purge}
+	for _, tt := range tests {
+		t.Run(tt.css, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.css)
+			w := &bytes.Buffer{}
+			err := cssMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.css, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestCSSInlineImports(t *testing.T) {
+	files := map[string]string{
+		"/site/css/base.css":       `@charset "utf-8";body{margin:0}`,
+		"/site/css/theme.css":      `@import "base.css";a{color:red}`,
+		"/site/css/vendor/a.css":   `.icon{background:url(../../img/icon.png)}@font-face{src:url("fonts/a.woff2") format("woff2")}`,
+		"/site/css/cycle.css":      `@import "main.css";.cycle{color:red}`,
+		"/site/css/remote.css":     `@import "https://example.com/a.css";.remote{color:red}`,
+		"/site/shared/common.css":  `.common{background:url(img/a.png),url(/img/b.png),url(data:image/gif;base64,R0lGODlhAQABAAAAACw=)}`,
+		"/site/css/vendor/b.css":   `@import "../base.css";.b{color:red}`,
+		"/site/css/empty-cond.css": `p{color:red}`,
+		"/site/css/svg.css":        `@namespace svg url(http://www.w3.org/2000/svg);svg|a{color:red}`,
+	}
+	readFile := func(filename string) ([]byte, error) {
+		if b, ok := files[filepath.ToSlash(filename)]; ok {
+			return []byte(b), nil
+		}
+		return nil, os.ErrNotExist
+	}
+
+	tests := []struct {
+		css      string
+		expected string
+	}{
+		{`@import "base.css";a{color:red}`, `body{margin:0}a{color:red}`},
+		{`@import url(theme.css);`, `body{margin:0}a{color:red}`},
+		{`@charset "utf-8";@import 'base.css';`, `@charset "utf-8";body{margin:0}`},
+		{`@import "base.css" screen;`, `@media screen{body{margin:0}}`},
+		{`@import "base.css" (min-width:768px);`, `@media(min-width:768px){body{margin:0}}`},
+		{`@import "base.css" supports(display:grid) screen and (min-width:768px);`, `@media screen and (min-width:768px){@supports(display:grid){body{margin:0}}}`},
+		{`@import "base.css" layer(reset);@import "theme.css" layer;`, `@layer reset{body{margin:0}}@layer{body{margin:0}a{color:red}}`},
+		{`@import "vendor/a.css";`, `.icon{background:url(../img/icon.png)}@font-face{src:url(vendor/fonts/a.woff2) format("woff2")}`},
+		{`@import "vendor/b.css";`, `body{margin:0}.b{color:red}`},
+		{`@import "/shared/common.css";`, `.common{background:url(../shared/img/a.png),url(/img/b.png),url(data:image/gif;base64,R0lGODlhAQABAAAAACw=)}`},
+		{`@import "cycle.css";.main{color:red}`, `.cycle{color:red}.main{color:red}`},
+
+		// imports that cannot be inlined
+		{`@import "missing.css";`, `@import "missing.css"`},
+		{`@import "https://example.com/a.css";@import "base.css";`, `@import "https://example.com/a.css";body{margin:0}`},
+		{`@import "base.css";@import "https://example.com/a.css";`, `@import "base.css";@import "https://example.com/a.css"`},
+		{`@import "remote.css";`, `@import "remote.css"`},
+		{`a{color:red}@import "base.css";`, `a{color:red}@import "base.css"`},
+		{`@import "svg.css";a{color:red}`, `@import "svg.css";a{color:red}`},
+		{`@import "base.css";@namespace svg url(http://www.w3.org/2000/svg);svg|a{color:red}`, `@import "base.css";@namespace svg url(http://www.w3.org/2000/svg);svg|a{color:red}`},
+	}
+
+	m := minify.New()
+	cssMinifier := &Minifier{Decimals: -1, InlineImports: true, BaseDir: "/site", ReadFile: readFile}
+	for _, tt := range tests {
+		t.Run(tt.css, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.css)
+			w := &bytes.Buffer{}
+			err := cssMinifier.Minify(m, w, r, map[string]string{"filename": "/site/css/main.css"})
+			test.Minify(t, tt.css, err, w.String(), tt.expected)
+		})
+	}
+
+	// stylesheets without filename, such as from stdin, cannot import themselves
+	files["/site/<stdin>"] = `@import "<stdin>";.stdin{color:red}`
+	w := &bytes.Buffer{}
+	err := cssMinifier.Minify(m, w, bytes.NewBufferString(files["/site/<stdin>"]), nil)
+	test.Minify(t, files["/site/<stdin>"], err, w.String(), `.stdin{color:red}`)
+}
+
+func TestCSSRebaseURLs(t *testing.T) {
+	tests := []struct {
+		css      string
+		expected string
+	}{
+		{`a{background:url(../img/a.png)}`, `a{background:url(../src/img/a.png)}`},
+		{`a{background:url("img/a b.png?v=1#x")}`, `a{background:url("../src/css/img/a b.png?v=1#x")}`},
+		{`a{background:image-set(url(a.png) 1x,url('a@2x.png') 2x)}`, `a{background:image-set(url(../src/css/a.png) 1x,url('../src/css/a@2x.png') 2x)}`},
+		{`@font-face{src:url(fonts/a.woff2) format("woff2")}`, `@font-face{src:url(../src/css/fonts/a.woff2) format("woff2")}`},
+		{`@import "base.css";@import url(theme.css) screen;`, `@import "../src/css/base.css";@import url(../src/css/theme.css) screen`},
+		{`a{background:url(/img/a.png),url(https://example.com/a.png),url(//example.com/a.png)}`, `a{background:url(/img/a.png),url(https://example.com/a.png),url(//example.com/a.png)}`},
+		{`a{fill:url(#a);background:url(data:image/gif;base64,R0lGODlhAQABAAAAACw=)}`, `a{fill:url(#a);background:url(data:image/gif;base64,R0lGODlhAQABAAAAACw=)}`},
+		{`a{--bg:url(a.png)}`, `a{--bg:url(a.png)}`},
+	}
+
+	m := minify.New()
+	params := map[string]string{"filename": "/site/src/css/main.css", "output": "/site/dist/main.css"}
+	for _, tt := range tests {
+		t.Run(tt.css, func(t *testing.T) {
+			for _, o := range []*Minifier{{Decimals: -1}, {Decimals: -1, MergeRules: true}} {
+				r := bytes.NewBufferString(tt.css)
+				w := &bytes.Buffer{}
+				err := o.Minify(m, w, r, params)
+				test.Minify(t, tt.css, err, w.String(), tt.expected)
+			}
+		})
+	}
+
+	// relative URLs are kept when the output is in the same directory, or when either path is unknown
+	for _, params := range []map[string]string{{"filename": "css/main.css", "output": "css/main.min.css"}, {"output": "dist/main.css"}, {"filename": "css/main.css"}} {
+		w := &bytes.Buffer{}
+		err := Minify(m, w, bytes.NewBufferString(`a{background:url(../img/a.png)}`), params)
+		test.Minify(t, `a{background:url(../img/a.png)}`, err, w.String(), `a{background:url(../img/a.png)}`)
+	}
+
+	// imported stylesheets are rebased to the output
+	files := map[string]string{
+		"/site/src/css/vendor/a.css": `.a{background:url(img/a.png)}`,
+	}
+	readFile := func(filename string) ([]byte, error) {
+		if b, ok := files[filepath.ToSlash(filename)]; ok {
+			return []byte(b), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	w := &bytes.Buffer{}
+	err := (&Minifier{Decimals: -1, InlineImports: true, ReadFile: readFile}).Minify(m, w, bytes.NewBufferString(`@import "vendor/a.css";@import "missing.css";`), params)
+	test.Minify(t, `@import "vendor/a.css";@import "missing.css";`, err, w.String(), `@import "../src/css/vendor/a.css";@import "../src/css/missing.css"`)
+	w.Reset()
+	err = (&Minifier{Decimals: -1, InlineImports: true, ReadFile: readFile}).Minify(m, w, bytes.NewBufferString(`@import "vendor/a.css";`), params)
+	test.Minify(t, `@import "vendor/a.css";`, err, w.String(), `.a{background:url(../src/css/vendor/img/a.png)}`)
+}
+
+func TestCSSTargets(t *testing.T) {
+	tests := []struct {
+		targets  string
+		css      string
+		expected string
+	}{
+		{"chrome >= 90", `a{-webkit-border-radius:2px;-moz-border-radius:2px;border-radius:2px}`, `a{border-radius:2px}`},
+		{"chrome >= 90", `a{-webkit-transition:-webkit-transform 1s;transition:transform 1s}`, `a{transition:transform 1s}`},
+		{"chrome >= 90", `a{display:-webkit-flex;display:-ms-flexbox;display:flex}`, `a{display:flex}`},
+		{"chrome >= 90", `a{background:-webkit-linear-gradient(red,blue);background:linear-gradient(red,blue)}`, `a{background:linear-gradient(red,blue)}`},
+		{"chrome >= 90", `@-webkit-keyframes x{from{opacity:0}}@keyframes x{from{opacity:0}}`, `@keyframes x{from{opacity:0}}`},
+		{"chrome >= 90", `a{color:rgba(0,0,0,.5)}`, `a{color:#00000080}`},
+		{"chrome 20", `a{-webkit-transition:color 1s;transition:color 1s}`, `a{-webkit-transition:color 1s;transition:color 1s}`},
+		{"chrome 20", `@-webkit-keyframes x{from{opacity:0}}@keyframes x{from{opacity:0}}`, `@-webkit-keyframes x{from{opacity:0}}@keyframes x{from{opacity:0}}`},
+		{"defaults", `a{-webkit-user-select:none;-moz-user-select:none;user-select:none}`, `a{-webkit-user-select:none;user-select:none}`},
+		{"defaults", `a{position:-webkit-sticky;position:sticky}`, `a{position:sticky}`},
+		{"defaults", `a{-webkit-backdrop-filter:blur(4px);backdrop-filter:blur(4px)}`, `a{-webkit-backdrop-filter:blur(4px);backdrop-filter:blur(4px)}`},
+		{"chrome >= 90", `a{-webkit-user-select:none;-moz-user-select:none;user-select:none}`, `a{user-select:none}`},
+		{"firefox >= 70", `a{-webkit-user-select:none;-moz-user-select:none;user-select:none}`, `a{user-select:none}`},
+		{"firefox 60", `a{-moz-user-select:none;-ms-user-select:none;user-select:none}`, `a{-moz-user-select:none;user-select:none}`},
+		{"ie 10", `a{-webkit-transform:none;-ms-transform:none;transform:none}`, `a{transform:none}`},
+		{"ie 9", `a{-webkit-transform:none;-ms-transform:none;transform:none}`, `a{-ms-transform:none;transform:none}`},
+		{"ie 11", `a{color:rgba(0,0,0,.5);margin:5000em}`, `a{color:rgba(0,0,0,.5);margin:5000em}`},
+
+		// kept without unprefixed declaration in the same block, or for unknown features
+		{"chrome >= 90", `a{-webkit-transform:none}`, `a{-webkit-transform:none}`},
+		{"chrome >= 90", `a{-webkit-transform:none}b{transform:none}`, `a{-webkit-transform:none}b{transform:none}`},
+		{"chrome >= 90", `a{-webkit-box-reflect:below;-webkit-text-size-adjust:none;text-size-adjust:none}`, `a{-webkit-box-reflect:below;-webkit-text-size-adjust:none;text-size-adjust:none}`},
+		{"chrome >= 90", `a{-webkit-background-clip:text;background-clip:text}`, `a{-webkit-background-clip:text;background-clip:text}`},
+		{"chrome >= 90", `a{display:-webkit-box;display:flex}`, `a{display:-webkit-box;display:flex}`},
+		{"chrome >= 90", `@-webkit-keyframes x{from{opacity:0}}@keyframes y{from{opacity:0}}`, `@-webkit-keyframes x{from{opacity:0}}@keyframes y{from{opacity:0}}`},
+	}
+
+	m := minify.New()
+	for _, tt := range tests {
+		t.Run(tt.targets+" "+tt.css, func(t *testing.T) {
+			targets, err := ParseTargets(tt.targets)
+			test.Error(t, err)
+			cssMinifier := &Minifier{Decimals: -1, Targets: targets}
+			r := bytes.NewBufferString(tt.css)
+			w := &bytes.Buffer{}
+			err = cssMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.css, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		query    string
+		browser  string
+		expected float64
+	}{
+		{"chrome >= 90, safari >= 14", "chrome", 90},
+		{"chrome >= 90, safari >= 14", "safari", 14},
+		{"Chrome > 90", "chrome", 91},
+		{"ie 11", "ie", 11},
+		{"ios 15.0-15.4", "ios_saf", 15},
+		{"last 2 firefox versions", "firefox", 134},
+		{"last 2 versions", "ie", 10},
+		{"last 2 versions, not dead", "ie", 0},
+		{"last 2 versions, not ie 10", "ie", 11},
+		{"defaults", "firefox", 115},
+		{"defaults", "ie", 0},
+		{"defaults", "safari", 15},
+		{"defaults", "ios_saf", 15},
+		{"defaults", "chrome", 109},
+		{"last 2 versions and chrome > 100", "chrome", 132},
+		{"safari 17 or ff esr", "firefox", 115},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			targets, err := ParseTargets(tt.query)
+			test.Error(t, err)
+			test.T(t, targets.Browsers[tt.browser], tt.expected)
+		})
+	}
+
+	errorTests := []string{"", "> 0.5%", "not dead", "netscape 4", "chrome 1000", "last 1 chrome version and chrome > 200", "supports css-grid"}
+	for _, query := range errorTests {
+		t.Run(query, func(t *testing.T) {
+			_, err := ParseTargets(query)
+			test.That(t, err != nil, "must fail")
+		})
+	}
+}
+
+func TestCSSNesting(t *testing.T) {
+	tests := []struct {
+		css       string
+		expected  string
+		flattened string
+	}{
+		{".a { color:red; &:hover { color:blue } }", ".a{color:red;&:hover{color:blue}}", ".a{color:red}.a:hover{color:blue}"},
+		{".a { > .b { color:red } }", ".a{>.b{color:red}}", ".a>.b{color:red}"},
+		{".a { .b { color:red } }", ".a{.b{color:red}}", ".a .b{color:red}"},
+		{".a { .b & { color:red } }", ".a{.b &{color:red}}", ".b .a{color:red}"},
+		{".a { &.b, & + & { color:red } }", ".a{&.b,&+&{color:red}}", ".a.b,.a+.a{color:red}"},
+		{".a, .b { & .c { color:red } }", ".a,.b{& .c{color:red}}", ".a .c,.b .c{color:red}"},
+		{".a .b { &:hover { color:red } }", ".a .b{&:hover{color:red}}", ".a .b:hover{color:red}"},
+		{".a .b { .c & { color:red } }", ".a .b{.c &{color:red}}", ".c :is(.a .b){color:red}"},
+		{"div { .a& { color:red } }", "div{.a&{color:red}}", ".a:is(div){color:red}"},
+		{".a { .b { .c { color:red } } }", ".a{.b{.c{color:red}}}", ".a .b .c{color:red}"},
+		{".a { color:red; .b { color:blue } margin: 0px }", ".a{color:red;.b{color:blue}margin:0}", ".a{color:red}.a .b{color:blue}.a{margin:0}"},
+		{".a { color:red; @media screen { color: blue; &:hover { color: green } } }", ".a{color:red;@media screen{color:blue;&:hover{color:green}}}", ".a{color:red}@media screen{.a{color:blue}.a:hover{color:green}}"},
+		{"@media screen { .a { &:hover { color:red } } }", "@media screen{.a{&:hover{color:red}}}", "@media screen{.a:hover{color:red}}"},
+		{".a { --x: { color:red }; color: var(--x) }", ".a{--x:{color:red};color:var(--x)}", ".a{--x:{color:red};color:var(--x)}"},
+		{".a{} @import 'b.css'; .c { color:red }", ".a{}@import 'b.css';.c{color:red}", ".a{}@import 'b.css';.c{color:red}"},
+		{".a:not(.x, .y) { &:hover { color:red } }", ".a:not(.x,.y){&:hover{color:red}}", ".a:not(.x,.y):hover{color:red}"},
+		{".a:is(.x, .y) { .b { color:red } }", ".a:is(.x,.y){.b{color:red}}", ".a:is(.x,.y) .b{color:red}"},
+		{".a, .b { & + & { color:red } }", ".a,.b{&+&{color:red}}", ":is(.a,.b)+:is(.a,.b){color:red}"},
+		{".a { @media screen { color:blue; @media (min-width:100px) { color:red } } }", ".a{@media screen{color:blue;@media(min-width:100px){color:red}}}", "@media screen{.a{color:blue}}@media screen and (min-width:100px){.a{color:red}}"},
+		{"@media (min-width:100px) { .a { @media print { color:red } } }", "@media(min-width:100px){.a{@media print{color:red}}}", "@media print and (min-width:100px){.a{color:red}}"},
+		{"@media screen { .a { @media print, not tv { color:red } } }", "@media screen{.a{@media print,not tv{color:red}}}", "@media screen{@media print,not tv{.a{color:red}}}"},
+		{".a { &:is(.x, .y), .b { color:red } }", ".a{&:is(.x,.y),.b{color:red}}", ".a:is(.x,.y),.a .b{color:red}"},
+	}
+
+	m := minify.New()
+	for _, tt := range tests {
+		t.Run(tt.css, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.css)
+			w := &bytes.Buffer{}
+			err := (&Minifier{Decimals: -1, Nesting: true}).Minify(m, w, r, nil)
+			test.Minify(t, tt.css, err, w.String(), tt.expected)
+
+			r = bytes.NewBufferString(tt.css)
+			w.Reset()
+			err = (&Minifier{Decimals: -1, FlattenNesting: true}).Minify(m, w, r, nil)
+			test.Minify(t, tt.css, err, w.String(), tt.flattened)
+		})
+	}
+
+	// flattened for targets without nesting support
+	targets, err := ParseTargets("safari >= 15")
+	test.Error(t, err)
+	w := &bytes.Buffer{}
+	err = (&Minifier{Decimals: -1, Nesting: true, Targets: targets}).Minify(m, w, bytes.NewBufferString(".a{&:hover{color:red}}"), nil)
+	test.Minify(t, ".a{&:hover{color:red}}", err, w.String(), ".a:hover{color:red}")
+}
+
+func TestCSSNames(t *testing.T) {
+	tests := []struct {
+		css      string
+		expected string
+	}{
+		{`.navigation-header{color:red}`, `.a{color:red}`},
+		{`#main .navigation-header,#main>.btn:hover{color:red}`, `#a .a,#a>.b:hover{color:red}`},
+		{`.col-1\/2,.\31 0{color:red}`, `.a,.b{color:red}`},
+		{`a[href="#main"],a[class=btn],:not(.btn){color:red}`, `a[href="#a"],a[class=a],:not(.a){color:red}`},
+		{`[class~="btn"],[id=main],.btn{color:red}`, `[class~=a],[id=a],.a{color:red}`},
+		{`[class^=icon-],[class*="col-"],[class=btn i],[href^="#"]{color:red}`, `[class^=icon-],[class*=col-],[class=btn i],[href^="#"]{color:red}`},
+		{`a{fill:url(#gradient);background:url(img.png#x)}`, `a{fill:url(#a);background:url(img.png#x)}`},
+		{`@keyframes x{from{color:red}50%{color:blue}}`, `@keyframes x{from{color:red}50%{color:blue}}`},
+		{`:root{--main-color:red;--gap:var(--main-color)}a{color:var(--main-color,blue)}`, `:root{--a:red;--b:var(--a)}a{color:var(--a,blue)}`},
+		{`@property --main-color{syntax:'<color>';inherits:false}a{--main-color:red}`, `@property --a{syntax:'<color>';inherits:false}a{--a:red}`},
+		{`a{view-transition-name:--card}::view-transition-group(--card){animation-duration:1s}`, `a{view-transition-name:--a}::view-transition-group(--a){animation-duration:1s}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.css, func(t *testing.T) {
+			for _, o := range []*Minifier{{Decimals: -1, RenameCustomProperties: true}, {Decimals: -1, MergeRules: true, RenameCustomProperties: true}} {
+				m := minify.New()
+				m.Names = minify.NewNames()
+				r := bytes.NewBufferString(tt.css)
+				w := &bytes.Buffer{}
+				err := o.Minify(m, w, r, nil)
+				test.Minify(t, tt.css, err, w.String(), tt.expected)
+			}
+		})
+	}
+
+	// purging compares the selectors with the original names of the documents
+	purge := NewPurge()
+	test.Error(t, purge.AddHTML(bytes.NewBufferString(`<p class=btn>`)))
+	m := minify.New()
+	m.Names = minify.NewNames()
+	w := &bytes.Buffer{}
+	err := (&Minifier{Decimals: -1, Purge: purge}).Minify(m, w, bytes.NewBufferString(`.table{color:red}.btn{color:red}`), nil)
+	test.Minify(t, `.table{color:red}.btn{color:red}`, err, w.String(), `.a{color:red}`)
+}
+
+func TestCSSComments(t *testing.T) {
+	tests := []struct {
+		policy   string
+		css      string
+		expected string
+	}{
+		{"none", `/*! MIT */ a{}`, `a{}`},
+		{"all", `/* x */ a{}`, `/*x*/a{}`},
+		{"license", `/*! MIT */ /* x */ /* @license  BSD */ a{}`, `/*!MIT*//*@license BSD*/a{}`},
+	}
+
+	m := minify.New()
+	for _, tt := range tests {
+		t.Run(tt.css, func(t *testing.T) {
+			comments, err := minify.NewComments(tt.policy)
+			test.Error(t, err)
+			cssMinifier := &Minifier{Decimals: -1, Comments: comments}
+			r := bytes.NewBufferString(tt.css)
+			w := &bytes.Buffer{}
+			err = cssMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.css, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestCSSSourceMap(t *testing.T) {
+	m := minify.New()
+	m.Add("text/css", &Minifier{Decimals: -1})
+	sm := minify.NewSourceMap("out.css")
+	sm.AddSource("in.css", 0)
+	w := &bytes.Buffer{}
+	err := m.MinifyWithSourceMap("text/css", w, bytes.NewBufferString("a {\n  color: red;\n}\n@media print {\n  b { margin: 0px }\n}"), sm, "out.css.map")
+	test.Error(t, err)
+	test.String(t, w.String(), "a{color:red}@media print{b{margin:0}}\n/*# sourceMappingURL=out.css.map */")
+
+	b, _ := sm.MarshalJSON()
+	test.String(t, string(b), `{"version":3,"file":"out.css","sources":["in.css"],"names":[],"mappings":"AAAA,EACE,UAEF,aACE,EAAI"}`)
+}
+
 func TestReaderErrors(t *testing.T) {
 	r := test.NewErrorReader(0)
 	w := &bytes.Buffer{}
@@ -290,8 +956,8 @@ func TestWriterErrors(t *testing.T) {
 		n   []int
 	}{
 		{`@import 'file'`, []int{0, 2}},
-		{`@media all{}`, []int{0, 2, 3, 4}},
-		{`a[id^="L"]{margin:2in!important;color:red}`, []int{0, 4, 6, 7, 8, 9, 10, 11}},
+		{`@media all{}`, []int{0, 1}},
+		{`a[id^="L"]{margin:2in!important;color:red}`, []int{0, 1, 3, 5, 6, 7, 8, 9, 10}},
 		{`a{color:rgb(255,0,0)}`, []int{4}},
 		{`a{color:rgb(255,255,255)}`, []int{4}},
 		{`a{color:hsl(0,100%,50%)}`, []int{4}},
@@ -302,7 +968,7 @@ func TestWriterErrors(t *testing.T) {
 		{`a{--var:val}`, []int{2, 3, 4}},
 		{`a{*color:0}`, []int{2, 3}},
 		{`a{color:0;baddecl 5}`, []int{5}},
-		{`a[id="x" i],b{color:0}`, []int{5, 8}},
+		{`a[id="x" i],b{color:0}`, []int{0, 1, 2, 3}},
 		{`a{color:()!important}`, []int{4, 6}},
 		{`a{margin:5 4}`, []int{5}},
 		{`a{margin=5}`, []int{2, 3}},
@@ -0,0 +1,329 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/buffer"
+	"github.com/tdewolff/parse/v2/css"
+)
+
+// minifyCustomProperty minifies the value of a custom property. The value is substituted as tokens wherever it is used, so only changes
+// that keep the tokens are made: whitespace is collapsed and removed around commas and brackets, comments are removed, and numbers are
+// shortened unless that would turn a number such as 1.0 into an integer or the other way around. An empty value keeps a space.
+func (c *cssMinifier) minifyCustomProperty(value []byte) []byte {
+	l := css.NewLexer(buffer.NewReader(value))
+	defer l.Restore()
+
+	b := make([]byte, 0, len(value))
+	prev := css.ErrorToken // last written token
+	space, comment := false, false
+	for {
+		tt, data := l.Next()
+		if tt == css.ErrorToken {
+			if l.Err() != io.EOF {
+				return value
+			}
+			break
+		}
+
+		switch tt {
+		case css.WhitespaceToken:
+			space = true
+			continue
+		case css.CommentToken:
+			comment = true
+			continue
+		case css.NumberToken, css.PercentageToken, css.DimensionToken:
+			short := c.shortenNumber(tt, data)
+			if !space && !comment && short[0] != data[0] && mergesNumber(prev) {
+				space = true // such as a+0.5 for a+.5, where the sign was joined with the number
+			}
+			data = short
+		case css.CustomPropertyNameToken:
+			if c.renameProperties() {
+				data = c.renameProperty(data)
+			}
+		}
+		if prev != css.ErrorToken && (space || comment) && !noSpaceAfter(prev) && !noSpaceBefore(tt) {
+			if space {
+				b = append(b, ' ')
+			} else {
+				b = append(b, "/**/"...) // keeps tokens such as 1/**/2 apart
+			}
+		}
+		b = append(b, data...)
+		prev = tt
+		space, comment = false, false
+	}
+	if len(b) == 0 {
+		return spaceBytes // older browsers reject --x:;
+	}
+	return b
+}
+
+// noSpaceAfter returns true for tokens after which whitespace is insignificant.
+func noSpaceAfter(tt css.TokenType) bool {
+	return tt == css.CommaToken || tt == css.FunctionToken || tt == css.LeftParenthesisToken || tt == css.LeftBracketToken || tt == css.LeftBraceToken
+}
+
+// noSpaceBefore returns true for tokens before which whitespace is insignificant.
+func noSpaceBefore(tt css.TokenType) bool {
+	return tt == css.CommaToken || tt == css.RightParenthesisToken || tt == css.RightBracketToken || tt == css.RightBraceToken
+}
+
+// mergesNumber returns true for tokens that join a following number that doesn't start with a sign.
+func mergesNumber(tt css.TokenType) bool {
+	return tt == css.IdentToken || tt == css.NumberToken || tt == css.PercentageToken || tt == css.DimensionToken || tt == css.DelimToken
+}
+
+// shortenNumber shortens the number of a number, percentage or dimension token, but keeps its sign, its unit and whether it is an
+// integer, since integers are valid in places where numbers such as 1.0 are not, and the sign separates it from the preceding token in
+// values such as 1+2.
+func (c *cssMinifier) shortenNumber(tt css.TokenType, data []byte) []byte {
+	n := len(data)
+	if tt == css.PercentageToken {
+		n--
+	} else if tt == css.DimensionToken {
+		n = parse.Number(data)
+	}
+
+	var num []byte
+	if !c.keepCSS2 {
+		num = minify.Number(parse.Copy(data[:n]), c.o.Decimals)
+	} else {
+		num = minify.Decimal(parse.Copy(data[:n]), c.o.Decimals)
+	}
+	if sign := data[0]; (sign == '+' || sign == '-') && num[0] != sign {
+		num = append([]byte{sign}, num...)
+	}
+	if len(data[:n]) <= len(num) || isInteger(num) != isInteger(data[:n]) {
+		return data
+	}
+	return append(num, data[n:]...)
+}
+
+// isInteger returns true if the number has no fraction or exponent.
+func isInteger(num []byte) bool {
+	return bytes.IndexAny(num, ".eE") == -1
+}
+
+// renameProperties returns true if custom properties are renamed.
+func (c *cssMinifier) renameProperties() bool {
+	return c.o.RenameCustomProperties && c.m != nil && c.m.Names != nil
+}
+
+// renameProperty returns the short name of a custom property such as --main-color.
+func (c *cssMinifier) renameProperty(name []byte) []byte {
+	return append([]byte("--"), c.m.Names.Property(unescapeIdent(name[2:]))...)
+}
+
+// renameValues renames the custom properties of the values of a declaration or at-rule, such as var(--main-color) or @property --main-color.
+func (c *cssMinifier) renameValues(values []css.Token) {
+	for i, t := range values {
+		if t.TokenType == css.CustomPropertyNameToken {
+			values[i].Data = c.renameProperty(t.Data)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+// cssWideKeywords are values that make a custom property invalid or inherited, so that var() uses the fallback or the inherited value.
+var cssWideKeywords = map[string]bool{
+	"initial":      true,
+	"inherit":      true,
+	"unset":        true,
+	"revert":       true,
+	"revert-layer": true,
+}
+
+// inlineCustomProperties replaces the var() references to custom properties that are declared once, in a :root ruleset at the top level,
+// by their value and removes the declaration. Properties that are registered by @property, that are !important, or whose value has
+// var() references themselves are kept.
+func inlineCustomProperties(list []node) []node {
+	counts := map[string]int{}
+	countCustomProperties(list, counts)
+
+	values := map[string][]byte{}
+	for _, n := range list {
+		if r, ok := n.(*rulesetNode); ok && isRoot(r) {
+			for _, n := range r.list {
+				if d, ok := n.(*declNode); ok && isCustomProperty(d) {
+					if name, value := customProperty(d); counts[string(name)] == 1 && isInlinable(value) {
+						values[string(name)] = value
+					}
+				}
+			}
+		}
+	}
+	if len(values) == 0 {
+		return list
+	}
+
+	// keep the properties that are referenced where their value would join the following tokens, such as var(--x)px
+	walkDeclarations(list, func(d *declNode) {
+		replaceVars(d.data, func(name []byte, end int) []byte {
+			if end < len(d.data) && (isNameByte(d.data[end]) || d.data[end] == '(' || d.data[end] == '%') {
+				delete(values, string(name))
+			}
+			return nil
+		})
+	})
+	if len(values) == 0 {
+		return list
+	}
+
+	walkDeclarations(list, func(d *declNode) {
+		d.data = replaceVars(d.data, func(name []byte, end int) []byte {
+			return values[string(name)]
+		})
+	})
+
+	j := 0
+	for _, n := range list {
+		if r, ok := n.(*rulesetNode); ok && isRoot(r) {
+			k := 0
+			for _, n := range r.list {
+				if d, ok := n.(*declNode); ok && isCustomProperty(d) {
+					if name, _ := customProperty(d); values[string(name)] != nil {
+						continue
+					}
+				}
+				r.list[k] = n
+				k++
+			}
+			if k == 0 && 0 < len(r.list) {
+				continue
+			}
+			r.list = r.list[:k]
+		}
+		list[j] = n
+		j++
+	}
+	return list[:j]
+}
+
+// countCustomProperties counts the declarations of each custom property, where properties registered by @property count twice.
+func countCustomProperties(list []node, counts map[string]int) {
+	for _, n := range list {
+		switch n := n.(type) {
+		case *declNode:
+			if isCustomProperty(n) {
+				name, _ := customProperty(n)
+				counts[string(name)]++
+			}
+		case *rulesetNode:
+			countCustomProperties(n.list, counts)
+		case *atRuleNode:
+			if 10 < len(n.prelude) && parse.EqualFold(n.prelude[:10], []byte("@property ")) {
+				counts[string(parse.TrimWhitespace(n.prelude[10:]))] += 2
+			}
+			countCustomProperties(n.list, counts)
+		}
+	}
+}
+
+// walkDeclarations calls f for all declarations of the rulesets and at-rules.
+func walkDeclarations(list []node, f func(*declNode)) {
+	for _, n := range list {
+		switch n := n.(type) {
+		case *declNode:
+			f(n)
+		case *rulesetNode:
+			walkDeclarations(n.list, f)
+		case *atRuleNode:
+			walkDeclarations(n.list, f)
+		}
+	}
+}
+
+// isRoot returns true for a ruleset with the only selector :root.
+func isRoot(r *rulesetNode) bool {
+	return len(r.selectors) == 1 && bytes.Equal(r.selectors[0], []byte(":root"))
+}
+
+// isCustomProperty returns true for the declaration of a custom property.
+func isCustomProperty(d *declNode) bool {
+	return 2 < len(d.prop) && d.prop[0] == '-' && d.prop[1] == '-'
+}
+
+// customProperty returns the name and value of a custom property declaration. The name is taken from the minified declaration, since
+// it may have been renamed.
+func customProperty(d *declNode) ([]byte, []byte) {
+	colon := bytes.IndexByte(d.data, ':')
+	if colon == -1 {
+		return d.data, nil
+	}
+	return d.data[:colon], d.data[colon+1:]
+}
+
+// isInlinable returns true if the value of a custom property can replace its var() references.
+func isInlinable(value []byte) bool {
+	value = parse.TrimWhitespace(value)
+	return 0 < len(value) && bytes.IndexAny(value, "!{};") == -1 && !bytes.Contains(parse.ToLower(parse.Copy(value)), []byte("var(")) &&
+		!cssWideKeywords[string(parse.ToLower(parse.Copy(value)))]
+}
+
+// replaceVars calls value for each var() reference of a minified declaration with the name and the end of the reference, and replaces
+// the reference by the returned value unless it is nil.
+func replaceVars(data []byte, value func(name []byte, end int) []byte) []byte {
+	var b []byte
+	prev := 0
+	for i := 0; i < len(data); i++ {
+		if c := data[i]; c == '"' || c == '\'' {
+			i = skipString(data, i)
+		} else if c == '\\' {
+			i++
+		} else if i+6 < len(data) && parse.EqualFold(data[i:i+6], []byte("var(--")) && (i == 0 || !isNameByte(data[i-1])) {
+			start := i + 4
+			end := start + 2
+			for end < len(data) && (isNameByte(data[end]) || data[end] == '\\') {
+				if data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if len(data) < end {
+				break // escape at the end
+			}
+			name := data[start:end]
+			for level := 0; end < len(data) && (data[end] != ')' || 0 < level); end++ {
+				if data[end] == '"' || data[end] == '\'' {
+					end = skipString(data, end)
+				} else if data[end] == '(' {
+					level++
+				} else if data[end] == ')' {
+					level--
+				}
+			}
+			if len(data) <= end {
+				break
+			}
+			end++ // closing parenthesis
+			if v := value(name, end); v != nil {
+				b = append(b, data[prev:i]...)
+				b = append(b, v...)
+				prev = end
+			}
+			i = end - 1
+		}
+	}
+	if b == nil {
+		return data
+	}
+	return append(b, data[prev:]...)
+}
+
+// skipString returns the index of the closing quote of the string at i.
+func skipString(data []byte, i int) int {
+	quote := data[i]
+	for i++; i < len(data) && data[i] != quote; i++ {
+		if data[i] == '\\' {
+			i++
+		}
+	}
+	return i
+}
@@ -0,0 +1,355 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/buffer"
+	"github.com/tdewolff/parse/v2/css"
+)
+
+// isLocalURL returns true for URLs without scheme or host, such as a.css or ../img/a.png, but not for same-document URLs such as #a.
+func isLocalURL(uri string) bool {
+	if uri == "" || uri[0] == '#' || strings.HasPrefix(uri, "//") {
+		return false
+	}
+	for i := 0; i < len(uri); i++ {
+		if c := uri[i]; c == ':' {
+			return false
+		} else if c == '/' || c == '?' || c == '#' {
+			break
+		}
+	}
+	return true
+}
+
+// rebaseURL prefixes a relative URL by the directory dir, where absolute URLs such as /img/a.png or https://example.com/a.png are kept.
+func rebaseURL(uri string, dir string) string {
+	if !isLocalURL(uri) || uri[0] == '/' {
+		return uri
+	}
+	query := ""
+	if i := strings.IndexAny(uri, "?#"); i != -1 {
+		uri, query = uri[:i], uri[i:]
+	}
+	rebased := path.Join(dir, uri)
+	if strings.HasSuffix(uri, "/") && !strings.HasSuffix(rebased, "/") {
+		rebased += "/"
+	}
+	return rebased + query
+}
+
+// urlToken returns the url() token of a URL with its original quote, or 0 when unquoted. The URL is unescaped, which is valid between
+// the same quotes, and unquoted URLs are only quoted when the rebased directory has whitespace or parentheses.
+func urlToken(uri string, quote byte) []byte {
+	if quote == 0 && strings.ContainsAny(uri, " \t\n\r\f()") {
+		quote = '"'
+	}
+	if quote == 0 {
+		return []byte("url(" + uri + ")")
+	}
+	return []byte("url(" + string(quote) + uri + string(quote) + ")")
+}
+
+// rebaseURLs rebases the relative URLs of url() tokens in the values by the directory dir.
+func rebaseURLs(values []css.Token, dir string) {
+	for i, val := range values {
+		if val.TokenType == css.URLToken {
+			uri := parse.TrimWhitespace(val.Data[4 : len(val.Data)-1])
+			quote := byte(0)
+			if 0 < len(uri) && (uri[0] == '"' || uri[0] == '\'') {
+				quote = uri[0]
+				uri = uri[1 : len(uri)-1]
+			}
+			if rebased := rebaseURL(string(uri), dir); rebased != string(uri) {
+				values[i].Data = urlToken(rebased, quote)
+			}
+		} else if val.TokenType == css.FunctionToken && parse.EqualFold(val.Data, []byte("url(")) {
+			j := i + 1
+			for j < len(values) && values[j].TokenType == css.WhitespaceToken {
+				j++
+			}
+			if j < len(values) && values[j].TokenType == css.StringToken {
+				values[j].Data = rebaseString(values[j].Data, dir)
+			}
+		}
+	}
+}
+
+// rebaseString rebases the relative URL of a string token by the directory dir.
+func rebaseString(data []byte, dir string) []byte {
+	uri := string(data[1 : len(data)-1])
+	if rebased := rebaseURL(uri, dir); rebased != uri {
+		return []byte(string(data[0]) + rebased + string(data[0]))
+	}
+	return data
+}
+
+// rebaseImport rebases the URL of an @import rule by the directory dir, which is either a string or a url() token.
+func rebaseImport(values []css.Token, dir string) {
+	for i, val := range values {
+		if val.TokenType == css.StringToken {
+			values[i].Data = rebaseString(val.Data, dir)
+			return
+		} else if val.TokenType != css.WhitespaceToken {
+			rebaseURLs(values[i:], dir)
+			return
+		}
+	}
+}
+
+// relDir returns the directory of filename relative to dir in slash notation, or an empty string if it's the same directory.
+func relDir(dir, filename string) string {
+	rel, err := filepath.Rel(dir, filepath.Dir(filename))
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// importRule is the URL and conditions of an @import rule, such as @import "a.css" layer(base) supports(display:grid) screen.
+type importRule struct {
+	url      string
+	layer    []byte // nil without layer, empty for an anonymous layer
+	supports []byte
+	media    []byte
+}
+
+// parseImport parses the minified prelude of an @import rule, it returns false if it isn't an @import rule.
+func parseImport(prelude []byte) (importRule, bool) {
+	rule := importRule{}
+	if len(prelude) < 7 || !parse.EqualFold(prelude[:7], []byte("@import")) {
+		return rule, false
+	}
+	l := css.NewLexer(buffer.NewReader(parse.Copy(prelude[7:])))
+	defer l.Restore()
+
+	next := func() (css.TokenType, []byte) {
+		for {
+			if tt, data := l.Next(); tt != css.WhitespaceToken {
+				return tt, data
+			}
+		}
+	}
+	// block returns the contents of a function up to its closing parenthesis
+	block := func() []byte {
+		b := []byte{}
+		level := 0
+		for {
+			tt, data := l.Next()
+			if tt == css.ErrorToken || tt == css.RightParenthesisToken && level == 0 {
+				return b
+			} else if tt == css.LeftParenthesisToken || tt == css.FunctionToken {
+				level++
+			} else if tt == css.RightParenthesisToken {
+				level--
+			}
+			b = append(b, data...)
+		}
+	}
+
+	tt, data := next()
+	switch tt {
+	case css.URLToken:
+		uri := parse.TrimWhitespace(data[4 : len(data)-1])
+		if 0 < len(uri) && (uri[0] == '"' || uri[0] == '\'') {
+			uri = uri[1 : len(uri)-1]
+		}
+		rule.url = string(uri)
+	case css.StringToken:
+		rule.url = string(data[1 : len(data)-1])
+	case css.FunctionToken:
+		if !parse.EqualFold(data, []byte("url(")) {
+			return rule, false
+		} else if tt, data = next(); tt != css.StringToken {
+			return rule, false
+		}
+		rule.url = string(data[1 : len(data)-1])
+		if tt, _ = next(); tt != css.RightParenthesisToken {
+			return rule, false
+		}
+	default:
+		return rule, false
+	}
+
+	media := []byte{}
+	for {
+		tt, data := l.Next()
+		if tt == css.ErrorToken {
+			break
+		} else if len(media) == 0 && tt == css.IdentToken && parse.EqualFold(data, []byte("layer")) {
+			rule.layer = []byte{}
+		} else if len(media) == 0 && tt == css.FunctionToken && parse.EqualFold(data, []byte("layer(")) {
+			rule.layer = block()
+		} else if len(media) == 0 && tt == css.FunctionToken && parse.EqualFold(data, []byte("supports(")) {
+			rule.supports = block()
+		} else if 0 < len(media) || tt != css.WhitespaceToken {
+			media = append(media, data...)
+		}
+	}
+	if media = parse.TrimWhitespace(media); 0 < len(media) && !parse.EqualFold(media, []byte("all")) {
+		rule.media = media
+	}
+	return rule, true
+}
+
+// wrap returns the nodes wrapped in the at-rules for the conditions of the @import rule.
+func (rule importRule) wrap(list []node) []node {
+	if rule.layer != nil {
+		prelude := []byte("@layer")
+		if 0 < len(rule.layer) {
+			prelude = append(append(prelude, ' '), rule.layer...)
+		}
+		list = []node{&atRuleNode{prelude: prelude, block: true, list: list}}
+	}
+	if rule.supports != nil {
+		prelude := []byte("@supports")
+		i := 0
+		for i < len(rule.supports) && isNameByte(rule.supports[i]) {
+			i++
+		}
+		if 0 < i && i < len(rule.supports) && rule.supports[i] == ':' {
+			prelude = append(append(append(prelude, '('), rule.supports...), ')') // declaration such as display:grid
+		} else {
+			prelude = append(append(prelude, ' '), rule.supports...)
+		}
+		list = []node{&atRuleNode{prelude: prelude, block: true, list: list}}
+	}
+	if rule.media != nil {
+		prelude := []byte("@media")
+		if rule.media[0] != '(' {
+			prelude = append(prelude, ' ')
+		}
+		prelude = append(prelude, rule.media...)
+		list = []node{&atRuleNode{prelude: prelude, block: true, list: list}}
+	}
+	return list
+}
+
+// isStatement returns true if the node is an at-rule statement with the given name, such as @charset or @import.
+func isStatement(n node, name string) bool {
+	a, ok := n.(*atRuleNode)
+	return ok && !a.block && len(name) <= len(a.prelude) && parse.EqualFold(a.prelude[:len(name)], []byte(name)) &&
+		(len(name) == len(a.prelude) || !isNameByte(a.prelude[len(name)]))
+}
+
+// inlineImports replaces the @import rules of local stylesheets at the start of the stylesheet by their contents, wrapped in at-rules
+// for their media queries, supports conditions and layers. Since @import rules must precede all other rules, an @import rule is only
+// inlined when all following @import rules are inlined too. Files that are already being inlined, which are import cycles, are dropped.
+// Stylesheets with @namespace rules are not inlined, nor imported into them, as these must precede all rules but @charset and @import.
+func (c *cssMinifier) inlineImports(list []node, stack []string) []node {
+	end := 0
+	for end < len(list) {
+		if _, ok := list[end].(*rawNode); !ok && !isStatement(list[end], "@charset") && !isStatement(list[end], "@import") && !isStatement(list[end], "@layer") {
+			break
+		}
+		end++
+	}
+
+	for _, n := range list[end:] {
+		if isStatement(n, "@namespace") {
+			return list // @namespace rules must precede the rules of the imported stylesheets
+		}
+	}
+
+	inlined := map[int][]node{}
+	for i := end - 1; 0 <= i; i-- {
+		if !isStatement(list[i], "@import") {
+			continue
+		}
+		imported, ok := c.inlineImport(list[i].(*atRuleNode), stack)
+		if !ok {
+			break
+		}
+		inlined[i] = imported
+	}
+	if len(inlined) == 0 {
+		return list
+	}
+
+	result := make([]node, 0, len(list))
+	for i, n := range list {
+		if imported, ok := inlined[i]; ok {
+			result = append(result, imported...)
+		} else {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// inlineImport returns the nodes of the stylesheet imported by the @import rule, and false if it cannot be inlined.
+func (c *cssMinifier) inlineImport(n *atRuleNode, stack []string) ([]node, bool) {
+	rule, ok := parseImport(n.prelude)
+	if !ok || !isLocalURL(rule.url) {
+		return nil, false
+	}
+
+	uri := rule.url
+	if i := strings.IndexAny(uri, "?#"); i != -1 {
+		uri = uri[:i]
+	}
+	// relative URLs have been rebased to the root directory
+	var filename string
+	if uri[0] == '/' {
+		filename = filepath.Join(c.o.BaseDir, filepath.FromSlash(uri))
+	} else {
+		filename = filepath.Join(c.rootDir, filepath.FromSlash(uri))
+	}
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	for _, f := range stack {
+		if f == filename {
+			return nil, true // import cycle
+		}
+	}
+
+	readFile := c.o.ReadFile
+	if readFile == nil {
+		readFile = ioutil.ReadFile
+	}
+	b, err := readFile(filename)
+	if err != nil {
+		return nil, false
+	}
+
+	imported := &cssMinifier{
+		m:         c.m,
+		o:         c.o,
+		keepNames: true,
+		keepCSS2:  c.keepCSS2,
+		filename:  filename,
+		rootDir:   c.rootDir,
+	}
+	imported.rebase = relDir(c.rootDir, filename)
+	list, err := imported.parse(buffer.NewReader(b), false)
+	if err != nil {
+		return nil, false
+	}
+
+	list = imported.inlineImports(list, append(stack, filename))
+	nodes := list[:0]
+	for _, n := range list {
+		if isStatement(n, "@import") || isStatement(n, "@namespace") {
+			return nil, false // the @import or @namespace rule cannot be moved into the at-rules of the conditions or after other rules
+		} else if !isStatement(n, "@charset") {
+			nodes = append(nodes, n)
+		}
+	}
+	return rule.wrap(nodes), true
+}
+
+// dir returns the directory of the stylesheet, which is the base directory when its filename is unknown.
+func (c *cssMinifier) dir() string {
+	if c.filename == "" {
+		if c.o.BaseDir == "" {
+			return "."
+		}
+		return c.o.BaseDir
+	}
+	return filepath.Dir(c.filename)
+}
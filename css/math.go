@@ -0,0 +1,451 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/css"
+)
+
+// mathTerm is a term of a sum in a math function, which is a product of factors that is added or subtracted.
+type mathTerm struct {
+	neg     bool
+	factors []mathFactor
+}
+
+// mathFactor is a number, a parenthesized sum, or any other value such as var() or pi, that is multiplied or divided by.
+type mathFactor struct {
+	div bool
+
+	numeric bool
+	num     float64
+	unit    string // lowercase unit, % for percentages, or empty for numbers
+	data    []byte // original number, nil when computed
+
+	sum   []mathTerm // parenthesized sum of several terms
+	value []byte     // other values, which are written as is
+}
+
+// isMathFunction returns true for the functions that take math expressions, given with their opening parenthesis such as calc(.
+func isMathFunction(fun []byte) bool {
+	switch string(parse.ToLower(parse.Copy(fun))) {
+	case "calc(", "min(", "max(", "clamp(":
+		return true
+	}
+	return false
+}
+
+// minifyMath simplifies the math function, such as calc(10px + 5px) to 15px or calc(100% - (10px + 5px)) to calc(100% - 15px).
+// Numbers are only combined when they have the same unit. It returns the token unchanged when the expression cannot be parsed, and
+// keeps the expression when the result is not shorter, such as calc(1/8) for calc(.125).
+func (c *cssMinifier) minifyMath(t Token) Token {
+	f, ok := c.parseMathFunction(t.Components)
+	if !ok {
+		return t
+	}
+
+	var r Token
+	if f.numeric && 0.0 <= f.num && (f.unit != "" || f.num == math.Trunc(f.num)) {
+		// negative numbers and fractions keep calc(), since they're clamped or rounded to the values allowed by the property
+		tt := css.DimensionToken
+		if f.unit == "" {
+			tt = css.NumberToken
+		} else if f.unit == "%" {
+			tt = css.PercentageToken
+		}
+		r = Token{tt, c.mathFactor(f), nil}
+	} else if i := bytes.IndexByte(f.value, '('); i != -1 && isMathFunction(f.value[:i+1]) {
+		r = Token{css.FunctionToken, f.value[:i+1], []css.Token{{TokenType: css.FunctionToken, Data: f.value}}} // calc(min(a,b)) to min(a,b)
+	} else {
+		b := []byte("calc(")
+		if f.sum != nil {
+			b = append(b, c.mathSum(f.sum)...)
+		} else {
+			b = append(b, c.mathFactor(f)...)
+		}
+		r = Token{css.FunctionToken, b[:5], []css.Token{{TokenType: css.FunctionToken, Data: append(b, ')')}}}
+	}
+
+	n := len(r.Data)
+	if r.Components != nil {
+		n = len(r.Components[0].Data)
+	}
+	if b := c.mathTokens(t.Components); len(b) <= n {
+		return Token{css.FunctionToken, t.Data, []css.Token{{TokenType: css.FunctionToken, Data: b}}}
+	}
+	return r
+}
+
+// mathTokens returns the math function as is, but without the whitespace that can be removed, which is all whitespace except around
+// addition and subtraction, and with shortened numbers.
+func (c *cssMinifier) mathTokens(tokens []css.Token) []byte {
+	b := []byte{}
+	for _, t := range tokens {
+		switch t.TokenType {
+		case css.WhitespaceToken:
+		case css.DelimToken:
+			if t.Data[0] == '+' || t.Data[0] == '-' {
+				b = append(b, ' ', t.Data[0], ' ')
+			} else {
+				b = append(b, t.Data...)
+			}
+		case css.NumberToken, css.PercentageToken, css.DimensionToken:
+			b = append(b, c.shortenNumber(t.TokenType, t.Data)...)
+		default:
+			b = append(b, t.Data...)
+		}
+	}
+	return b
+}
+
+// parseMathFunction parses and simplifies a math function including its name and closing parenthesis. The result is either numeric,
+// a parenthesized sum for calc(), or a value for min(), max() and clamp() that cannot be computed.
+func (c *cssMinifier) parseMathFunction(tokens []css.Token) (mathFactor, bool) {
+	if len(tokens) < 3 || tokens[len(tokens)-1].TokenType != css.RightParenthesisToken {
+		return mathFactor{}, false
+	}
+	fun := string(parse.ToLower(parse.Copy(tokens[0].Data)))
+	args := [][]mathTerm{}
+	start := 1
+	for i, level := 1, 0; i < len(tokens); i++ {
+		if tt := tokens[i].TokenType; tt == css.FunctionToken || tt == css.LeftParenthesisToken {
+			level++
+		} else if tt == css.RightParenthesisToken && 0 < level {
+			level--
+		} else if level == 0 && (tt == css.CommaToken || tt == css.RightParenthesisToken) {
+			terms, ok := c.parseMathSum(tokens[start:i])
+			if !ok {
+				return mathFactor{}, false
+			}
+			args = append(args, terms)
+			start = i + 1
+		}
+	}
+
+	if fun == "calc(" {
+		if len(args) != 1 {
+			return mathFactor{}, false
+		}
+		return sumFactor(args[0]), true
+	} else if fun == "min(" || fun == "max(" || fun == "clamp(" && len(args) == 3 {
+		// compute the function when all arguments are numbers with the same unit
+		nums := make([]float64, len(args))
+		numeric := true
+		for i, arg := range args {
+			if f := sumFactor(arg); !f.numeric || f.unit != sumFactor(args[0]).unit {
+				numeric = false
+				break
+			} else {
+				nums[i] = f.num
+			}
+		}
+		if numeric {
+			f := sumFactor(args[0])
+			f.data = nil
+			if fun == "clamp(" {
+				f.num = nums[1]
+				if nums[2] < f.num {
+					f.num = nums[2]
+				}
+				if f.num < nums[0] {
+					f.num = nums[0]
+				}
+			} else {
+				for _, num := range nums[1:] {
+					if fun == "min(" && num < f.num || fun == "max(" && f.num < num {
+						f.num = num
+					}
+				}
+			}
+			return f, true
+		}
+
+		value := []byte(fun)
+		for i, arg := range args {
+			if 0 < i {
+				value = append(value, ',')
+			}
+			value = append(value, c.mathSum(arg)...)
+		}
+		return mathFactor{value: append(value, ')')}, true
+	}
+	return mathFactor{}, false
+}
+
+// parseMathSum parses and simplifies a sum of products, such as 100% - 2*10px.
+func (c *cssMinifier) parseMathSum(tokens []css.Token) ([]mathTerm, bool) {
+	terms := []mathTerm{}
+	neg := false
+	i := skipWhitespace(tokens, 0)
+	for {
+		term := mathTerm{neg: neg}
+		for {
+			div := false
+			if 0 < len(term.factors) {
+				div = tokens[i].Data[0] == '/'
+				i = skipWhitespace(tokens, i+1)
+			}
+			if len(tokens) <= i {
+				return nil, false
+			}
+
+			var f mathFactor
+			var ok bool
+			switch tokens[i].TokenType {
+			case css.NumberToken, css.PercentageToken, css.DimensionToken:
+				f, ok = parseMathNumber(tokens[i])
+				i++
+			case css.LeftParenthesisToken, css.FunctionToken:
+				end := matchingParenthesis(tokens, i)
+				if end == -1 {
+					return nil, false
+				} else if tokens[i].TokenType == css.LeftParenthesisToken {
+					var terms []mathTerm
+					terms, ok = c.parseMathSum(tokens[i+1 : end])
+					f = sumFactor(terms)
+				} else if isMathFunction(tokens[i].Data) {
+					f, ok = c.parseMathFunction(tokens[i : end+1])
+				} else {
+					f, ok = mathFactor{value: []byte{}}, true
+					for _, t := range tokens[i : end+1] {
+						f.value = append(f.value, t.Data...)
+					}
+				}
+				i = end + 1
+			case css.IdentToken:
+				f, ok = mathFactor{value: parse.Copy(tokens[i].Data)}, true
+				i++
+			}
+			if !ok {
+				return nil, false
+			}
+			f.div = div
+			term.factors = append(term.factors, f)
+
+			j := skipWhitespace(tokens, i)
+			if j == len(tokens) || tokens[j].TokenType != css.DelimToken || tokens[j].Data[0] != '*' && tokens[j].Data[0] != '/' {
+				break
+			}
+			i = j
+		}
+		terms = append(terms, simplifyMathProduct(term)...)
+
+		// addition and subtraction must be surrounded by whitespace
+		if len(tokens) <= i {
+			return simplifyMathSum(terms), true
+		} else if tokens[i].TokenType != css.WhitespaceToken {
+			return nil, false
+		}
+		i = skipWhitespace(tokens, i)
+		if len(tokens) <= i {
+			return simplifyMathSum(terms), true
+		} else if i+1 == len(tokens) || tokens[i].TokenType != css.DelimToken || tokens[i].Data[0] != '+' && tokens[i].Data[0] != '-' || tokens[i+1].TokenType != css.WhitespaceToken {
+			return nil, false
+		}
+		neg = tokens[i].Data[0] == '-'
+		i = skipWhitespace(tokens, i+1)
+	}
+}
+
+func skipWhitespace(tokens []css.Token, i int) int {
+	for i < len(tokens) && tokens[i].TokenType == css.WhitespaceToken {
+		i++
+	}
+	return i
+}
+
+// matchingParenthesis returns the index of the closing parenthesis of the function or parenthesis at i, or -1 if it's missing.
+func matchingParenthesis(tokens []css.Token, i int) int {
+	level := 0
+	for ; i < len(tokens); i++ {
+		if tt := tokens[i].TokenType; tt == css.FunctionToken || tt == css.LeftParenthesisToken {
+			level++
+		} else if tt == css.RightParenthesisToken {
+			if level--; level == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseMathNumber(t css.Token) (mathFactor, bool) {
+	n := len(t.Data)
+	if t.TokenType == css.PercentageToken {
+		n--
+	} else if t.TokenType == css.DimensionToken {
+		n = parse.Number(t.Data)
+	}
+	num, err := strconv.ParseFloat(string(t.Data[:n]), 64)
+	if err != nil {
+		return mathFactor{}, false
+	}
+	return mathFactor{numeric: true, num: num, unit: string(parse.ToLower(parse.Copy(t.Data[n:]))), data: t.Data[:n]}, true
+}
+
+// sumFactor returns the sum as a factor, which is numeric for a single number.
+func sumFactor(terms []mathTerm) mathFactor {
+	if len(terms) == 1 && len(terms[0].factors) == 1 && terms[0].factors[0].numeric {
+		f := terms[0].factors[0]
+		if terms[0].neg {
+			f.num = -f.num
+			f.data = nil
+		}
+		return f
+	} else if len(terms) == 1 && len(terms[0].factors) == 1 && !terms[0].neg {
+		return terms[0].factors[0]
+	}
+	return mathFactor{sum: terms}
+}
+
+// simplifyMathProduct multiplies the numbers of a product, such as 2*10px/4 to 5px, and returns the terms that the product expands to.
+func simplifyMathProduct(term mathTerm) []mathTerm {
+	// remove parentheses around products
+	factors := []mathFactor{}
+	for _, f := range term.factors {
+		if f.sum != nil && len(f.sum) == 1 && (!f.div || len(f.sum[0].factors) == 1) {
+			if f.sum[0].neg {
+				term.neg = !term.neg
+			}
+			for _, g := range f.sum[0].factors {
+				g.div = g.div != f.div
+				factors = append(factors, g)
+			}
+		} else {
+			factors = append(factors, f)
+		}
+	}
+	if len(factors) == 1 && factors[0].sum != nil && !factors[0].div {
+		// a parenthesized sum in a sum
+		terms := factors[0].sum
+		if term.neg {
+			for i := range terms {
+				terms[i].neg = !terms[i].neg
+			}
+		}
+		return terms
+	}
+
+	// multiply the numbers when the product has at most one unit and it only divides by non-zero numbers
+	coef := mathFactor{numeric: true, num: 1.0}
+	n := 0
+	div := false
+	for _, f := range factors {
+		if f.numeric {
+			if f.div && (f.unit != "" || f.num == 0.0) || !f.div && f.unit != "" && coef.unit != "" {
+				return []mathTerm{{term.neg, factors}}
+			} else if f.div {
+				coef.num /= f.num
+				div = true
+			} else {
+				coef.num *= f.num
+				if f.unit != "" {
+					coef.unit = f.unit
+				}
+			}
+			coef.data = f.data
+			n++
+		}
+	}
+	if n < 2 || div && !isExact(coef.num) {
+		return []mathTerm{{term.neg, factors}} // such as 1/3, which has no exact decimal
+	}
+	coef.data = nil
+
+	others := []mathFactor{}
+	for _, f := range factors {
+		if !f.numeric {
+			others = append(others, f)
+		}
+	}
+	if coef.num == 1.0 && coef.unit == "" && 0 < len(others) && !others[0].div {
+		return []mathTerm{{term.neg, others}}
+	}
+	return []mathTerm{{term.neg, append([]mathFactor{coef}, others...)}}
+}
+
+// isExact returns true if the number is written exactly with the precision of mathFactor.
+func isExact(num float64) bool {
+	exact, err := strconv.ParseFloat(strconv.FormatFloat(num, 'g', 15, 64), 64)
+	return err == nil && exact == num
+}
+
+// simplifyMathSum adds the numbers with the same unit, such as 100% - 10px + 5px to 100% - 5px.
+func simplifyMathSum(terms []mathTerm) []mathTerm {
+	sum := []mathTerm{}
+	units := map[string]int{} // unit to index in sum
+	for _, term := range terms {
+		if len(term.factors) != 1 || !term.factors[0].numeric {
+			sum = append(sum, term)
+			continue
+		}
+		f := term.factors[0]
+		if term.neg {
+			f.num = -f.num
+			f.data = nil
+		}
+		if i, ok := units[f.unit]; ok {
+			sum[i].factors[0].num += f.num
+			sum[i].factors[0].data = nil
+		} else {
+			units[f.unit] = len(sum)
+			sum = append(sum, mathTerm{false, []mathFactor{f}})
+		}
+	}
+
+	// subtract negative numbers, and negate the number of the first term
+	for i := range sum {
+		if f := &sum[i].factors[0]; f.numeric && (0 < i && f.num < 0.0 || i == 0 && sum[i].neg) {
+			f.num = -f.num
+			f.data = nil
+			sum[i].neg = !sum[i].neg
+		}
+	}
+	return sum
+}
+
+// mathSum returns the minified sum, where addition and subtraction are surrounded by whitespace.
+func (c *cssMinifier) mathSum(terms []mathTerm) []byte {
+	b := []byte{}
+	for i, term := range terms {
+		if 0 < i {
+			if term.neg {
+				b = append(b, " - "...)
+			} else {
+				b = append(b, " + "...)
+			}
+		} else if term.neg {
+			b = append(b, "-1*"...)
+		}
+		for j, f := range term.factors {
+			if f.div {
+				b = append(b, '/')
+			} else if 0 < j {
+				b = append(b, '*')
+			}
+			b = append(b, c.mathFactor(f)...)
+		}
+	}
+	return b
+}
+
+func (c *cssMinifier) mathFactor(f mathFactor) []byte {
+	if f.numeric {
+		num := f.data
+		if num == nil {
+			num = strconv.AppendFloat(nil, f.num, 'g', 15, 64)
+		}
+		if !c.keepCSS2 {
+			num = minify.Number(num, c.o.Decimals)
+		} else {
+			num = minify.Decimal(num, c.o.Decimals)
+		}
+		return append(parse.Copy(num), f.unit...)
+	} else if f.sum != nil {
+		return append(append([]byte{'('}, c.mathSum(f.sum)...), ')')
+	}
+	return f.value
+}
@@ -0,0 +1,120 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"bytes"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/css"
+)
+
+// isDelim returns true for the comparison delimiters of media query ranges such as (400px<=width), which need no whitespace around them.
+func isDelim(t css.Token) bool {
+	return t.TokenType == css.DelimToken && (t.Data[0] == '<' || t.Data[0] == '>' || t.Data[0] == '=')
+}
+
+// isKeyword returns true for an identifier with the given name.
+func isKeyword(t css.Token, name string) bool {
+	return t.TokenType == css.IdentToken && parse.EqualFold(t.Data, []byte(name))
+}
+
+// minifyConditions shortens the numbers and dimensions of a media query list or supports condition, and removes whitespace inside
+// parentheses and around their comparisons. The numbers and identifiers of function arguments, such as those of selector(), are kept.
+func (c *cssMinifier) minifyConditions(values []css.Token, lowercase bool) []css.Token {
+	tokens := make([]css.Token, 0, len(values))
+	level, parens := 0, 0 // level of function arguments and of parentheses
+	for i, t := range values {
+		switch t.TokenType {
+		case css.FunctionToken:
+			level++
+		case css.LeftParenthesisToken:
+			parens++
+			if 0 < level {
+				level++
+			}
+		case css.RightParenthesisToken:
+			parens--
+			if 0 < level {
+				level--
+			}
+		case css.NumberToken, css.PercentageToken, css.DimensionToken:
+			if level == 0 {
+				t.TokenType, t.Data = c.shortenToken(0, t.TokenType, t.Data)
+			}
+		case css.IdentToken:
+			if level == 0 && lowercase && (len(t.Data) < 2 || t.Data[0] != '-' || t.Data[1] != '-') {
+				t.Data = parse.ToLower(t.Data)
+			}
+		case css.WhitespaceToken:
+			if 0 < len(tokens) {
+				if prev := tokens[len(tokens)-1]; prev.TokenType == css.LeftParenthesisToken || prev.TokenType == css.FunctionToken || 0 < parens && isDelim(prev) {
+					continue
+				}
+			}
+			if i+1 < len(values) && (values[i+1].TokenType == css.RightParenthesisToken || 0 < parens && isDelim(values[i+1])) {
+				continue
+			}
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// minifyMediaQueries minifies a media query list and removes all and from queries such as all and (min-width:100px). Media queries
+// are case-insensitive except for custom media queries.
+func (c *cssMinifier) minifyMediaQueries(values []css.Token) []css.Token {
+	values = c.minifyConditions(values, true)
+	tokens := make([]css.Token, 0, len(values))
+	for start := 0; start < len(values); {
+		end := start
+		for end < len(values) && values[end].TokenType != css.CommaToken {
+			end++
+		}
+
+		query := values[start:end]
+		for 0 < len(query) && query[0].TokenType == css.WhitespaceToken {
+			query = query[1:]
+		}
+		if 4 < len(query) && isKeyword(query[0], "all") && query[1].TokenType == css.WhitespaceToken && isKeyword(query[2], "and") &&
+			query[3].TokenType == css.WhitespaceToken && query[4].TokenType == css.LeftParenthesisToken {
+			query = query[4:]
+		}
+		if start == 0 && 0 < len(query) && query[0].TokenType != css.LeftParenthesisToken {
+			tokens = append(tokens, css.Token{TokenType: css.WhitespaceToken, Data: spaceBytes}) // @media screen
+		}
+		tokens = append(tokens, query...)
+		if end < len(values) {
+			tokens = append(tokens, values[end])
+		}
+		start = end + 1
+	}
+	return tokens
+}
+
+// isMedia returns true for the prelude of a @media rule.
+func isMedia(prelude []byte) bool {
+	return 6 <= len(prelude) && parse.EqualFold(prelude[:6], []byte("@media")) && (len(prelude) == 6 || !isNameByte(prelude[6]))
+}
+
+// mergeMedia merges consecutive @media rules with the same media queries.
+func mergeMedia(list []node) []node {
+	j := 0
+	for _, n := range list {
+		switch n := n.(type) {
+		case *rulesetNode:
+			n.list = mergeMedia(n.list)
+		case *atRuleNode:
+			if n.block {
+				n.list = mergeMedia(n.list)
+				if 0 < j && isMedia(n.prelude) {
+					if prev, ok := list[j-1].(*atRuleNode); ok && prev.block && bytes.Equal(prev.prelude, n.prelude) {
+						prev.list = mergeMedia(append(prev.list, n.list...))
+						continue
+					}
+				}
+			}
+		}
+		list[j] = n
+		j++
+	}
+	return list[:j]
+}
@@ -0,0 +1,237 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"bytes"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// propFamilies maps properties to the shorthand that sets them, when that isn't the part before the first dash. Physical and logical
+// properties that may set the same box dimension, such as height and block-size, are of the same family.
+var propFamilies = map[string]string{
+	"line-height":     "font",
+	"top":             "inset",
+	"right":           "inset",
+	"bottom":          "inset",
+	"left":            "inset",
+	"row-gap":         "gap",
+	"column-gap":      "gap",
+	"grid-gap":        "gap",
+	"grid-row-gap":    "gap",
+	"grid-column-gap": "gap",
+	"columns":         "column",
+	"align-content":   "place",
+	"align-items":     "place",
+	"align-self":      "place",
+	"justify-content": "place",
+	"justify-items":   "place",
+	"justify-self":    "place",
+	"width":           "size",
+	"height":          "size",
+	"min-width":       "size",
+	"min-height":      "size",
+	"max-width":       "size",
+	"max-height":      "size",
+	"block-size":      "size",
+	"inline-size":     "size",
+	"min-block-size":  "size",
+	"min-inline-size": "size",
+	"max-block-size":  "size",
+	"max-inline-size": "size",
+}
+
+// propFamily returns the family of a property without vendor prefix, properties of the same family may override each other such as margin and margin-top.
+func propFamily(prop []byte) string {
+	if 1 < len(prop) && prop[0] == '-' && prop[1] == '-' {
+		return string(prop) // custom property
+	} else if prop[0] == '-' {
+		if i := bytes.IndexByte(prop[1:], '-'); i != -1 {
+			prop = prop[i+2:]
+		}
+	}
+	if family, ok := propFamilies[string(prop)]; ok {
+		return family
+	} else if i := bytes.IndexByte(prop, '-'); i != -1 {
+		prop = prop[:i]
+	}
+	return string(prop)
+}
+
+// safePseudos are the pseudo-classes and pseudo-elements supported by all browsers. Selectors with other pseudos are not joined with
+// other selectors, as a browser drops the entire ruleset when it doesn't support one of its selectors.
+var safePseudos = map[string]bool{
+	"active":           true,
+	"after":            true,
+	"before":           true,
+	"checked":          true,
+	"disabled":         true,
+	"empty":            true,
+	"enabled":          true,
+	"first-child":      true,
+	"first-letter":     true,
+	"first-line":       true,
+	"first-of-type":    true,
+	"focus":            true,
+	"hover":            true,
+	"lang":             true,
+	"last-child":       true,
+	"last-of-type":     true,
+	"link":             true,
+	"not":              true,
+	"nth-child":        true,
+	"nth-last-child":   true,
+	"nth-last-of-type": true,
+	"nth-of-type":      true,
+	"only-child":       true,
+	"only-of-type":     true,
+	"root":             true,
+	"target":           true,
+	"visited":          true,
+}
+
+// isSafeSelector returns true if the selector only has pseudo-classes and pseudo-elements that are supported by all browsers.
+func isSafeSelector(selector []byte) bool {
+	for i := 0; i < len(selector); i++ {
+		switch selector[i] {
+		case '\\':
+			i++
+		case '[':
+			for i < len(selector) && selector[i] != ']' {
+				if selector[i] == '\\' {
+					i++
+				}
+				i++
+			}
+		case ':':
+			i++
+			if i < len(selector) && selector[i] == ':' {
+				i++
+			}
+			start := i
+			for i < len(selector) && (selector[i] == '-' || selector[i] == '_' || 'a' <= selector[i]|0x20 && selector[i]|0x20 <= 'z' || '0' <= selector[i] && selector[i] <= '9') {
+				i++
+			}
+			if !safePseudos[string(parse.ToLower(parse.Copy(selector[start:i])))] {
+				return false
+			}
+			i--
+		}
+	}
+	return true
+}
+
+// isMergeable returns true if the ruleset only has valid declarations.
+func (r *rulesetNode) isMergeable() bool {
+	for _, n := range r.list {
+		if decl, ok := n.(*declNode); !ok || decl.prop == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// conflicts returns true if the rulesets set the same or related properties, whose order may not be changed.
+func (r *rulesetNode) conflicts(s *rulesetNode) bool {
+	families := map[string]bool{}
+	for _, n := range r.list {
+		families[propFamily(n.(*declNode).prop)] = true
+	}
+	if families["all"] && 0 < len(s.list) {
+		return true
+	}
+	for _, n := range s.list {
+		if family := propFamily(n.(*declNode).prop); families[family] || family == "all" && 0 < len(r.list) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rulesetNode) isSafe() bool {
+	for _, selector := range r.selectors {
+		if !isSafeSelector(selector) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *rulesetNode) hasSelector(selector []byte) bool {
+	for _, s := range r.selectors {
+		if bytes.Equal(s, selector) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rulesetNode) equalSelectors(s *rulesetNode) bool {
+	for _, selector := range r.selectors {
+		if !s.hasSelector(selector) {
+			return false
+		}
+	}
+	for _, selector := range s.selectors {
+		if !r.hasSelector(selector) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *rulesetNode) equalDecls(s *rulesetNode) bool {
+	if len(r.list) != len(s.list) {
+		return false
+	}
+	for i := range r.list {
+		if !bytes.Equal(r.list[i].(*declNode).data, s.list[i].(*declNode).data) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeRulesets merges rulesets with identical selectors, and joins the selectors of rulesets with identical declarations, within each block.
+// A ruleset is merged into an earlier one only when its declarations can be moved before the rulesets in between without changing the cascade,
+// that is when those don't set the same or related properties. Rulesets are never moved across other rules such as at-rules and comments.
+func mergeRulesets(list []node) []node {
+	merged := list[:0]
+	for _, n := range list {
+		switch n := n.(type) {
+		case *atRuleNode:
+			n.list = mergeRulesets(n.list)
+		case *rulesetNode:
+			if n.isMergeable() && mergeRuleset(merged, n) {
+				continue
+			}
+		}
+		merged = append(merged, n)
+	}
+	return merged
+}
+
+// mergeRuleset merges the ruleset into one of the preceding rulesets and returns true if it succeeded.
+func mergeRuleset(list []node, r *rulesetNode) bool {
+	for i := len(list) - 1; 0 <= i; i-- {
+		prev, ok := list[i].(*rulesetNode)
+		if !ok || !prev.isMergeable() {
+			return false
+		}
+
+		equalSelectors := prev.equalSelectors(r)
+		if prev.equalDecls(r) && (equalSelectors || prev.isSafe() && r.isSafe()) {
+			for _, selector := range r.selectors {
+				if !prev.hasSelector(selector) {
+					prev.selectors = append(prev.selectors, selector)
+				}
+			}
+			return true
+		} else if equalSelectors {
+			prev.list = append(prev.list, r.list...)
+			return true
+		} else if prev.conflicts(r) {
+			return false
+		}
+	}
+	return false
+}
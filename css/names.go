@@ -0,0 +1,197 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"bytes"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/parse/v2"
+)
+
+// unescapeIdent returns the name of an identifier with escapes such as col-1\/2 or \31 0, so that it equals the name used in documents.
+func unescapeIdent(ident []byte) []byte {
+	if bytes.IndexByte(ident, '\\') == -1 {
+		return ident
+	}
+	b := make([]byte, 0, len(ident))
+	for i := 0; i < len(ident); i++ {
+		if ident[i] != '\\' || i+1 == len(ident) {
+			b = append(b, ident[i])
+			continue
+		}
+		i++
+		n := 0
+		for n < 6 && i+n < len(ident) && isHexDigit(ident[i+n]) {
+			n++
+		}
+		if n == 0 {
+			b = append(b, ident[i])
+			continue
+		}
+		r, _ := strconv.ParseUint(string(ident[i:i+n]), 16, 32)
+		if r == 0 || 0x10FFFF < r || 0xD800 <= r && r <= 0xDFFF {
+			r = utf8.RuneError
+		}
+		b = append(b, string(rune(r))...)
+		i += n - 1
+		if i+1 < len(ident) && (ident[i+1] == ' ' || ident[i+1] == '\t' || ident[i+1] == '\n') {
+			i++
+		}
+	}
+	return b
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c|0x20 && c|0x20 <= 'f'
+}
+
+// renameURLFragment renames the id of a minified URL token such as url(#gradient) that refers to an element in the same document.
+func renameURLFragment(names *minify.Names, data []byte) []byte {
+	uri := parse.TrimWhitespace(data[4 : len(data)-1])
+	delim := byte(0)
+	if 1 < len(uri) && (uri[0] == '"' || uri[0] == '\'') {
+		delim = uri[0]
+		uri = uri[1 : len(uri)-1]
+	}
+	if len(uri) < 2 || uri[0] != '#' || bytes.IndexAny(uri, "\\\"' ") != -1 {
+		return data
+	}
+
+	b := append([]byte("url("), delim)
+	if delim == 0 {
+		b = b[:4]
+	}
+	b = append(b, '#')
+	b = append(b, names.ID(uri[1:])...)
+	if delim != 0 {
+		b = append(b, delim)
+	}
+	return append(b, ')')
+}
+
+// renameSelectors renames the class names and ids of the selectors of all rulesets.
+func renameSelectors(names *minify.Names, list []node) {
+	for _, n := range list {
+		switch n := n.(type) {
+		case *rulesetNode:
+			for i, selector := range n.selectors {
+				n.selectors[i] = renameSelector(names, selector)
+			}
+			renameSelectors(names, n.list)
+		case *atRuleNode:
+			renameSelectors(names, n.list)
+		}
+	}
+}
+
+// renameSelector renames the class names and ids of a minified selector, including those of attribute selectors that match the whole
+// value such as [class=btn].
+func renameSelector(names *minify.Names, selector []byte) []byte {
+	b := make([]byte, 0, len(selector))
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		if c == '[' {
+			start := i
+			for i < len(selector) && selector[i] != ']' {
+				if selector[i] == '"' || selector[i] == '\'' {
+					quote := selector[i]
+					for i++; i < len(selector) && selector[i] != quote; i++ {
+						if selector[i] == '\\' {
+							i++
+						}
+					}
+				}
+				i++
+			}
+			if len(selector) <= i {
+				return append(b, selector[start:]...)
+			}
+			b = append(b, '[')
+			b = append(b, renameAttrSelector(names, selector[start+1:i])...)
+			b = append(b, ']')
+			continue
+		} else if c == '\\' && i+1 < len(selector) {
+			b = append(b, c, selector[i+1])
+			i++
+			continue
+		} else if (c == '.' || c == '#') && i+1 < len(selector) && (isNameByte(selector[i+1]) || selector[i+1] == '\\') {
+			start := i + 1
+			for i++; i < len(selector) && (isNameByte(selector[i]) || selector[i] == '\\'); i++ {
+				if selector[i] == '\\' && i+1 < len(selector) {
+					i++
+					if isHexDigit(selector[i]) {
+						for n := 1; n < 6 && i+1 < len(selector) && isHexDigit(selector[i+1]); n++ {
+							i++
+						}
+						if i+1 < len(selector) && selector[i+1] == ' ' {
+							i++
+						}
+					}
+				}
+			}
+			name := unescapeIdent(selector[start:i])
+			b = append(b, c)
+			if c == '.' {
+				b = append(b, names.Class(name)...)
+			} else {
+				b = append(b, names.ID(name)...)
+			}
+			i--
+			continue
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+// renameAttrSelector renames the value of a minified attribute selector without brackets, such as class=btn, id=main or href="#main",
+// when it matches a whole class name or id. Attribute selectors that match part of the value such as class^=icon- or with the
+// case-insensitive flag are kept, so that they no longer match renamed names.
+func renameAttrSelector(names *minify.Names, attr []byte) []byte {
+	i := bytes.IndexByte(attr, '=')
+	if i < 1 {
+		return attr
+	}
+	name, value := attr[:i], attr[i+1:]
+	include := name[len(name)-1] == '~'
+	if include {
+		name = name[:len(name)-1]
+	} else if !isNameByte(name[len(name)-1]) {
+		return attr // ^=, $=, *= or |=
+	}
+
+	quote := byte(0)
+	if 0 < len(value) && (value[0] == '"' || value[0] == '\'') {
+		quote = value[0]
+		if len(value) < 2 || value[len(value)-1] != quote {
+			return attr // flag after the string
+		}
+		value = value[1 : len(value)-1]
+		if bytes.IndexByte(value, '\\') != -1 {
+			return attr
+		}
+	} else {
+		value = unescapeIdent(value)
+	}
+	if len(value) == 0 || bytes.IndexAny(value, " \t\n\r\f\"'") != -1 {
+		return attr
+	}
+
+	b := append([]byte{}, attr[:i+1]...)
+	switch string(parse.ToLower(parse.Copy(name))) {
+	case "class":
+		return append(b, names.Class(value)...)
+	case "id":
+		if !include {
+			return append(b, names.ID(value)...)
+		}
+	case "href":
+		if !include && quote != 0 && 1 < len(value) && value[0] == '#' {
+			b = append(b, quote, '#')
+			b = append(b, names.ID(value[1:])...)
+			return append(b, quote)
+		}
+	}
+	return attr
+}
@@ -0,0 +1,530 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/buffer"
+	"github.com/tdewolff/parse/v2/css"
+)
+
+// parse parses the stylesheet into the tree of minified nodes, including nested rules when enabled.
+func (c *cssMinifier) parse(r io.Reader, isInline bool) ([]node, error) {
+	if c.o.Nesting || c.o.FlattenNesting {
+		return c.parseNested(r, isInline)
+	}
+	c.p = css.NewParser(r, isInline)
+	defer c.p.Restore()
+	return c.parseTree()
+}
+
+// parseNested parses a stylesheet that may have nested rules, which the parser does not support. The nested rules and the at-rules
+// that contain them are split off the token stream and built here, while the remaining runs of statements or declarations are parsed
+// by parseTree. The tokens are slices of the input, so that the nodes keep the positions for the source map.
+func (c *cssMinifier) parseNested(r io.Reader, isInline bool) ([]node, error) {
+	var src []byte
+	if buf, ok := r.(interface{ Bytes() []byte }); ok {
+		src = buf.Bytes()
+	} else {
+		var err error
+		if src, err = ioutil.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+
+	l := css.NewLexer(buffer.NewReader(src))
+	tokens := []css.Token{}
+	for {
+		tt, data := l.Next()
+		if tt == css.ErrorToken {
+			break
+		}
+		tokens = append(tokens, css.Token{TokenType: tt, Data: data})
+	}
+	err := l.Err()
+	l.Restore()
+	if err != io.EOF {
+		return nil, err
+	}
+	return c.parseNestedList(tokens, isInline)
+}
+
+// parseNestedList parses the tokens of a stylesheet or block, where style is true for the declarations of a style rule.
+func (c *cssMinifier) parseNestedList(tokens []css.Token, style bool) ([]node, error) {
+	list := []node{}
+	run := -1 // start of the tokens that are parsed by parseTree
+	flush := func(end int) error {
+		if run == -1 {
+			return nil
+		}
+		nodes, err := c.parseSpan(tokens[run:end], style)
+		if err != nil {
+			return err
+		}
+		list = append(list, nodes...)
+		run = -1
+		return nil
+	}
+
+	for i := 0; i < len(tokens); {
+		end, block := nextItem(tokens, i, style)
+		nested := false
+		if block != -1 {
+			if tokens[i].TokenType == css.AtKeywordToken {
+				nested = isConditional(tokens[i].Data)
+			} else {
+				nested = style || hasBlock(tokens[block+1:end])
+			}
+		}
+		if !nested {
+			if run == -1 {
+				run = i
+			}
+			i = end
+			continue
+		}
+
+		if err := flush(i); err != nil {
+			return nil, err
+		}
+		n, err := c.parseNestedRule(tokens[i:end], block-i, style)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+		i = end
+	}
+	if err := flush(len(tokens)); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// parseNestedRule parses a style rule or conditional at-rule whose block starts at the given token.
+func (c *cssMinifier) parseNestedRule(tokens []css.Token, block int, style bool) (node, error) {
+	body := tokens[block+1:]
+	if 0 < len(body) && body[len(body)-1].TokenType == css.RightBraceToken {
+		body = body[:len(body)-1]
+	}
+
+	start := tokens[0].Data
+	preludes, err := c.parsePreludes(tokens[:block+1])
+	if err != nil {
+		return nil, err
+	}
+	if tokens[0].TokenType == css.AtKeywordToken {
+		prelude := []byte{}
+		if 0 < len(preludes) {
+			prelude = preludes[0]
+		}
+		list, err := c.parseNestedList(body, style)
+		if err != nil {
+			return nil, err
+		}
+		return &atRuleNode{prelude: prelude, block: true, list: list, start: start}, nil
+	}
+
+	if len(preludes) == 0 {
+		// the parser did not recognize the selector, keep it as is
+		preludes = append(preludes, parse.Copy(bytes.TrimSpace(span(tokens[:block]))))
+	}
+	list, err := c.parseNestedList(body, true)
+	if err != nil {
+		return nil, err
+	}
+	return &rulesetNode{selectors: preludes, list: list, start: start}, nil
+}
+
+// parsePreludes returns the minified selectors of a style rule or the prelude of an at-rule, up to and including the opening brace.
+func (c *cssMinifier) parsePreludes(tokens []css.Token) ([][]byte, error) {
+	sub := c.subMinifier(span(tokens), false)
+	defer sub.p.Restore()
+	buf := buffer.NewWriter(make([]byte, 0, 64))
+	sub.w = buf
+
+	for {
+		gt, _, data := sub.p.Next()
+		switch gt {
+		case css.QualifiedRuleGrammar:
+			if err := sub.addSelector(sub.p.Values()); err != nil {
+				return nil, err
+			}
+		case css.BeginRulesetGrammar:
+			err := sub.addSelector(sub.p.Values())
+			return sub.selectors, err
+		case css.BeginAtRuleGrammar:
+			if _, err := sub.minifyItem(gt, data); err != nil {
+				return nil, err
+			}
+			return [][]byte{parse.Copy(buf.Bytes()[:buf.Len()-1])}, nil
+		default:
+			return sub.selectors, nil
+		}
+	}
+}
+
+// parseSpan parses the tokens, which have no nested rules, with parseTree.
+func (c *cssMinifier) parseSpan(tokens []css.Token, isInline bool) ([]node, error) {
+	sub := c.subMinifier(span(tokens), isInline)
+	defer sub.p.Restore()
+	return sub.parseTree()
+}
+
+// subMinifier returns a minifier that parses a part of the stylesheet.
+func (c *cssMinifier) subMinifier(b []byte, isInline bool) *cssMinifier {
+	return &cssMinifier{
+		m:         c.m,
+		p:         css.NewParser(buffer.NewReader(b), isInline),
+		o:         c.o,
+		keepNames: true,
+		keepCSS2:  c.keepCSS2,
+		filename:  c.filename,
+		rootDir:   c.rootDir,
+		rebase:    c.rebase,
+	}
+}
+
+// span returns the input from the first to the last token. The tokens are consecutive slices of the input, and the capacity is
+// kept so that the lexer can append its NULL in place.
+func span(tokens []css.Token) []byte {
+	if len(tokens) == 0 {
+		return []byte{}
+	}
+	n := 0
+	for _, t := range tokens {
+		n += len(t.Data)
+	}
+	return tokens[0].Data[:n]
+}
+
+// nextItem returns the end of the statement or declaration at i, and the index of the opening brace of its block or -1.
+func nextItem(tokens []css.Token, i int, style bool) (int, int) {
+	switch tokens[i].TokenType {
+	case css.WhitespaceToken, css.CommentToken:
+		return i + 1, -1
+	case css.SemicolonToken:
+		if style {
+			return i + 1, -1
+		}
+	}
+
+	custom := tokens[i].TokenType == css.CustomPropertyNameToken // the value of custom properties may have braces
+	level := 0
+	for j := i; j < len(tokens); j++ {
+		switch tokens[j].TokenType {
+		case css.LeftParenthesisToken, css.LeftBracketToken, css.FunctionToken:
+			level++
+		case css.LeftBraceToken:
+			if level == 0 && !custom {
+				return matchingBrace(tokens, j), j
+			}
+			level++
+		case css.RightParenthesisToken, css.RightBracketToken, css.RightBraceToken:
+			if 0 < level {
+				level--
+			}
+		case css.SemicolonToken:
+			if level == 0 {
+				return j + 1, -1
+			}
+		}
+	}
+	return len(tokens), -1
+}
+
+// matchingBrace returns the index after the brace that closes the block at i.
+func matchingBrace(tokens []css.Token, i int) int {
+	level := 0
+	for j := i; j < len(tokens); j++ {
+		switch tokens[j].TokenType {
+		case css.LeftBraceToken:
+			level++
+		case css.RightBraceToken:
+			level--
+			if level == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// hasBlock returns true if the tokens have a block, that is if the rule has nested rules.
+func hasBlock(tokens []css.Token) bool {
+	for _, t := range tokens {
+		if t.TokenType == css.LeftBraceToken {
+			return true
+		}
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////////
+
+// flattenNesting replaces the nested rules by rulesets with the equivalent selectors, and moves the nested at-rules to the top level
+// around rulesets of the parent selectors, where nested @media rules are combined into one. Nested rules whose parent is not a
+// compound selector use :is(), which takes the highest specificity of its selectors and may thus differ slightly from that of nesting.
+func flattenNesting(list []node) []node {
+	flat := make([]node, 0, len(list))
+	for _, n := range list {
+		switch n := n.(type) {
+		case *rulesetNode:
+			if !hasNestedRules(n.list) {
+				flat = append(flat, n)
+				continue
+			}
+			flat = append(flat, flattenBlock(n.list, n.selectors, n.start)...)
+		case *atRuleNode:
+			if n.block {
+				n.list = flattenNesting(n.list)
+			}
+			flat = append(flat, flattenMedia(n)...)
+		default:
+			flat = append(flat, n)
+		}
+	}
+	return flat
+}
+
+// flattenBlock returns the rulesets of the block of a style rule with the given selectors, in order.
+func flattenBlock(list []node, selectors [][]byte, start []byte) []node {
+	flat := []node{}
+	var decls *rulesetNode
+	for _, n := range list {
+		switch n := n.(type) {
+		case *rulesetNode:
+			decls = nil
+			flat = append(flat, flattenBlock(n.list, resolveSelectors(n.selectors, selectors), n.start)...)
+		case *atRuleNode:
+			decls = nil
+			if n.block {
+				n.list = flattenBlock(n.list, selectors, n.start)
+			}
+			flat = append(flat, flattenMedia(n)...)
+		default:
+			if decls == nil {
+				decls = &rulesetNode{selectors: selectors, start: start}
+				flat = append(flat, decls)
+			}
+			decls.list = append(decls.list, n)
+		}
+	}
+	return flat
+}
+
+// flattenMedia moves the @media rules in the block of the @media rule n out of it with the combined media query, such as @media screen
+// and (min-width:100px) for @media (min-width:100px) in @media screen, and splits n around them. The @media rules whose media queries
+// cannot be combined are kept.
+func flattenMedia(n *atRuleNode) []node {
+	if !n.block || !isMedia(n.prelude) || len(n.list) == 0 {
+		return []node{n}
+	}
+	flat := []node{}
+	var rest *atRuleNode
+	for _, child := range n.list {
+		if m, ok := child.(*atRuleNode); ok && m.block && isMedia(m.prelude) {
+			if prelude := combineMedia(n.prelude, m.prelude); prelude != nil {
+				m.prelude = prelude
+				flat = append(flat, m)
+				rest = nil
+				continue
+			}
+		}
+		if rest == nil {
+			rest = &atRuleNode{prelude: n.prelude, block: true, start: n.start}
+			flat = append(flat, rest)
+		}
+		rest.list = append(rest.list, child)
+	}
+	return flat
+}
+
+// combineMedia returns the prelude of a @media rule that applies when both @media rules apply, or nil if their media queries cannot be
+// combined, which is when both have a media type, or when either has several media queries, not or or.
+func combineMedia(a, b []byte) []byte {
+	a, b = parse.TrimWhitespace(a[6:]), parse.TrimWhitespace(b[6:])
+	if !isSimpleMedia(a) || !isSimpleMedia(b) {
+		return nil
+	} else if len(b) != 0 && b[0] != '(' {
+		if len(a) != 0 && a[0] != '(' {
+			return nil
+		}
+		a, b = b, a // the media type comes first
+	}
+
+	if len(a) == 0 {
+		a, b = b, a
+	}
+	prelude := []byte("@media")
+	if len(a) != 0 && a[0] != '(' {
+		prelude = append(prelude, ' ')
+	}
+	prelude = append(prelude, a...)
+	if len(b) != 0 {
+		prelude = append(prelude, " and "...)
+		prelude = append(prelude, b...)
+	}
+	return prelude
+}
+
+// isSimpleMedia returns true for a minified media query without commas, not or or outside parentheses.
+func isSimpleMedia(query []byte) bool {
+	level := 0
+	word := 0
+	for i := 0; i <= len(query); i++ {
+		if i < len(query) && level == 0 && isNameByte(query[i]) {
+			continue
+		} else if level == 0 && word < i {
+			if w := parse.ToLower(parse.Copy(query[word:i])); bytes.Equal(w, []byte("not")) || bytes.Equal(w, []byte("or")) {
+				return false
+			}
+		}
+		if i < len(query) {
+			switch query[i] {
+			case '(':
+				level++
+			case ')':
+				level--
+			case ',':
+				if level == 0 {
+					return false
+				}
+			}
+		}
+		word = i + 1
+	}
+	return true
+}
+
+// hasNestedRules returns true if the block has nested rules or at-rules with a block.
+func hasNestedRules(list []node) bool {
+	for _, n := range list {
+		switch n := n.(type) {
+		case *rulesetNode:
+			return true
+		case *atRuleNode:
+			if n.block {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveSelectors returns the selectors of a nested rule for each of the parent selectors. Selectors with several nesting selectors
+// use :is() with all parent selectors instead, since each & matches any of them, such as :is(.a,.b)+:is(.a,.b) for & + &.
+func resolveSelectors(selectors, parents [][]byte) [][]byte {
+	resolved := make([][]byte, 0, len(selectors)*len(parents))
+	for _, selector := range selectors {
+		list := parents
+		if 1 < len(parents) && 1 < len(nestingSelectors(selector)) {
+			list = [][]byte{append(append([]byte(":is("), bytes.Join(parents, []byte(","))...), ')')}
+		}
+	Parents:
+		for _, parent := range list {
+			b := resolveSelector(selector, parent)
+			for _, prev := range resolved {
+				if bytes.Equal(prev, b) {
+					continue Parents
+				}
+			}
+			resolved = append(resolved, b)
+		}
+	}
+	return resolved
+}
+
+// resolveSelector replaces the nesting selectors & by the parent selector, where relative selectors such as >a or a are relative to &.
+func resolveSelector(selector, parent []byte) []byte {
+	amps := nestingSelectors(selector)
+	if len(amps) == 0 {
+		b := make([]byte, 0, len(parent)+len(selector)+1)
+		b = append(b, parent...)
+		if selector[0] != '>' && selector[0] != '+' && selector[0] != '~' {
+			b = append(b, ' ')
+		}
+		return append(b, selector...)
+	}
+
+	compound := compoundEnd(parent) == len(parent)
+	b := make([]byte, 0, len(selector)+len(amps)*(len(parent)+5))
+	prev := 0
+	for _, i := range amps {
+		b = append(b, selector[prev:i]...)
+		concat := i == 0 // the parent replaces & textually when it starts the selector or its compound selector
+		if compound && !concat {
+			concat = isCombinatorByte(selector[i-1]) || isSimpleStart(parent[0])
+		}
+		if concat && i+1 < len(selector) && isNameByte(selector[i+1]) {
+			concat = false
+		}
+		if concat {
+			b = append(b, parent...)
+		} else {
+			b = append(b, ":is("...)
+			b = append(b, parent...)
+			b = append(b, ')')
+		}
+		prev = i + 1
+	}
+	return append(b, selector[prev:]...)
+}
+
+// isCombinatorByte returns true for the bytes that precede a compound selector.
+func isCombinatorByte(c byte) bool {
+	return c == ' ' || c == '>' || c == '+' || c == '~' || c == '(' || c == ','
+}
+
+// isSimpleStart returns true if a selector starting with the byte can follow another simple selector, that is if it is not a type
+// or universal selector.
+func isSimpleStart(c byte) bool {
+	return c == '.' || c == '#' || c == '[' || c == ':'
+}
+
+// nestingSelectors returns the indices of the nesting selectors &.
+func nestingSelectors(selector []byte) []int {
+	amps := []int{}
+	for i := 0; i < len(selector); i++ {
+		switch c := selector[i]; c {
+		case '\\':
+			i++
+		case '"', '\'':
+			for i++; i < len(selector) && selector[i] != c; i++ {
+				if selector[i] == '\\' {
+					i++
+				}
+			}
+		case '&':
+			amps = append(amps, i)
+		}
+	}
+	return amps
+}
+
+// compoundEnd returns the index of the first combinator of a minified selector, or its length if it is a compound selector.
+func compoundEnd(selector []byte) int {
+	level := 0
+	for i := 0; i < len(selector); i++ {
+		switch c := selector[i]; c {
+		case '\\':
+			i++
+		case '"', '\'':
+			for i++; i < len(selector) && selector[i] != c; i++ {
+				if selector[i] == '\\' {
+					i++
+				}
+			}
+		case '(', '[':
+			level++
+		case ')', ']':
+			level--
+		case ' ', '>', '+', '~':
+			if level == 0 {
+				return i
+			}
+		}
+	}
+	return len(selector)
+}
@@ -0,0 +1,218 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// css2Units are the units supported by all browsers, values with other units such as vh may need a fallback.
+var css2Units = map[string]bool{
+	"cm":  true,
+	"deg": true,
+	"em":  true,
+	"ex":  true,
+	"hz":  true,
+	"in":  true,
+	"khz": true,
+	"mm":  true,
+	"ms":  true,
+	"pc":  true,
+	"pt":  true,
+	"px":  true,
+	"rad": true,
+	"s":   true,
+}
+
+// css2Keywords are the keywords of CSS 2.1 that are supported by all browsers, values with other keywords such as sticky or fit-content
+// may need a fallback.
+var css2Keywords = map[string]bool{}
+
+func init() {
+	for _, keyword := range strings.Fields(`
+		absolute always aqua armenian auto avoid baseline bidi-override black blink block blue bold bolder both bottom capitalize caption
+		center circle cjk-ideographic close-quote collapse compact condensed crosshair cursive dashed decimal decimal-leading-zero default
+		disc dotted double e-resize embed expanded extra-condensed extra-expanded fantasy fixed fuchsia georgian gray green groove hebrew
+		help hidden hide hiragana hiragana-iroha icon inherit inline inline-block inline-table inset inside invert italic justify
+		katakana katakana-iroha landscape large larger left lighter lime line-through list-item lower-alpha lower-greek lower-latin
+		lower-roman lowercase ltr maroon medium menu message-box middle monospace move n-resize narrower navy ne-resize no-close-quote
+		no-open-quote no-repeat none normal nowrap nw-resize oblique olive open-quote orange outset outside overline pointer portrait pre
+		pre-line pre-wrap progress purple red relative repeat repeat-x repeat-y ridge right rtl run-in s-resize sans-serif scroll
+		se-resize semi-condensed semi-expanded separate serif show silver small small-caps small-caption smaller solid square static
+		status-bar sub super sw-resize table table-caption table-cell table-column table-column-group table-footer-group
+		table-header-group table-row table-row-group teal text text-bottom text-top thick thin top transparent ultra-condensed
+		ultra-expanded underline upper-alpha upper-latin upper-roman uppercase visible w-resize wait white wider x-large x-small xx-large
+		xx-small yellow`) {
+		css2Keywords[keyword] = true
+	}
+}
+
+// nameProps are the properties whose values may contain names such as font families or animation names, which aren't keywords.
+var nameProps = map[string]bool{
+	"animation":           true,
+	"animation-name":      true,
+	"counter-increment":   true,
+	"counter-reset":       true,
+	"counter-set":         true,
+	"font":                true,
+	"font-family":         true,
+	"transition":          true,
+	"transition-property": true,
+	"will-change":         true,
+}
+
+// vendorPrefix returns the vendor prefix of a property such as -webkit-, or nil for standard and custom properties.
+func vendorPrefix(prop []byte) []byte {
+	if 1 < len(prop) && prop[0] == '-' && prop[1] != '-' {
+		if i := bytes.IndexByte(prop[1:], '-'); i != -1 {
+			return prop[:i+2]
+		}
+	}
+	return nil
+}
+
+// needsFallback returns true if the value may not be supported by all browsers, so that a preceding declaration of the same property
+// is a fallback. These are values with functions other than url(), vendor prefixes such as -webkit-box, substitutions such as var(),
+// units such as vh or rem, hex colors with alpha, and hacks such as \9. If keywords isn't nil, identifiers that aren't in it such as
+// sticky or fit-content need a fallback as well.
+func needsFallback(value []byte, keywords map[string]bool) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '\\' {
+			return true
+		} else if c == '"' || c == '\'' {
+			for i++; i < len(value) && value[i] != c; i++ {
+				if value[i] == '\\' {
+					i++
+				}
+			}
+		} else if c == '(' {
+			if i < 3 || !bytes.EqualFold(value[i-3:i], []byte("url")) {
+				return true
+			}
+			for i < len(value) && value[i] != ')' {
+				i++
+			}
+		} else if isDigit(c) || c == '.' || (c == '-' || c == '+') && i+1 < len(value) && (isDigit(value[i+1]) || value[i+1] == '.') {
+			i++
+			for i < len(value) && (isDigit(value[i]) || value[i] == '.') {
+				i++
+			}
+			if i+1 < len(value) && value[i]|0x20 == 'e' && (isDigit(value[i+1]) || (value[i+1] == '-' || value[i+1] == '+') && i+2 < len(value) && isDigit(value[i+2])) {
+				for i += 2; i < len(value) && isDigit(value[i]); i++ {
+				}
+			}
+			start := i
+			for i < len(value) && isNameByte(value[i]) {
+				i++
+			}
+			if unit := value[start:i]; 0 < len(unit) && !css2Units[string(parse.ToLower(parse.Copy(unit)))] {
+				return true
+			}
+			i--
+		} else if isNameByte(c) || c == '#' {
+			start := i
+			for i+1 < len(value) && isNameByte(value[i+1]) {
+				i++
+			}
+			if c == '-' && 2 < i-start && value[start+1] != '-' && bytes.IndexByte(value[start+2:i+1], '-') != -1 {
+				return true // vendor prefix such as -webkit-box
+			} else if c == '#' && (i-start == 4 || i-start == 8) {
+				return true // hex color with alpha such as #0008
+			} else if c != '#' && keywords != nil && (len(value) <= i+1 || value[i+1] != '(') && !keywords[string(parse.ToLower(parse.Copy(value[start:i+1])))] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isNameByte(c byte) bool {
+	return c == '-' || c == '_' || 'a' <= c|0x20 && c|0x20 <= 'z' || '0' <= c && c <= '9' || 0x80 <= c
+}
+
+// overrides returns true if the declaration sets all the properties set by the preceding declaration prev, with at least the same importance.
+// Declarations of properties with different vendor prefixes don't override each other, and neither do declarations that may be a fallback.
+func (d *declNode) overrides(prev *declNode) bool {
+	value, important := d.value()
+	prevValue, prevImportant := prev.value()
+	if prevImportant && !important || !bytes.Equal(vendorPrefix(prev.prop), vendorPrefix(d.prop)) {
+		return false
+	} else if !bytes.Equal(prev.prop, d.prop) || !bytes.Equal(prevValue, value) {
+		if 1 < len(d.prop) && d.prop[0] == '-' && d.prop[1] == '-' {
+			return bytes.Equal(prev.prop, d.prop) // custom properties don't fall back to a previous declaration
+		}
+
+		// the preceding declaration is a fallback if the value may not be supported, such as cursor:pointer;cursor:hand
+		keywords := css2Keywords
+		if prop := d.prop[len(vendorPrefix(d.prop)):]; nameProps[string(prop)] {
+			keywords = nil
+		}
+		if needsFallback(prevValue, nil) || needsFallback(value, keywords) {
+			return false
+		} else if bytes.Equal(prev.prop, d.prop) && bytes.HasPrefix(value, append(parse.Copy(prevValue), ' ')) {
+			return false // the value adds components, such as text-decoration:underline;text-decoration:underline dotted
+		}
+	}
+
+	props := definiteProps(d.prop)
+	for _, prevProp := range expandProp(prev.prop) {
+		found := false
+		for _, prop := range props {
+			if prop == prevProp {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// definiteProps returns the longhands that are certainly set by a property, unlike expandProp which returns those that may be set.
+func definiteProps(prop []byte) []string {
+	if prefix := vendorPrefix(prop); prefix != nil {
+		prop = prop[len(prefix):]
+	}
+	if _, ok := shorthandLonghands[string(prop)]; ok {
+		return expandProp(prop)
+	}
+	return []string{string(prop)}
+}
+
+// removeOverridden removes declarations that are overridden by a later declaration in the same block, such as color:red;color:blue or
+// margin-top:0;margin:1px. Declarations are kept when they may be a fallback for a value that isn't supported by all browsers.
+func removeOverridden(list []node) []node {
+	j := 0
+	for i, n := range list {
+		switch n := n.(type) {
+		case *rulesetNode:
+			n.list = removeOverridden(n.list)
+		case *atRuleNode:
+			n.list = removeOverridden(n.list)
+		case *declNode:
+			if n.prop != nil && isOverridden(n, list[i+1:]) {
+				continue
+			}
+		}
+		list[j] = n
+		j++
+	}
+	return list[:j]
+}
+
+func isOverridden(decl *declNode, list []node) bool {
+	for _, n := range list {
+		if next, ok := n.(*declNode); ok && next.prop != nil && next.overrides(decl) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/html"
+)
+
+// implicitTags are inserted by HTML parsers even when they're absent from the document.
+var implicitTags = []string{"html", "head", "body", "tbody"}
+
+// Purge is the set of tag names, class names and ids used by documents. Rulesets whose selectors reference a name that isn't used cannot match
+// any element of those documents and are removed.
+type Purge struct {
+	Tags    map[string]bool
+	Classes map[string]bool
+	IDs     map[string]bool
+
+	// Safelist matches the tag names, class names and ids that are always considered used, such as those added by third-party scripts.
+	Safelist *regexp.Regexp
+}
+
+// NewPurge returns an empty set of used names.
+func NewPurge() *Purge {
+	p := &Purge{
+		Tags:    map[string]bool{},
+		Classes: map[string]bool{},
+		IDs:     map[string]bool{},
+	}
+	for _, tag := range implicitTags {
+		p.Tags[tag] = true
+	}
+	return p
+}
+
+// AddHTML adds the tag names, class names and ids used by an HTML document. All words of scripts, event handler attributes and inline SVG
+// or MathML are added as well, as they may add elements or names.
+func (p *Purge) AddHTML(r io.Reader) error {
+	l := html.NewLexer(r)
+	defer l.Restore()
+
+	script := false
+	for {
+		tt, data := l.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := l.Err(); err != io.EOF {
+				return err
+			}
+			return nil
+		case html.StartTagToken:
+			p.Tags[string(l.Text())] = true
+			script = bytes.Equal(l.Text(), []byte("script"))
+		case html.AttributeToken:
+			val := l.AttrVal()
+			if 1 < len(val) && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+				val = val[1 : len(val)-1]
+			}
+			if name := l.Text(); bytes.Equal(name, []byte("class")) {
+				for _, class := range bytes.Fields(val) {
+					p.Classes[string(class)] = true
+				}
+			} else if bytes.Equal(name, []byte("id")) {
+				p.IDs[string(val)] = true
+			} else if 2 < len(name) && name[0] == 'o' && name[1] == 'n' {
+				p.addWords(val)
+			}
+		case html.TextToken:
+			if script {
+				p.addWords(data)
+			}
+		case html.SvgToken, html.MathToken:
+			p.addWords(data)
+		}
+		if tt != html.StartTagToken && tt != html.AttributeToken && tt != html.StartTagCloseToken {
+			script = false
+		}
+	}
+}
+
+// AddJS adds all words of a script as tag names, class names and ids, as it may add elements or names to a document.
+func (p *Purge) AddJS(r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	p.addWords(b)
+	return nil
+}
+
+func (p *Purge) addWords(b []byte) {
+	for i := 0; i < len(b); i++ {
+		if !isNameByte(b[i]) {
+			continue
+		}
+		start := i
+		for i < len(b) && isNameByte(b[i]) {
+			i++
+		}
+		word := string(b[start:i])
+		p.Tags[string(parse.ToLower([]byte(word)))] = true
+		p.Classes[word] = true
+		p.IDs[word] = true
+	}
+}
+
+// isUsed returns true if the name is in the set or in the safelist.
+func (p *Purge) isUsed(names map[string]bool, name []byte) bool {
+	return names[string(name)] || p.Safelist != nil && p.Safelist.Match(name)
+}
+
+// canMatch returns false if the minified selector references a tag name, class name or id that isn't used. Names inside functional
+// pseudo-classes such as :not() are ignored, as well as selectors with escapes or namespaces.
+func (p *Purge) canMatch(selector []byte) bool {
+	compound := true // at the start of a compound selector
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		switch {
+		case c == '\\' || c == '|':
+			return true
+		case c == ' ' || c == '>' || c == '+' || c == '~':
+			compound = true
+			continue
+		case c == '[':
+			for i < len(selector) && selector[i] != ']' {
+				if selector[i] == '"' || selector[i] == '\'' {
+					quote := selector[i]
+					for i++; i < len(selector) && selector[i] != quote; i++ {
+					}
+				}
+				i++
+			}
+		case c == '(':
+			for level := 1; i+1 < len(selector) && 0 < level; i++ {
+				if selector[i+1] == '(' {
+					level++
+				} else if selector[i+1] == ')' {
+					level--
+				} else if selector[i+1] == '"' || selector[i+1] == '\'' {
+					quote := selector[i+1]
+					for i++; i+1 < len(selector) && selector[i+1] != quote; i++ {
+					}
+				}
+			}
+		case c == '.' || c == '#' || c == ':' || compound && isNameByte(c):
+			start := i
+			if c == '.' || c == '#' || c == ':' {
+				start++
+				if c == ':' && start < len(selector) && selector[start] == ':' {
+					start++
+				}
+			}
+			i = start
+			for i < len(selector) && isNameByte(selector[i]) {
+				i++
+			}
+			if i < len(selector) && selector[i] == '\\' {
+				return true
+			}
+			name := selector[start:i]
+			i--
+			if c == '.' && !p.isUsed(p.Classes, name) || c == '#' && !p.isUsed(p.IDs, name) {
+				return false
+			} else if isNameByte(c) && !p.isUsed(p.Tags, parse.ToLower(parse.Copy(name))) {
+				return false
+			}
+		}
+		compound = false
+	}
+	return true
+}
+
+// isConditional returns true for at-rules whose block has rulesets that apply to the document, such as @media, unlike @keyframes.
+func isConditional(prelude []byte) bool {
+	name := prelude
+	if i := bytes.IndexAny(name, " ({"); i != -1 {
+		name = name[:i]
+	}
+	switch string(parse.ToLower(parse.Copy(name))) {
+	case "@media", "@supports", "@document", "@-moz-document", "@layer", "@container", "@scope", "@starting-style":
+		return true
+	}
+	return false
+}
+
+// purge removes the selectors that cannot match any element of the documents, and the rulesets and conditional at-rules that become empty.
+func (p *Purge) purge(list []node) []node {
+	j := 0
+	for _, n := range list {
+		switch n := n.(type) {
+		case *rulesetNode:
+			selectors := n.selectors[:0]
+			for _, selector := range n.selectors {
+				if p.canMatch(selector) {
+					selectors = append(selectors, selector)
+				}
+			}
+			if len(selectors) == 0 {
+				continue
+			}
+			n.selectors = selectors
+		case *atRuleNode:
+			if n.block && isConditional(n.prelude) {
+				if n.list = p.purge(n.list); len(n.list) == 0 {
+					continue
+				}
+			}
+		}
+		list[j] = n
+		j++
+	}
+	return list[:j]
+}
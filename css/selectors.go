@@ -0,0 +1,233 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/buffer"
+	"github.com/tdewolff/parse/v2/css"
+)
+
+// legacyPseudoElements are the pseudo-elements that all browsers accept with a single colon, such as :before for ::before.
+var legacyPseudoElements = map[string]bool{
+	"before":       true,
+	"after":        true,
+	"first-line":   true,
+	"first-letter": true,
+}
+
+// nthPseudoClasses are the pseudo-classes with an An+B argument, and the pseudo-classes that equal them for the argument 1.
+var nthPseudoClasses = map[string]string{
+	"nth-child(":        "first-child",
+	"nth-last-child(":   "last-child",
+	"nth-of-type(":      "first-of-type",
+	"nth-last-of-type(": "last-of-type",
+}
+
+// addSelector minifies a selector and adds it to the selectors of the ruleset, unless it is a duplicate. The parser splits the
+// selectors at every comma, so that the parts of a selector with a selector list such as :is(.a,.b) are joined again.
+func (c *cssMinifier) addSelector(values []css.Token) error {
+	w := c.w
+	buf := buffer.NewWriter(make([]byte, 0, 16))
+	c.w = buf
+	err := c.minifySelectors(nil, c.minifySelectorTokens(values))
+	c.w = w
+	if err != nil {
+		return err
+	}
+
+	selector := buf.Bytes()
+	if n := len(c.selectors); 0 < n && 0 < openBrackets(c.selectors[n-1]) {
+		selector = append(append(c.selectors[n-1], ','), selector...)
+		c.selectors = c.selectors[:n-1]
+	}
+	for _, prev := range c.selectors {
+		if bytes.Equal(prev, selector) {
+			return nil
+		}
+	}
+	c.selectors = append(c.selectors, selector)
+	return nil
+}
+
+// openBrackets returns the number of parentheses and brackets of the minified selector that are not closed.
+func openBrackets(selector []byte) int {
+	level := 0
+	for i := 0; i < len(selector); i++ {
+		switch c := selector[i]; c {
+		case '\\':
+			i++
+		case '"', '\'':
+			for i++; i < len(selector) && selector[i] != c; i++ {
+				if selector[i] == '\\' {
+					i++
+				}
+			}
+		case '(', '[':
+			level++
+		case ')', ']':
+			level--
+		}
+	}
+	return level
+}
+
+// minifySelectorTokens removes whitespace around column combinators, commas and inside parentheses, removes the universal selector
+// before other simple selectors such as *.a, writes legacy pseudo-elements with a single colon, and shortens the arguments of
+// pseudo-classes such as :nth-child(2n+1) to :nth-child(odd) or :nth-child(1) to :first-child.
+func (c *cssMinifier) minifySelectorTokens(values []css.Token) []css.Token {
+	tokens := make([]css.Token, 0, len(values))
+	for i := 0; i < len(values); i++ {
+		t := values[i]
+		switch t.TokenType {
+		case css.WhitespaceToken:
+			if 0 < len(tokens) {
+				if prev := tokens[len(tokens)-1].TokenType; prev == css.ColumnToken || prev == css.CommaToken || prev == css.FunctionToken || prev == css.LeftParenthesisToken {
+					continue
+				}
+			}
+			if i+1 < len(values) {
+				if next := values[i+1].TokenType; next == css.ColumnToken || next == css.CommaToken || next == css.RightParenthesisToken {
+					continue
+				}
+			}
+		case css.DelimToken:
+			if t.Data[0] == '*' && i+1 < len(values) && (len(tokens) == 0 || !isDelimByte(tokens[len(tokens)-1], '|')) {
+				if next := values[i+1]; next.TokenType == css.HashToken || next.TokenType == css.ColonToken || next.TokenType == css.LeftBracketToken ||
+					isDelimByte(next, '.') {
+					continue // *.a
+				}
+			}
+		case css.ColonToken:
+			if i+2 < len(values) && values[i+1].TokenType == css.ColonToken && values[i+2].TokenType == css.IdentToken &&
+				legacyPseudoElements[string(parse.ToLower(parse.Copy(values[i+2].Data)))] {
+				continue // ::before
+			}
+		case css.FunctionToken:
+			if first, ok := nthPseudoClasses[string(parse.ToLower(parse.Copy(t.Data)))]; ok {
+				if end := matchingParenthesis(values, i); end != -1 {
+					tokens = append(tokens, minifyNth(values[i:end+1], first)...)
+					i = end
+					continue
+				}
+			}
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// isDelimByte returns true for the delimiter c.
+func isDelimByte(t css.Token, c byte) bool {
+	return t.TokenType == css.DelimToken && t.Data[0] == c
+}
+
+// minifyNth shortens the An+B argument of a pseudo-class such as :nth-child(2n+1) from the function to its closing parenthesis, or
+// replaces it by the pseudo-class first for the argument 1 without a selector list.
+func minifyNth(values []css.Token, first string) []css.Token {
+	args := values[1 : len(values)-1]
+	of := len(args)
+	for i, t := range args {
+		if isKeyword(t, "of") {
+			of = i
+			break
+		}
+	}
+
+	anb := []byte{}
+	for _, t := range args[:of] {
+		if t.TokenType != css.WhitespaceToken {
+			anb = append(anb, t.Data...)
+		}
+	}
+	a, b, ok := parseAnB(parse.ToLower(anb))
+	if !ok {
+		return values
+	}
+	if a < 0 && b+a <= 0 {
+		a = 0 // matches b only, such as -n+1
+	} else if 0 < a && b < 0 {
+		b = (b%a + a) % a // such as 2n-1 for 2n+1
+	}
+	if a == 0 && b == 1 && of == len(args) {
+		return []css.Token{{TokenType: css.IdentToken, Data: []byte(first)}}
+	}
+	if short := formatAnB(a, b); len(short) < len(anb) {
+		anb = short
+	}
+
+	fun := css.Token{TokenType: css.FunctionToken, Data: parse.ToLower(parse.Copy(values[0].Data))}
+	tokens := []css.Token{fun, {TokenType: css.IdentToken, Data: anb}}
+	if of < len(args) {
+		selectors := args[of:]
+		for selectors[len(selectors)-1].TokenType == css.WhitespaceToken {
+			selectors = selectors[:len(selectors)-1]
+		}
+		tokens = append(tokens, css.Token{TokenType: css.WhitespaceToken, Data: spaceBytes})
+		tokens = append(tokens, selectors...)
+	}
+	return append(tokens, values[len(values)-1])
+}
+
+// parseAnB parses an An+B argument such as odd, 2n+1, -n+3 or 5, without whitespace.
+func parseAnB(s []byte) (int, int, bool) {
+	switch string(s) {
+	case "odd":
+		return 2, 1, true
+	case "even":
+		return 2, 0, true
+	}
+
+	n := bytes.IndexByte(s, 'n')
+	if n == -1 {
+		b, err := strconv.Atoi(string(s))
+		return 0, b, err == nil
+	}
+
+	a := 1
+	switch string(s[:n]) {
+	case "", "+":
+	case "-":
+		a = -1
+	default:
+		var err error
+		if a, err = strconv.Atoi(string(s[:n])); err != nil {
+			return 0, 0, false
+		}
+	}
+	b := 0
+	if n+1 < len(s) {
+		if s[n+1] != '+' && s[n+1] != '-' {
+			return 0, 0, false
+		}
+		var err error
+		if b, err = strconv.Atoi(string(s[n+1:])); err != nil {
+			return 0, 0, false
+		}
+	}
+	return a, b, true
+}
+
+// formatAnB returns the shortest form of an An+B argument, such as odd for 2n+1.
+func formatAnB(a, b int) []byte {
+	if a == 0 {
+		return []byte(strconv.Itoa(b))
+	} else if a == 2 && b == 1 {
+		return []byte("odd")
+	}
+	s := []byte{}
+	if a == -1 {
+		s = append(s, '-')
+	} else if a != 1 {
+		s = strconv.AppendInt(s, int64(a), 10)
+	}
+	s = append(s, 'n')
+	if 0 < b {
+		s = append(s, '+')
+	}
+	if b != 0 {
+		s = strconv.AppendInt(s, int64(b), 10)
+	}
+	return s
+}
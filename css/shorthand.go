@@ -0,0 +1,331 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"bytes"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/buffer"
+	"github.com/tdewolff/parse/v2/css"
+)
+
+// shorthand is a property whose longhands can be collapsed into it when they're all declared together.
+type shorthand struct {
+	name      string
+	longhands []string
+	fold      func(values [][]byte) []byte // joins the values of the longhands into the value of the shorthand, or returns nil
+}
+
+// shorthands are in order of preference, as border-width and border-top share border-top-width.
+// Shorthands such as border and font are only folded when they don't reset other properties, except for font which also resets
+// properties that cannot be set by it such as font-kerning.
+var shorthands = []shorthand{
+	{"margin", []string{"margin-top", "margin-right", "margin-bottom", "margin-left"}, foldList},
+	{"padding", []string{"padding-top", "padding-right", "padding-bottom", "padding-left"}, foldList},
+	{"border-width", []string{"border-top-width", "border-right-width", "border-bottom-width", "border-left-width"}, foldList},
+	{"border-style", []string{"border-top-style", "border-right-style", "border-bottom-style", "border-left-style"}, foldList},
+	{"border-color", []string{"border-top-color", "border-right-color", "border-bottom-color", "border-left-color"}, foldList},
+	{"border-top", []string{"border-top-width", "border-top-style", "border-top-color"}, foldList},
+	{"border-right", []string{"border-right-width", "border-right-style", "border-right-color"}, foldList},
+	{"border-bottom", []string{"border-bottom-width", "border-bottom-style", "border-bottom-color"}, foldList},
+	{"border-left", []string{"border-left-width", "border-left-style", "border-left-color"}, foldList},
+	{"font", []string{"font-style", "font-variant", "font-weight", "font-stretch", "font-size", "line-height", "font-family"}, foldFont},
+	{"background", []string{"background-image", "background-position", "background-size", "background-repeat", "background-attachment", "background-origin", "background-clip", "background-color"}, foldBackground},
+	{"list-style", []string{"list-style-type", "list-style-position", "list-style-image"}, foldList},
+}
+
+// shorthandLonghands maps the shorthands to their longhands, including border which is never folded.
+var shorthandLonghands = map[string][]string{
+	"border": {"border-top", "border-right", "border-bottom", "border-left"},
+}
+
+// foldedLonghands are the longhands of all shorthands that are folded.
+var foldedLonghands = map[string]bool{}
+
+func init() {
+	for _, sh := range shorthands {
+		shorthandLonghands[sh.name] = sh.longhands
+		for _, longhand := range sh.longhands {
+			foldedLonghands[longhand] = true
+		}
+	}
+}
+
+// isIndependent returns true for properties that don't interact with the other properties of their family.
+func isIndependent(prop string) bool {
+	return bytes.Contains([]byte(prop), []byte("-radius")) || prop == "border-collapse" || prop == "border-spacing" || 12 <= len(prop) && prop[:12] == "border-image"
+}
+
+// expandProp returns the longhands set by a property. Unknown properties of the family of a folded shorthand, such as margin-inline or
+// font-kerning, may set any of the longhands of that family.
+func expandProp(prop []byte) []string {
+	family := propFamily(prop)
+	if prop[0] == '-' && (len(prop) < 2 || prop[1] != '-') {
+		if i := bytes.IndexByte(prop[1:], '-'); i != -1 {
+			prop = prop[i+2:]
+		}
+	}
+
+	name := string(prop)
+	if longhands, ok := shorthandLonghands[name]; ok {
+		expanded := []string{}
+		for _, longhand := range longhands {
+			expanded = append(expanded, expandProp([]byte(longhand))...)
+		}
+		return expanded
+	} else if foldedLonghands[name] || isIndependent(name) {
+		return []string{name}
+	}
+
+	expanded := []string{name}
+	for longhand := range foldedLonghands {
+		if propFamily([]byte(longhand)) == family {
+			expanded = append(expanded, longhand)
+		}
+	}
+	return expanded
+}
+
+// value returns the value of the declaration and whether it is important.
+func (d *declNode) value() ([]byte, bool) {
+	value := d.data[len(d.prop)+1:]
+	if bytes.HasSuffix(value, importantBytes) {
+		return value[:len(value)-len(importantBytes)], true
+	}
+	return value, false
+}
+
+// splitValue splits a minified value into its components separated by spaces, where commas end a component too.
+func splitValue(value []byte) [][]byte {
+	components := [][]byte{}
+	level := 0
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '(':
+			level++
+		case ')':
+			level--
+		case '\\':
+			i++
+		case '"', '\'':
+			for i++; i < len(value) && value[i] != c; i++ {
+				if value[i] == '\\' {
+					i++
+				}
+			}
+		case ' ', ',':
+			if level == 0 {
+				components = append(components, value[start:i])
+				if c == ',' {
+					components = append(components, value[i:i+1])
+				}
+				start = i + 1
+			}
+		}
+	}
+	return append(components, value[start:])
+}
+
+// isSingleValue returns true if the value is a single component that can be used in a shorthand, which excludes CSS-wide keywords and
+// substitutions such as var() that may expand into multiple components.
+func isSingleValue(value []byte) bool {
+	return isShorthandValue(value) && bytes.IndexByte(value, ',') == -1 && len(splitValue(value)) == 1
+}
+
+// isShorthandValue returns true if the value can be used in a shorthand, which excludes CSS-wide keywords and substitutions.
+func isShorthandValue(value []byte) bool {
+	if len(value) == 0 || hasSubstitution(value) {
+		return false
+	}
+	switch css.ToHash(parse.ToLower(parse.Copy(value))) {
+	case css.Inherit, css.Initial, css.Unset:
+		return false
+	}
+	return !bytes.EqualFold(value, []byte("revert")) && !bytes.EqualFold(value, []byte("revert-layer"))
+}
+
+func hasSubstitution(value []byte) bool {
+	value = parse.ToLower(parse.Copy(value))
+	return bytes.Contains(value, []byte("var(")) || bytes.Contains(value, []byte("env(")) || bytes.Contains(value, []byte("attr("))
+}
+
+func foldList(values [][]byte) []byte {
+	for _, value := range values {
+		if !isSingleValue(value) {
+			return nil
+		}
+	}
+	return bytes.Join(values, spaceBytes)
+}
+
+// foldFont only folds values that are valid in the font shorthand, such as the CSS2 values of font-variant and the keywords of font-stretch.
+func foldFont(values [][]byte) []byte {
+	for _, value := range values[:6] {
+		if !isSingleValue(value) {
+			return nil
+		}
+	}
+	family := values[6]
+	if !isShorthandValue(family) {
+		return nil
+	}
+	if !bytes.EqualFold(values[1], []byte("normal")) && !bytes.EqualFold(values[1], []byte("small-caps")) {
+		return nil
+	} else if values[3][0]|0x20 < 'a' || 'z' < values[3][0]|0x20 {
+		return nil // font-stretch percentage
+	}
+
+	b := bytes.Join(values[:5], spaceBytes)
+	b = append(b, '/')
+	b = append(b, values[5]...)
+	b = append(b, ' ')
+	return append(b, family...)
+}
+
+// foldBackground only folds a single background layer.
+func foldBackground(values [][]byte) []byte {
+	for i, value := range values {
+		if !isShorthandValue(value) || bytes.IndexByte(value, ',') != -1 || i != 1 && i != 2 && i != 4 && !isSingleValue(value) {
+			return nil
+		}
+	}
+	if bytes.EqualFold(values[6], []byte("text")) {
+		return nil // background-clip:text is not supported in the shorthand by all browsers
+	}
+
+	b := append([]byte{}, values[0]...)
+	b = append(b, ' ')
+	b = append(b, values[1]...)
+	b = append(b, '/')
+	b = append(b, values[2]...)
+	for _, value := range values[3:] {
+		b = append(b, ' ')
+		b = append(b, value...)
+	}
+	return b
+}
+
+// foldShorthands collapses longhands that are declared together in a block into their shorthand, such as margin-top, margin-right,
+// margin-bottom and margin-left into margin. Longhands are only folded when they're declared once with the same importance,
+// and no other declaration in the block sets any of them.
+func (c *cssMinifier) foldShorthands(list []node) []node {
+	for _, n := range list {
+		switch n := n.(type) {
+		case *rulesetNode:
+			n.list = c.foldShorthands(n.list)
+		case *atRuleNode:
+			n.list = c.foldShorthands(n.list)
+		}
+	}
+	if hasNestedRules(list) {
+		return list // folding would move declarations across nested rules of the same specificity
+	}
+	for _, sh := range shorthands {
+		list = c.foldShorthand(list, sh)
+	}
+	return list
+}
+
+func (c *cssMinifier) foldShorthand(list []node, sh shorthand) []node {
+	decls := make([]*declNode, len(sh.longhands))
+	first := -1
+	for i, n := range list {
+		decl, ok := n.(*declNode)
+		if !ok || decl.prop == nil {
+			continue
+		}
+		j := 0
+		for j < len(sh.longhands) && sh.longhands[j] != string(decl.prop) {
+			j++
+		}
+		if j == len(sh.longhands) {
+			continue
+		} else if decls[j] != nil {
+			return list // declared more than once, such as for fallbacks
+		}
+		decls[j] = decl
+		if first == -1 {
+			first = i
+		}
+	}
+
+	values := make([][]byte, len(decls))
+	important := false
+	for i, decl := range decls {
+		if decl == nil {
+			return list
+		}
+		var imp bool
+		values[i], imp = decl.value()
+		if i == 0 {
+			important = imp
+		} else if imp != important {
+			return list
+		}
+	}
+
+	// no other declaration may set any of the longhands
+	for _, n := range list {
+		if decl, ok := n.(*declNode); ok && !isLonghandOf(decl, decls) {
+			if decl.prop == nil {
+				return list
+			}
+			for _, prop := range expandProp(decl.prop) {
+				for _, longhand := range sh.longhands {
+					if prop == longhand {
+						return list
+					}
+				}
+			}
+		}
+	}
+
+	value := sh.fold(values)
+	if value == nil {
+		return list
+	}
+	data := append([]byte(sh.name+":"), value...)
+	if important {
+		data = append(data, importantBytes...)
+	}
+	folded := &declNode{prop: []byte(sh.name), data: c.minifyDecl(data), start: decls[0].start}
+
+	j := 0
+	for i, n := range list {
+		if i == first {
+			list[j] = folded
+			j++
+		} else if decl, ok := n.(*declNode); !ok || !isLonghandOf(decl, decls) {
+			list[j] = n
+			j++
+		}
+	}
+	return list[:j]
+}
+
+func isLonghandOf(decl *declNode, decls []*declNode) bool {
+	for _, d := range decls {
+		if d == decl {
+			return true
+		}
+	}
+	return false
+}
+
+// minifyDecl minifies a single declaration such as margin:0 0 0 0.
+func (c *cssMinifier) minifyDecl(decl []byte) []byte {
+	p, w := c.p, c.w
+	defer func() {
+		c.p, c.w = p, w
+	}()
+	buf := buffer.NewWriter(make([]byte, 0, len(decl)))
+	c.p = css.NewParser(buffer.NewReader(decl), true)
+	c.w = buf
+	defer c.p.Restore()
+	if gt, _, data := c.p.Next(); gt != css.DeclarationGrammar {
+		return decl
+	} else if _, err := c.minifyItem(gt, data); err != nil {
+		return decl
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,428 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// defaultsQuery approximates the browserslist defaults, which also include browsers by usage statistics that aren't embedded. To stay
+// on the safe side, it includes the versions of the last few years that are still in use, such as those of devices that no longer update.
+const defaultsQuery = "last 2 versions, firefox esr, chrome >= 109, safari >= 15, ios_saf >= 15, samsung >= 20, not dead"
+
+var (
+	lastVersionsRegexp     = regexp.MustCompile(`^last (\d+)( major)? versions?$`)
+	lastBrowserRegexp      = regexp.MustCompile(`^last (\d+) (\w+)( major)? versions?$`)
+	versionCompareRegexp   = regexp.MustCompile(`^(\w+) ?(>=|<=|>|<) ?(\d+(?:\.\d+)*)$`)
+	versionRangeRegexp     = regexp.MustCompile(`^(\w+) (\d+(?:\.\d+)*) ?- ?(\d+(?:\.\d+)*)$`)
+	versionRegexp          = regexp.MustCompile(`^(\w+) (\d+(?:\.\d+)*)$`)
+	queriesSeparatorRegexp = regexp.MustCompile(`\s*,\s*|\s+or\s+`)
+)
+
+// Targets are the browsers that the stylesheet must support, which are used to remove vendor prefixes that none of them need and to
+// derive KeepCSS2, see ParseTargets.
+type Targets struct {
+	// Browsers has the oldest targeted version of each browser, such as chrome, firefox, safari, ios_saf, edge, opera, samsung, android,
+	// and_chr, and_ff or ie.
+	Browsers map[string]float64
+}
+
+// ParseTargets returns the targets of a browserslist query, such as "defaults" or "last 2 versions, not dead, ie 11", evaluated against
+// an embedded compatibility table. Queries are joined by commas or "or", narrowed by "and" and excluded by "not". Supported queries are
+// defaults, dead, firefox esr, last N versions, last N <browser> versions, <browser> <version>, <browser> <from>-<to>, and comparisons
+// such as chrome >= 90. Queries by usage statistics, such as > 0.5%, are not supported.
+func ParseTargets(query string) (*Targets, error) {
+	set, err := evalQueries(strings.ToLower(strings.Join(strings.Fields(query), " ")))
+	if err != nil {
+		return nil, err
+	} else if len(set) == 0 {
+		return nil, fmt.Errorf("query %q doesn't select any browser", query)
+	}
+
+	t := &Targets{Browsers: map[string]float64{}}
+	for b, versions := range set {
+		for v := range versions {
+			if min, ok := t.Browsers[b]; !ok || v < min {
+				t.Browsers[b] = v
+			}
+		}
+	}
+	return t, nil
+}
+
+// versionSet is a set of versions for each browser.
+type versionSet map[string]map[float64]bool
+
+func (s versionSet) add(browser string, version float64) {
+	if s[browser] == nil {
+		s[browser] = map[float64]bool{}
+	}
+	s[browser][version] = true
+}
+
+func (s versionSet) filter(f func(string, float64) bool) versionSet {
+	result := versionSet{}
+	for b, versions := range s {
+		for v := range versions {
+			if f(b, v) {
+				result.add(b, v)
+			}
+		}
+	}
+	return result
+}
+
+func allVersions() versionSet {
+	set := versionSet{}
+	for b, versions := range browserVersions {
+		for _, v := range versions {
+			set.add(b, v)
+		}
+	}
+	return set
+}
+
+func evalQueries(query string) (versionSet, error) {
+	result := versionSet{}
+	for i, q := range queriesSeparatorRegexp.Split(query, -1) {
+		not := strings.HasPrefix(q, "not ")
+		if not {
+			if i == 0 {
+				return nil, fmt.Errorf("query %q must follow another query", q)
+			}
+			q = q[4:]
+		}
+
+		var set versionSet
+		for j, part := range strings.Split(q, " and ") {
+			partSet, err := evalQuery(part)
+			if err != nil {
+				return nil, err
+			} else if j == 0 {
+				set = partSet
+			} else {
+				set = set.filter(func(b string, v float64) bool { return partSet[b][v] })
+			}
+		}
+
+		if not {
+			result = result.filter(func(b string, v float64) bool { return !set[b][v] })
+		} else {
+			for b, versions := range set {
+				for v := range versions {
+					result.add(b, v)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func evalQuery(q string) (versionSet, error) {
+	switch q {
+	case "defaults":
+		return evalQueries(defaultsQuery)
+	case "dead":
+		return allVersions().filter(isDead), nil
+	case "firefox esr", "ff esr":
+		set := versionSet{}
+		for _, v := range firefoxESR {
+			set.add("firefox", v)
+		}
+		return set, nil
+	}
+
+	if m := lastVersionsRegexp.FindStringSubmatch(q); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		set := versionSet{}
+		for b := range browserVersions {
+			lastVersions(set, b, n)
+		}
+		return set, nil
+	} else if m := lastBrowserRegexp.FindStringSubmatch(q); m != nil {
+		b, ok := browserAliases[m[2]]
+		if !ok {
+			return nil, fmt.Errorf("unknown browser %q in query %q", m[2], q)
+		}
+		n, _ := strconv.Atoi(m[1])
+		set := versionSet{}
+		lastVersions(set, b, n)
+		return set, nil
+	} else if m := versionCompareRegexp.FindStringSubmatch(q); m != nil {
+		b, ok := browserAliases[m[1]]
+		if !ok {
+			return nil, fmt.Errorf("unknown browser %q in query %q", m[1], q)
+		}
+		version := parseVersion(m[3])
+		return allVersions().filter(func(browser string, v float64) bool {
+			if browser != b {
+				return false
+			}
+			switch m[2] {
+			case ">=":
+				return version <= v
+			case "<=":
+				return v <= version
+			case ">":
+				return version < v
+			}
+			return v < version
+		}), nil
+	} else if m := versionRangeRegexp.FindStringSubmatch(q); m != nil {
+		b, ok := browserAliases[m[1]]
+		if !ok {
+			return nil, fmt.Errorf("unknown browser %q in query %q", m[1], q)
+		}
+		from, to := parseVersion(m[2]), parseVersion(m[3])
+		return allVersions().filter(func(browser string, v float64) bool {
+			return browser == b && from <= v && v <= to
+		}), nil
+	} else if m := versionRegexp.FindStringSubmatch(q); m != nil {
+		b, ok := browserAliases[m[1]]
+		if !ok {
+			return nil, fmt.Errorf("unknown browser %q in query %q", m[1], q)
+		}
+		version := parseVersion(m[2])
+		for _, v := range browserVersions[b] {
+			if v == version {
+				set := versionSet{}
+				set.add(b, v)
+				return set, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown version %s of %s in query %q", m[2], m[1], q)
+	}
+	return nil, fmt.Errorf("unsupported query %q", q)
+}
+
+func lastVersions(set versionSet, browser string, n int) {
+	versions := browserVersions[browser]
+	if n < len(versions) {
+		versions = versions[len(versions)-n:]
+	}
+	for _, v := range versions {
+		set.add(browser, v)
+	}
+}
+
+// parseVersion returns the major and minor version, such as 4.4 for 4.4.3.
+func parseVersion(s string) float64 {
+	if i := strings.IndexByte(s, '.'); i != -1 {
+		if j := strings.IndexByte(s[i+1:], '.'); j != -1 {
+			s = s[:i+1+j]
+		}
+	}
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+////////////////////////////////////////////////////////////////
+
+// supports returns true if the browser version supports the feature without vendor prefix.
+func supports(feature, browser string, version float64) bool {
+	switch browser {
+	case "and_chr":
+		browser = "chrome"
+	case "and_ff":
+		browser = "firefox"
+	case "samsung":
+		chromium := 0.0
+		for _, v := range samsungChromium {
+			if v[0] <= version {
+				chromium = v[1]
+			}
+		}
+		browser, version = "chrome", chromium
+	case "opera":
+		if 15 <= version {
+			browser, version = "chrome", version+13 // Opera 15 is based on Chromium 28, and later versions on newer Chromium versions
+		}
+	case "edge":
+		if 79 <= version {
+			browser = "chrome"
+		}
+	case "android":
+		if 37 <= version {
+			browser = "chrome"
+		}
+	}
+	min, ok := compatTable[feature][browser]
+	return ok && min <= version
+}
+
+// usesPrefix returns true if the browser version may use declarations with the vendor prefix. Most browsers support some -webkit-
+// prefixed properties, so they are assumed to use them.
+func usesPrefix(prefix, browser string, version float64) bool {
+	switch prefix {
+	case "-webkit-":
+		return browser != "ie"
+	case "-moz-":
+		return browser == "firefox" || browser == "and_ff"
+	case "-ms-":
+		return browser == "ie" || browser == "edge" && version < 79
+	case "-o-":
+		return browser == "opera" && version < 15
+	}
+	return true
+}
+
+// supportsAll returns true if all targeted browsers support the feature.
+func (t *Targets) supportsAll(feature string) bool {
+	for b, v := range t.Browsers {
+		if !supports(feature, b, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// needsPrefix returns true if any targeted browser needs the vendor prefix for the feature.
+func (t *Targets) needsPrefix(prefix, feature string) bool {
+	for b, v := range t.Browsers {
+		if usesPrefix(prefix, b, v) && !supports(feature, b, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepCSS2 returns true if any targeted browser doesn't support hex colors with alpha or rgb() and hsl() with alpha.
+func (t *Targets) keepCSS2() bool {
+	return !t.supportsAll("hex-alpha") || !t.supportsAll("color-alpha")
+}
+
+// prefixedFeature is a vendor prefix and the feature it is used for.
+type prefixedFeature struct {
+	prefix, feature string
+}
+
+// declPrefixes returns the vendor prefixes of the property and value of a declaration, and false if any of them has an unknown feature.
+func declPrefixes(d *declNode) ([]prefixedFeature, bool) {
+	features := []prefixedFeature{}
+	prop := parse.ToLower(parse.Copy(d.prop))
+	if prefix := vendorPrefix(prop); prefix != nil {
+		feature, ok := prefixedProperties[string(prop[len(prefix):])]
+		if !ok {
+			return nil, false
+		}
+		features = append(features, prefixedFeature{string(prefix), feature})
+	}
+
+	value, _ := d.value()
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c == '"' || c == '\'' {
+			for i++; i < len(value) && value[i] != c; i++ {
+				if value[i] == '\\' {
+					i++
+				}
+			}
+		} else if c == '-' && (i == 0 || !isNameByte(value[i-1])) && i+1 < len(value) && value[i+1] != '-' && !isDigit(value[i+1]) && value[i+1] != '.' {
+			j := i + 1
+			for j < len(value) && isNameByte(value[j]) {
+				j++
+			}
+			if j < len(value) && value[j] == '(' {
+				j++
+			}
+			name := parse.ToLower(parse.Copy(value[i:j]))
+			if prefix := vendorPrefix(name); prefix != nil {
+				feature, ok := prefixedValues[string(name[len(prefix):])]
+				if !ok {
+					if feature, ok = prefixedProperties[string(name[len(prefix):])]; !ok {
+						return nil, false
+					}
+				}
+				features = append(features, prefixedFeature{string(prefix), feature})
+			}
+			i = j - 1
+		}
+	}
+	return features, true
+}
+
+// isPrunable returns true if none of the targets needs the vendor prefixes of the declaration, and the block has the declaration without
+// vendor prefixes that replaces it.
+func (t *Targets) isPrunable(d *declNode, list []node) bool {
+	features, ok := declPrefixes(d)
+	if !ok || len(features) == 0 {
+		return false
+	}
+	for _, f := range features {
+		if t.needsPrefix(f.prefix, f.feature) {
+			return false
+		}
+	}
+
+	prop := d.prop
+	if prefix := vendorPrefix(prop); prefix != nil {
+		prop = prop[len(prefix):]
+	}
+	for _, n := range list {
+		if decl, ok := n.(*declNode); ok && decl.prop != nil && bytes.EqualFold(decl.prop, prop) {
+			if features, ok := declPrefixes(decl); ok && len(features) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isPrunableAtRule returns true if none of the targets needs the vendor prefix of an at-rule such as @-webkit-keyframes, and the list has
+// the at-rule without vendor prefix.
+func (t *Targets) isPrunableAtRule(a *atRuleNode, list []node) bool {
+	name := a.prelude[1:]
+	if i := bytes.IndexAny(name, " ({"); i != -1 {
+		name = name[:i]
+	}
+	prefix := vendorPrefix(name)
+	if prefix == nil {
+		return false
+	}
+	feature, ok := prefixedAtRules[string(parse.ToLower(parse.Copy(name[len(prefix):])))]
+	if !ok || t.needsPrefix(string(parse.ToLower(parse.Copy(prefix))), feature) {
+		return false
+	}
+
+	prelude := append([]byte{'@'}, a.prelude[1+len(prefix):]...)
+	for _, n := range list {
+		if b, ok := n.(*atRuleNode); ok && b.block && bytes.EqualFold(b.prelude, prelude) {
+			return true
+		}
+	}
+	return false
+}
+
+// prune removes the declarations and at-rules with vendor prefixes that none of the targets need, when the same block has their
+// counterpart without vendor prefixes.
+func (t *Targets) prune(list []node) []node {
+	pruned := map[int]bool{}
+	for i, n := range list {
+		switch n := n.(type) {
+		case *rulesetNode:
+			n.list = t.prune(n.list)
+		case *atRuleNode:
+			if n.block && t.isPrunableAtRule(n, list) {
+				pruned[i] = true
+			} else if n.block {
+				n.list = t.prune(n.list)
+			}
+		case *declNode:
+			if n.prop != nil && t.isPrunable(n, list) {
+				pruned[i] = true
+			}
+		}
+	}
+
+	j := 0
+	for i, n := range list {
+		if !pruned[i] {
+			list[j] = n
+			j++
+		}
+	}
+	return list[:j]
+}
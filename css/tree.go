@@ -0,0 +1,176 @@
+package css // import "github.com/tdewolff/minify/css"
+
+import (
+	"io"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/buffer"
+	"github.com/tdewolff/parse/v2/css"
+)
+
+// node is an item of a stylesheet or of a block, it is one of *rulesetNode, *atRuleNode, *declNode or *rawNode.
+type node interface{}
+
+// rulesetNode is a qualified rule with its minified selectors.
+type rulesetNode struct {
+	selectors [][]byte
+	list      []node
+	start     []byte // start in the input for source maps
+}
+
+// atRuleNode is an at-rule with its minified prelude such as @media screen, block is false for statements such as @import.
+type atRuleNode struct {
+	prelude []byte
+	block   bool
+	list    []node
+	start   []byte
+}
+
+// declNode is a minified declaration such as color:red, prop is nil for invalid declarations that are kept as is.
+type declNode struct {
+	prop  []byte
+	data  []byte
+	start []byte
+}
+
+// rawNode is a kept comment or a token of an unknown at-rule.
+type rawNode struct {
+	data []byte
+}
+
+// parseTree minifies all items of the stylesheet into a tree of nodes, so that structural passes can change the tree before it is written.
+func (c *cssMinifier) parseTree() ([]node, error) {
+	w := c.w
+	defer func() {
+		c.w = w
+	}()
+	buf := buffer.NewWriter(make([]byte, 0, 64))
+	c.w = buf
+
+	list := []node{}
+	stack := []*[]node{&list}
+	var selectorsStart []byte
+	for {
+		gt, _, data := c.p.Next()
+		switch gt {
+		case css.ErrorGrammar:
+			if err := c.p.Err(); err == io.EOF {
+				return list, nil
+			} else if !isDeclarationError(err) {
+				return nil, err
+			}
+		case css.EndAtRuleGrammar, css.EndRulesetGrammar:
+			if 1 < len(stack) {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		start := c.grammarStart(gt, data)
+		if gt == css.QualifiedRuleGrammar || gt == css.BeginRulesetGrammar {
+			if len(c.selectors) == 0 {
+				selectorsStart = start
+			}
+			if err := c.addSelector(c.p.Values()); err != nil {
+				return nil, err
+			}
+			if gt == css.BeginRulesetGrammar {
+				r := &rulesetNode{selectors: c.selectors, start: selectorsStart}
+				c.selectors = nil
+				*stack[len(stack)-1] = append(*stack[len(stack)-1], r)
+				stack = append(stack, &r.list)
+			}
+			continue
+		}
+
+		buf.Reset()
+		if _, err := c.minifyItem(gt, data); err != nil {
+			return nil, err
+		}
+		b := parse.Copy(buf.Bytes())
+
+		var n node
+		switch gt {
+		case css.BeginAtRuleGrammar:
+			a := &atRuleNode{prelude: b[:len(b)-1], block: true, start: start}
+			*stack[len(stack)-1] = append(*stack[len(stack)-1], a)
+			stack = append(stack, &a.list)
+			continue
+		case css.AtRuleGrammar:
+			n = &atRuleNode{prelude: b, start: start}
+		case css.DeclarationGrammar, css.CustomPropertyGrammar:
+			n = &declNode{prop: parse.Copy(data), data: b, start: start}
+		case css.ErrorGrammar:
+			n = &declNode{data: b}
+		default:
+			if len(b) == 0 {
+				continue // removed comment
+			}
+			n = &rawNode{b}
+		}
+		*stack[len(stack)-1] = append(*stack[len(stack)-1], n)
+	}
+}
+
+// writeTree writes the nodes, where semicolons are written only between declarations and statements.
+func (c *cssMinifier) writeTree(list []node) error {
+	semicolon := false
+	for _, n := range list {
+		if semicolon {
+			if _, err := c.w.Write(semicolonBytes); err != nil {
+				return err
+			}
+			semicolon = false
+		}
+
+		switch n := n.(type) {
+		case *rulesetNode:
+			c.mapData(n.start)
+			for i, selector := range n.selectors {
+				if 0 < i {
+					if _, err := c.w.Write(commaBytes); err != nil {
+						return err
+					}
+				}
+				if _, err := c.w.Write(selector); err != nil {
+					return err
+				}
+			}
+			if err := c.writeBlock(n.list); err != nil {
+				return err
+			}
+		case *atRuleNode:
+			c.mapData(n.start)
+			if _, err := c.w.Write(n.prelude); err != nil {
+				return err
+			}
+			if !n.block {
+				semicolon = true
+			} else if err := c.writeBlock(n.list); err != nil {
+				return err
+			}
+		case *declNode:
+			c.mapData(n.start)
+			if _, err := c.w.Write(n.data); err != nil {
+				return err
+			}
+			semicolon = true
+		case *rawNode:
+			if _, err := c.w.Write(n.data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *cssMinifier) writeBlock(list []node) error {
+	if _, err := c.w.Write(leftBracketBytes); err != nil {
+		return err
+	}
+	if err := c.writeTree(list); err != nil {
+		return err
+	}
+	_, err := c.w.Write(rightBracketBytes)
+	return err
+}
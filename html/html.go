@@ -4,6 +4,7 @@ package html // import "github.com/tdewolff/minify/html"
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 
 	"github.com/tdewolff/minify/v2"
 	"github.com/tdewolff/parse/v2"
@@ -39,6 +40,7 @@ type Minifier struct {
 	KeepDocumentTags        bool
 	KeepEndTags             bool
 	KeepWhitespace          bool
+	Comments                *minify.Comments // nil removes all comments except conditional comments
 }
 
 // Minify minifies HTML data, it reads from r and writes to w.
@@ -57,6 +59,21 @@ func (o *Minifier) Minify(m *minify.M, w io.Writer, r io.Reader, _ map[string]st
 	attrMinifyBuffer := buffer.NewWriter(make([]byte, 0, 64))
 	attrByteBuffer := make([]byte, 0, 64)
 
+	hasTop := false // whether #top refers to an element, otherwise it scrolls to the top and is not renamed
+	if m.Names != nil {
+		var src []byte
+		if buf, ok := r.(interface{ Bytes() []byte }); ok {
+			src = buf.Bytes()
+		} else {
+			var err error
+			if src, err = ioutil.ReadAll(r); err != nil {
+				return err
+			}
+		}
+		hasTop = hasTopID(src)
+		r = buffer.NewReader(src)
+	}
+
 	l := html.NewLexer(r)
 	defer l.Restore()
 
@@ -93,6 +110,10 @@ func (o *Minifier) Minify(m *minify.M, w io.Writer, r io.Reader, _ map[string]st
 				} else if _, err := w.Write(t.Data); err != nil { // downlevel-revealed or short downlevel-hidden
 					return err
 				}
+			} else if o.Comments != nil && o.Comments.Keep(t.Data) {
+				if _, err := w.Write(t.Data); err != nil {
+					return err
+				}
 			}
 		case html.SvgToken:
 			if err := m.MinifyMimetype(svgMimeBytes, w, buffer.NewReader(t.Data), nil); err != nil {
@@ -387,6 +408,10 @@ func (o *Minifier) Minify(m *minify.M, w io.Writer, r io.Reader, _ map[string]st
 						continue
 					}
 
+					if m.Names != nil {
+						val = renameAttrVal(m.Names, attr.Hash, attr.Text, val, hasTop)
+					}
+
 					// CSS and JS minifiers for attribute inline code
 					if attr.Hash == html.Style {
 						attrMinifyBuffer.Reset()
@@ -198,6 +198,31 @@ func TestHTMLKeepConditionalComments(t *testing.T) {
 	}
 }
 
+func TestHTMLComments(t *testing.T) {
+	htmlTests := []struct {
+		policy   string
+		html     string
+		expected string
+	}{
+		{"none", `<!--! MIT --><b>x</b>`, `<b>x</b>`},
+		{"all", `<!-- x --><b>x</b>`, `<!-- x --><b>x</b>`},
+		{"license", `<!-- @license MIT --> <!-- x --> <b>x</b>`, `<!-- @license MIT --><b>x</b>`},
+	}
+
+	m := minify.New()
+	for _, tt := range htmlTests {
+		t.Run(tt.html, func(t *testing.T) {
+			comments, err := minify.NewComments(tt.policy)
+			test.Error(t, err)
+			htmlMinifier := &Minifier{Comments: comments}
+			r := bytes.NewBufferString(tt.html)
+			w := &bytes.Buffer{}
+			err = htmlMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.html, err, w.String(), tt.expected)
+		})
+	}
+}
+
 func TestHTMLKeepWhitespace(t *testing.T) {
 	htmlTests := []struct {
 		html     string
@@ -268,6 +293,59 @@ func TestHTMLURL(t *testing.T) {
 	}
 }
 
+func TestHTMLNames(t *testing.T) {
+	htmlTests := []struct {
+		html     string
+		expected string
+	}{
+		{`<div class=" navigation-header  btn " id="main">x</div>`, `<div class="a b" id=a>x</div>`},
+		{`<label for="main">x</label><input list="main" aria-describedby="main help">`, `<label for=a>x</label><input list=a aria-describedby="a b">`},
+		{`<a href="#main">x</a><a href="#top">x</a><a href="#/about">x</a><a href="other.html#main">x</a>`, `<a href=#a>x</a><a href=#top>x</a><a href=#/about>x</a><a href=other.html#main>x</a>`},
+		{`<h1 id="top">x</h1><a href="#top">x</a>`, `<h1 id=a>x</h1><a href=#a>x</a>`},
+		{`<a href="#top">x</a><p ID = 'top'>`, `<a href=#a>x</a><p id=a>`},
+		{`<a href="#top">x</a><p id="topic" title="top">`, `<a href=#top>x</a><p id=a title=top>`},
+		{`<style>.btn{color:red}</style><p class=btn>`, `<style>.a{color:red}</style><p class=a>`},
+		{`<p data-class="btn" title="main">`, `<p data-class=btn title=main>`},
+	}
+
+	for _, tt := range htmlTests {
+		t.Run(tt.html, func(t *testing.T) {
+			m := minify.New()
+			m.AddFunc("text/css", css.Minify)
+			m.Names = minify.NewNames()
+			r := bytes.NewBufferString(tt.html)
+			w := &bytes.Buffer{}
+			err := Minify(m, w, r, nil)
+			test.Minify(t, tt.html, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestHTMLScriptDefine(t *testing.T) {
+	htmlTests := []struct {
+		html     string
+		expected string
+	}{
+		{`<script>if(DEBUG)console.log("debug");run()</script>`, `<script>run()</script>`},
+		{`<script type="text/javascript">var env=process.env.NODE_ENV</script>`, `<script>var env="production"</script>`},
+		{`<button onclick="DEBUG?debug():run()">`, `<button onclick=run()>`},
+	}
+
+	m := minify.New()
+	m.AddRegexp(regexp.MustCompile("^(application|text)/(x-)?(java|ecma)script$"), &js.Minifier{
+		RemoveDeadCode: true,
+		Define:         map[string]string{"DEBUG": "false", "process.env.NODE_ENV": `"production"`},
+	})
+	for _, tt := range htmlTests {
+		t.Run(tt.html, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.html)
+			w := &bytes.Buffer{}
+			err := Minify(m, w, r, nil)
+			test.Minify(t, tt.html, err, w.String(), tt.expected)
+		})
+	}
+}
+
 func TestSpecialTagClosing(t *testing.T) {
 	m := minify.New()
 	m.AddFunc("text/html", Minify)
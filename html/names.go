@@ -0,0 +1,101 @@
+package html // import "github.com/tdewolff/minify/html"
+
+import (
+	"bytes"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/html"
+)
+
+// idListAttrs are the attributes that refer to elements by a space-separated list of ids.
+var idListAttrs = map[string]bool{
+	"aria-activedescendant": true,
+	"aria-controls":         true,
+	"aria-describedby":      true,
+	"aria-details":          true,
+	"aria-errormessage":     true,
+	"aria-flowto":           true,
+	"aria-labelledby":       true,
+	"aria-owns":             true,
+	"for":                   true,
+	"form":                  true,
+	"headers":               true,
+	"itemref":               true,
+	"list":                  true,
+}
+
+// renameAttrVal renames the class names and ids of an attribute value, including the ids of attributes that refer to elements
+// and same-document URLs such as #main. The URL #top is only renamed if the document has an element with the id top, since it
+// otherwise scrolls to the top of the document.
+func renameAttrVal(names *minify.Names, attr html.Hash, name, val []byte, hasTop bool) []byte {
+	if attr == html.Class {
+		b := []byte{}
+		for _, class := range bytes.Fields(val) {
+			if 0 < len(b) {
+				b = append(b, ' ')
+			}
+			b = append(b, names.Class(class)...)
+		}
+		return b
+	} else if attr == html.Id {
+		return names.ID(val)
+	} else if idListAttrs[string(name)] {
+		b := []byte{}
+		for _, id := range bytes.Fields(val) {
+			if 0 < len(b) {
+				b = append(b, ' ')
+			}
+			b = append(b, names.ID(id)...)
+		}
+		return b
+	} else if (attr == html.Href || attr == html.Usemap) && isFragment(val) && (hasTop || !bytes.Equal(val, []byte("#top"))) {
+		return append([]byte{'#'}, names.ID(val[1:])...)
+	}
+	return val
+}
+
+// isFragment returns true for same-document URLs such as #main, but not for routes such as #/about.
+func isFragment(val []byte) bool {
+	if len(val) < 2 || val[0] != '#' {
+		return false
+	}
+	for _, c := range val[1:] {
+		if !(c == '-' || c == '_' || 'a' <= c|0x20 && c|0x20 <= 'z' || '0' <= c && c <= '9' || 0x80 <= c) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasTopID returns true if the document has an element with the id top, including elements of embedded SVG.
+func hasTopID(b []byte) bool {
+	for i := 0; ; i += 3 {
+		n := bytes.Index(b[i:], []byte("top"))
+		if n == -1 {
+			return false
+		}
+		i += n
+
+		// find id= before the value, which may be quoted and have whitespace around the equal sign
+		j := i
+		if 0 < j && (b[j-1] == '"' || b[j-1] == '\'') {
+			j--
+		}
+		for 0 < j && parse.IsWhitespace(b[j-1]) {
+			j--
+		}
+		if j == 0 || b[j-1] != '=' {
+			continue
+		}
+		j--
+		for 0 < j && parse.IsWhitespace(b[j-1]) {
+			j--
+		}
+		end := i + 3
+		if 2 < j && parse.EqualFold(b[j-2:j], []byte("id")) && parse.IsWhitespace(b[j-3]) &&
+			(end == len(b) || b[end] == '"' || b[end] == '\'' || b[end] == '>' || b[end] == '/' || parse.IsWhitespace(b[end])) {
+			return true
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package js // import "github.com/tdewolff/minify/js"
+
+import (
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// The AST is only built when an option requires knowledge about the program structure, such as renaming variables.
+// Nodes keep references to the input buffer wherever possible so that unchanged parts are written out verbatim.
+
+type stmt interface {
+	isStmt()
+}
+
+type expr interface {
+	isExpr()
+}
+
+////////////////////////////////////////////////////////////////
+
+type blockStmt struct {
+	list  []stmt
+	scope *scope
+}
+
+type emptyStmt struct{}
+
+type exprStmt struct {
+	x expr
+}
+
+type binding struct {
+	target expr // identifier, array or object pattern
+	init   expr
+}
+
+type varDecl struct {
+	tok  js.Hash // Var, Let or Const
+	list []binding
+}
+
+type funcDecl struct {
+	f *funcNode
+}
+
+type classDecl struct {
+	c *classNode
+}
+
+type ifStmt struct {
+	cond expr
+	body stmt
+	els  stmt
+}
+
+type doWhileStmt struct {
+	body stmt
+	cond expr
+}
+
+type whileStmt struct {
+	cond expr
+	body stmt
+}
+
+type forStmt struct {
+	init  stmt // varDecl or exprStmt
+	cond  expr
+	post  expr
+	body  stmt
+	scope *scope
+}
+
+type forInStmt struct {
+	init  stmt // varDecl or exprStmt
+	value expr
+	of    bool
+	await bool
+	body  stmt
+	scope *scope
+}
+
+type branchStmt struct {
+	tok   js.Hash // Break or Continue
+	label []byte
+}
+
+type returnStmt struct {
+	x expr
+}
+
+type throwStmt struct {
+	x expr
+}
+
+type tryStmt struct {
+	body    *blockStmt
+	param   expr
+	catch   *blockStmt
+	finally *blockStmt
+	scope   *scope // scope of the catch parameter
+}
+
+type caseClause struct {
+	test expr // nil for default
+	list []stmt
+}
+
+type switchStmt struct {
+	x     expr
+	cases []caseClause
+	scope *scope
+}
+
+type labeledStmt struct {
+	label []byte
+	body  stmt
+}
+
+type withStmt struct {
+	x    expr
+	body stmt
+}
+
+type debuggerStmt struct{}
+
+type importSpec struct {
+	name  []byte // imported name
+	local *ident
+}
+
+type importStmt struct {
+	def       *ident
+	namespace *ident
+	specs     []importSpec
+	braces    bool
+	module    []byte
+}
+
+type exportSpec struct {
+	local expr // identifier when exporting local bindings, otherwise the name in the other module
+	name  []byte
+}
+
+type exportStmt struct {
+	def       bool
+	decl      stmt // declaration or exprStmt for export default
+	star      bool
+	namespace []byte
+	specs     []exportSpec
+	braces    bool
+	module    []byte
+}
+
+// commentStmt holds a comment that is kept in the output, such as a license.
+type commentStmt struct {
+	data []byte
+}
+
+func (*blockStmt) isStmt()    {}
+func (*emptyStmt) isStmt()    {}
+func (*exprStmt) isStmt()     {}
+func (*varDecl) isStmt()      {}
+func (*funcDecl) isStmt()     {}
+func (*classDecl) isStmt()    {}
+func (*ifStmt) isStmt()       {}
+func (*doWhileStmt) isStmt()  {}
+func (*whileStmt) isStmt()    {}
+func (*forStmt) isStmt()      {}
+func (*forInStmt) isStmt()    {}
+func (*branchStmt) isStmt()   {}
+func (*returnStmt) isStmt()   {}
+func (*throwStmt) isStmt()    {}
+func (*tryStmt) isStmt()      {}
+func (*switchStmt) isStmt()   {}
+func (*labeledStmt) isStmt()  {}
+func (*withStmt) isStmt()     {}
+func (*debuggerStmt) isStmt() {}
+func (*importStmt) isStmt()   {}
+func (*exportStmt) isStmt()   {}
+func (*commentStmt) isStmt()  {}
+
+////////////////////////////////////////////////////////////////
+
+// ident is an identifier reference or binding, v is nil for property names and unresolved references.
+type ident struct {
+	name []byte
+	v    *variable
+	pos  int
+}
+
+// literal is any token that is written out as is, such as numbers, strings, regular expressions and keywords like this or null.
+type literal struct {
+	tt   js.TokenType
+	data []byte
+	pos  int
+}
+
+type templateExpr struct {
+	tag   expr
+	parts [][]byte // raw template tokens, including the backticks and ${ }
+	list  []expr
+}
+
+type arrayExpr struct {
+	list []expr // nil elements are holes
+}
+
+type propKind int
+
+const (
+	propInit propKind = iota
+	propSpread
+	propMethod
+	propGet
+	propSet
+	propField
+)
+
+type property struct {
+	kind      propKind
+	static    bool
+	key       expr // literal for names, strings and numbers, or any expression when computed
+	computed  bool
+	shorthand bool
+	value     expr // value, initializer or funcExpr for methods
+}
+
+type objectExpr struct {
+	list []property
+}
+
+type funcNode struct {
+	name      *ident
+	async     bool
+	generator bool
+	arrow     bool
+	params    []expr // identifier, pattern, assignExpr for defaults or spreadExpr for the rest parameter
+	body      []stmt
+	exprBody  expr // concise body of arrow functions
+	scope     *scope
+}
+
+type funcExpr struct {
+	f *funcNode
+}
+
+type classNode struct {
+	name    *ident
+	extends expr
+	list    []property
+	scope   *scope
+}
+
+type classExpr struct {
+	c *classNode
+}
+
+type unaryExpr struct {
+	op []byte
+	x  expr
+}
+
+type postfixExpr struct {
+	op []byte
+	x  expr
+}
+
+type binaryExpr struct {
+	op []byte
+	x  expr
+	y  expr
+}
+
+type assignExpr struct {
+	op     []byte
+	target expr
+	value  expr
+}
+
+type condExpr struct {
+	cond expr
+	x    expr
+	y    expr
+}
+
+type seqExpr struct {
+	list []expr
+}
+
+// callExpr is optional when called as f?.(), inChain is set for any link following an optional link in the same chain.
+type callExpr struct {
+	callee   expr
+	args     []expr
+	optional bool
+	inChain  bool
+}
+
+type newExpr struct {
+	callee expr
+	args   []expr
+}
+
+type memberExpr struct {
+	obj      expr
+	name     []byte // nil when computed
+	index    expr
+	optional bool
+	inChain  bool
+	pos      int
+}
+
+type yieldExpr struct {
+	delegate bool
+	x        expr
+}
+
+type spreadExpr struct {
+	x expr
+}
+
+func (*ident) isExpr()        {}
+func (*literal) isExpr()      {}
+func (*templateExpr) isExpr() {}
+func (*arrayExpr) isExpr()    {}
+func (*objectExpr) isExpr()   {}
+func (*funcExpr) isExpr()     {}
+func (*classExpr) isExpr()    {}
+func (*unaryExpr) isExpr()    {}
+func (*postfixExpr) isExpr()  {}
+func (*binaryExpr) isExpr()   {}
+func (*assignExpr) isExpr()   {}
+func (*condExpr) isExpr()     {}
+func (*seqExpr) isExpr()      {}
+func (*callExpr) isExpr()     {}
+func (*newExpr) isExpr()      {}
+func (*memberExpr) isExpr()   {}
+func (*yieldExpr) isExpr()    {}
+func (*spreadExpr) isExpr()   {}
+
+////////////////////////////////////////////////////////////////
+
+// Operator precedence levels, higher binds stronger.
+const (
+	precSeq = iota
+	precAssign
+	precCond
+	precCoalesce
+	precOr
+	precAnd
+	precBitOr
+	precBitXor
+	precBitAnd
+	precEquality
+	precRelational
+	precShift
+	precAdd
+	precMul
+	precExp
+	precUnary
+	precUpdate
+	precNew
+	precCall
+	precPrimary
+)
+
+var binaryPrec = map[string]int{
+	"??":         precCoalesce,
+	"||":         precOr,
+	"&&":         precAnd,
+	"|":          precBitOr,
+	"^":          precBitXor,
+	"&":          precBitAnd,
+	"==":         precEquality,
+	"!=":         precEquality,
+	"===":        precEquality,
+	"!==":        precEquality,
+	"<":          precRelational,
+	">":          precRelational,
+	"<=":         precRelational,
+	">=":         precRelational,
+	"in":         precRelational,
+	"instanceof": precRelational,
+	"<<":         precShift,
+	">>":         precShift,
+	">>>":        precShift,
+	"+":          precAdd,
+	"-":          precAdd,
+	"*":          precMul,
+	"/":          precMul,
+	"%":          precMul,
+	"**":         precExp,
+}
+
+var assignOps = map[string]bool{
+	"=":    true,
+	"+=":   true,
+	"-=":   true,
+	"*=":   true,
+	"/=":   true,
+	"%=":   true,
+	"**=":  true,
+	"<<=":  true,
+	">>=":  true,
+	">>>=": true,
+	"&=":   true,
+	"|=":   true,
+	"^=":   true,
+	"&&=":  true,
+	"||=":  true,
+	"??=":  true,
+}
+
+func exprPrec(e expr) int {
+	switch e := e.(type) {
+	case *seqExpr:
+		return precSeq
+	case *assignExpr, *yieldExpr, *spreadExpr:
+		return precAssign
+	case *funcExpr:
+		if e.f.arrow {
+			return precAssign
+		}
+	case *condExpr:
+		return precCond
+	case *binaryExpr:
+		return binaryPrec[string(e.op)]
+	case *unaryExpr:
+		return precUnary
+	case *postfixExpr:
+		return precUpdate
+	case *newExpr:
+		if len(e.args) == 0 {
+			return precNew
+		}
+		return precCall
+	case *callExpr, *memberExpr:
+		return precCall
+	case *templateExpr:
+		if e.tag != nil {
+			return precCall
+		}
+	}
+	return precPrimary
+}
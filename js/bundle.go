@@ -0,0 +1,569 @@
+package js // import "github.com/tdewolff/minify/js"
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// Bundler combines an ES module and all modules it imports by relative paths, such as ./util.js, into a single script.
+// The top-level declarations of all modules are hoisted into one scope, where they are renamed only when their names collide.
+// Imports of other modules, such as packages, are kept for ESM output and are an error for IIFE output. Since the kept imports run
+// before all bundled code, it is an error to import them after modules whose top-level code may have side effects.
+type Bundler struct {
+	Format string // iife (default) wraps the bundle in a function, esm writes an ES module that keeps the exports of the entry module
+
+	// Name is the global variable that receives the exports of the entry module for IIFE output, the exports are dropped if empty.
+	Name string
+
+	// ReadFile reads the modules, it defaults to ioutil.ReadFile.
+	ReadFile func(filename string) ([]byte, error)
+}
+
+// DefaultBundler is the default bundler.
+var DefaultBundler = &Bundler{}
+
+// Bundle bundles the entry module and the modules it imports, and writes the result to w.
+func Bundle(w io.Writer, entry string) error {
+	return DefaultBundler.Bundle(w, entry)
+}
+
+type module struct {
+	filename string
+	list     []stmt
+	scope    *scope
+
+	deps    map[string]*module // by module specifier, nil for external modules
+	imports map[*variable]importRef
+	exports map[string]exportRef
+	stars   []*module // modules re-exported by export *
+
+	def *variable // binding of an anonymous default export
+	ns  *variable // namespace object, nil if unused
+}
+
+// importRef refers to a name exported by a module, where * is the namespace object.
+type importRef struct {
+	m    *module
+	name string
+}
+
+// exportRef is either a local binding or a name exported by another module.
+type exportRef struct {
+	v *variable
+	importRef
+}
+
+type bundle struct {
+	*Bundler
+	esm     bool
+	modules map[string]*module
+	order   []*module // dependencies before the modules that import them
+	entry   *module
+	owner   map[*variable]*module
+	nss     []*module // modules whose namespace object is used
+}
+
+// Bundle bundles the entry module and the modules it imports, and writes the result to w.
+func (o *Bundler) Bundle(w io.Writer, entry string) error {
+	b := &bundle{
+		Bundler: o,
+		modules: map[string]*module{},
+		owner:   map[*variable]*module{},
+	}
+	if b.ReadFile == nil {
+		b.ReadFile = ioutil.ReadFile
+	}
+	switch o.Format {
+	case "", "iife":
+	case "esm":
+		b.esm = true
+	default:
+		return fmt.Errorf("unknown bundle format %s, must be iife or esm", o.Format)
+	}
+
+	src, err := b.ReadFile(entry)
+	if err != nil {
+		return err
+	}
+	if b.entry, err = b.load(filepath.Clean(entry), src); err != nil {
+		return err
+	}
+
+	// resolve imports to the bindings they refer to
+	aliases := map[*variable]*variable{}
+	users := map[*variable][]*module{}
+	for _, m := range b.order {
+		for v, ref := range m.imports {
+			target, err := b.resolve(ref.m, ref.name, map[importRef]bool{})
+			if err != nil {
+				return fmt.Errorf("%s: %v", m.filename, err)
+			}
+			aliases[v] = target
+			users[target] = append(users[target], m)
+		}
+	}
+
+	var exports []exportSpec
+	if b.esm || o.Name != "" {
+		names := b.exportNames(b.entry, map[*module]bool{})
+		if !b.esm {
+			names = []string{"*"}
+		}
+		for _, name := range names {
+			target, err := b.resolve(b.entry, name, map[importRef]bool{})
+			if err != nil {
+				return fmt.Errorf("%s: %v", b.entry.filename, err)
+			}
+			exports = append(exports, exportSpec{&ident{name: target.name, v: target}, []byte(exportName([]byte(name)))})
+		}
+	}
+
+	// namespace objects are declared first, their getters refer to bindings that are declared later
+	list := []stmt{}
+	for i := 0; i < len(b.nss); i++ {
+		ns := b.nss[i].ns
+		list = append(list, &varDecl{js.Const, []binding{{&ident{name: ns.name, v: ns}, b.namespace(b.nss[i])}}})
+	}
+
+	b.rename(users)
+	for v, target := range aliases {
+		v.short = target.Name()
+	}
+	for _, m := range b.order {
+		list = append(list, b.hoist(m)...)
+	}
+	if b.esm {
+		if 0 < len(exports) {
+			list = append(list, &exportStmt{specs: exports, braces: true})
+		}
+	} else {
+		f := &funcNode{body: append([]stmt{&exprStmt{&literal{js.StringToken, []byte(`"use strict"`), 0}}}, list...)}
+		call := &callExpr{callee: &funcExpr{f}}
+		if o.Name != "" {
+			f.body = append(f.body, &returnStmt{exports[0].local})
+			list = []stmt{&varDecl{js.Var, []binding{{&ident{name: []byte(o.Name)}, call}}}}
+		} else {
+			list = []stmt{&exprStmt{call}}
+		}
+	}
+	if 0 < len(b.entry.list) {
+		if comment, ok := b.entry.list[0].(*commentStmt); ok && comment.data[0] == '#' {
+			list = append([]stmt{comment}, list...)
+		}
+	}
+
+	p := newPrinter()
+	p.stmtList(list)
+	return p.writeTo(w)
+}
+
+// load parses a module and loads its dependencies, modules are added to the order after their dependencies.
+func (b *bundle) load(filename string, src []byte) (*module, error) {
+	list, global, err := parseProgram(src, true, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	isEntry := len(b.modules) == 0
+	m := &module{
+		filename: filename,
+		list:     list,
+		scope:    global,
+		deps:     map[string]*module{},
+		imports:  map[*variable]importRef{},
+		exports:  map[string]exportRef{},
+	}
+	b.modules[filename] = m
+	for _, v := range global.vars {
+		b.owner[v] = m
+	}
+
+	for _, s := range list {
+		specifier := []byte(nil)
+		switch s := s.(type) {
+		case *importStmt:
+			specifier = s.module
+		case *exportStmt:
+			specifier = s.module
+			if specifier != nil && b.external(specifier) && (!isEntry || !b.esm) {
+				return nil, fmt.Errorf("%s: cannot re-export external module %s", filename, specifier)
+			}
+		}
+		if specifier == nil {
+			continue
+		}
+		if b.external(specifier) {
+			if !b.esm {
+				return nil, fmt.Errorf("%s: cannot import external module %s in IIFE format", filename, specifier)
+			}
+			for _, prev := range b.order {
+				if hasTopLevelEffects(prev.list) {
+					return nil, fmt.Errorf("%s: cannot import external module %s after %s, which has side effects", filename, specifier, prev.filename)
+				}
+			}
+			m.deps[string(specifier)] = nil
+			continue
+		}
+		dep, err := b.loadDep(filename, string(specifier[1:len(specifier)-1]))
+		if err != nil {
+			return nil, err
+		}
+		m.deps[string(specifier)] = dep
+	}
+
+	for _, s := range list {
+		switch s := s.(type) {
+		case *importStmt:
+			dep := m.deps[string(s.module)]
+			if dep == nil {
+				continue
+			}
+			if s.def != nil {
+				m.imports[s.def.v] = importRef{dep, "default"}
+			}
+			if s.namespace != nil {
+				m.imports[s.namespace.v] = importRef{dep, "*"}
+			}
+			for _, spec := range s.specs {
+				m.imports[spec.local.v] = importRef{dep, exportName(spec.name)}
+			}
+		case *exportStmt:
+			if s.module != nil {
+				dep := m.deps[string(s.module)]
+				if dep == nil {
+					continue
+				} else if s.star && s.namespace == nil {
+					m.stars = append(m.stars, dep)
+				} else if s.star {
+					m.exports[exportName(s.namespace)] = exportRef{importRef: importRef{dep, "*"}}
+				}
+				for _, spec := range s.specs {
+					m.exports[exportName(spec.name)] = exportRef{importRef: importRef{dep, exportName(spec.local.(*ident).name)}}
+				}
+			} else if s.def {
+				var v *variable
+				switch decl := s.decl.(type) {
+				case *funcDecl:
+					if decl.f.name == nil {
+						decl.f.name = &ident{name: b.defaultName(m)}
+					}
+					v = b.declare(m, decl.f.name)
+				case *classDecl:
+					if decl.c.name == nil {
+						decl.c.name = &ident{name: b.defaultName(m)}
+					}
+					v = b.declare(m, decl.c.name)
+				default:
+					v = &variable{name: b.defaultName(m)}
+					b.owner[v] = m
+					m.def = v
+				}
+				m.exports["default"] = exportRef{v: v}
+			} else if s.decl != nil {
+				for _, id := range declaredIdents(s.decl) {
+					m.exports[string(id.name)] = exportRef{v: id.v}
+				}
+			} else {
+				for _, spec := range s.specs {
+					local := spec.local.(*ident)
+					if local.v == nil {
+						return nil, fmt.Errorf("%s: cannot export undeclared %s", filename, local.name)
+					}
+					m.exports[exportName(spec.name)] = exportRef{v: local.v}
+				}
+			}
+		}
+	}
+	b.order = append(b.order, m)
+	return m, nil
+}
+
+// loadDep loads the module imported by specifier from the importing module, trying the .js and .mjs extensions and index.js for directories.
+func (b *bundle) loadDep(importer, specifier string) (*module, error) {
+	filename := filepath.Join(filepath.Dir(importer), filepath.FromSlash(specifier))
+	for _, candidate := range []string{filename, filename + ".js", filename + ".mjs", filepath.Join(filename, "index.js")} {
+		if m, ok := b.modules[candidate]; ok {
+			return m, nil
+		}
+		if src, err := b.ReadFile(candidate); err == nil {
+			return b.load(candidate, src)
+		}
+	}
+	return nil, fmt.Errorf("%s: cannot find module %s", importer, specifier)
+}
+
+// external returns true for module specifiers that are not relative paths, such as packages.
+func (b *bundle) external(specifier []byte) bool {
+	s := string(specifier[1 : len(specifier)-1])
+	return !strings.HasPrefix(s, "./") && !strings.HasPrefix(s, "../")
+}
+
+// declare returns the top-level binding of the identifier, declaring it for anonymous default exports.
+func (b *bundle) declare(m *module, id *ident) *variable {
+	if id.v == nil {
+		m.def = &variable{name: id.name}
+		id.v = m.def
+		b.owner[m.def] = m
+	}
+	return id.v
+}
+
+// defaultName returns the name for anonymous default exports and namespace objects, which is derived from the filename.
+func (b *bundle) defaultName(m *module) []byte {
+	base := filepath.Base(m.filename)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	name := []byte(base)
+	for i, c := range name {
+		if !isIdentChar(c) || c == '\\' {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || '0' <= name[0] && name[0] <= '9' || reservedNames[string(name)] {
+		name = append([]byte("_"), name...)
+	}
+	return name
+}
+
+// resolve returns the top-level binding that the name exported by the module refers to, following re-exports and imports.
+func (b *bundle) resolve(m *module, name string, seen map[importRef]bool) (*variable, error) {
+	if seen[importRef{m, name}] {
+		return nil, fmt.Errorf("circular re-export of %s in %s", name, m.filename)
+	}
+	seen[importRef{m, name}] = true
+
+	if name == "*" {
+		if m.ns == nil {
+			m.ns = &variable{name: b.defaultName(m)}
+			b.owner[m.ns] = m
+			b.nss = append(b.nss, m)
+		}
+		return m.ns, nil
+	} else if ref, ok := m.exports[name]; ok {
+		if ref.v == nil {
+			return b.resolve(ref.m, ref.name, seen)
+		} else if imp, ok := m.imports[ref.v]; ok {
+			return b.resolve(imp.m, imp.name, seen)
+		}
+		return ref.v, nil
+	} else if name != "default" {
+		for _, star := range m.stars {
+			if v, err := b.resolve(star, name, seen); err == nil {
+				return v, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s does not export %s", m.filename, name)
+}
+
+// exportNames returns the sorted names exported by the module, including those of export * but excluding names of external modules.
+func (b *bundle) exportNames(m *module, seen map[*module]bool) []string {
+	seen[m] = true
+	names := []string{}
+	for name := range m.exports {
+		names = append(names, name)
+	}
+	for _, star := range m.stars {
+		if !seen[star] {
+			for _, name := range b.exportNames(star, seen) {
+				if _, ok := m.exports[name]; !ok && name != "default" {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	unique := names[:0]
+	for i, name := range names {
+		if i == 0 || name != names[i-1] {
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+// namespace returns the namespace object of a module, its getters keep the bindings live.
+func (b *bundle) namespace(m *module) expr {
+	obj := &objectExpr{}
+	obj.list = append(obj.list, property{kind: propInit, key: &literal{js.IdentifierToken, []byte("__proto__"), 0}, value: &literal{js.IdentifierToken, []byte("null"), 0}})
+	for _, name := range b.exportNames(m, map[*module]bool{}) {
+		v, err := b.resolve(m, name, map[importRef]bool{})
+		if err != nil {
+			continue // ambiguous or circular names are not exported
+		}
+		key := &literal{js.IdentifierToken, []byte(name), 0}
+		if key.data[0] == '"' {
+			key.tt = js.StringToken
+		}
+		getter := &funcNode{body: []stmt{&returnStmt{&ident{name: v.name, v: v}}}}
+		obj.list = append(obj.list, property{kind: propGet, key: key, value: &funcExpr{getter}})
+	}
+	return obj
+}
+
+// rename gives unique names to the top-level bindings of all modules. A binding keeps its name unless it is already taken,
+// refers to a global variable, or is shadowed in a module that imports it.
+func (b *bundle) rename(users map[*variable][]*module) {
+	globals := map[string]bool{}
+	declared := map[*module]map[string]bool{}
+	all := map[string]bool{}
+	for _, m := range b.order {
+		for name := range m.scope.globals {
+			globals[name] = true
+			all[name] = true
+		}
+		declared[m] = map[string]bool{}
+		var inner func(*scope)
+		inner = func(s *scope) {
+			for _, child := range s.children {
+				for name := range child.names {
+					declared[m][name] = true
+					all[name] = true
+				}
+				inner(child)
+			}
+		}
+		inner(m.scope)
+		for name := range m.scope.names {
+			all[name] = true
+		}
+	}
+	if b.Name != "" {
+		globals[b.Name] = true
+	}
+
+	vars := []*variable{}
+	for _, m := range b.order {
+		for _, v := range m.scope.vars {
+			if _, ok := m.imports[v]; !ok {
+				vars = append(vars, v)
+			}
+		}
+		if m.def != nil {
+			vars = append(vars, m.def)
+		}
+	}
+	for _, m := range b.nss {
+		vars = append(vars, m.ns)
+	}
+
+	taken := map[string]bool{}
+	for _, v := range vars {
+		name := string(v.name)
+		ok := !taken[name] && !globals[name]
+		for _, m := range users[v] {
+			if m != b.owner[v] && declared[m][name] {
+				ok = false
+			}
+		}
+		for i := 1; !ok; i++ {
+			name = string(v.name) + "$" + strconv.Itoa(i)
+			ok = !taken[name] && !all[name]
+		}
+		if name != string(v.name) {
+			v.short = []byte(name)
+		}
+		taken[name] = true
+	}
+}
+
+// hoist returns the statements of a module without its imports and exports.
+func (b *bundle) hoist(m *module) []stmt {
+	list := make([]stmt, 0, len(m.list))
+	for _, s := range m.list {
+		switch s := s.(type) {
+		case *importStmt:
+			if m.deps[string(s.module)] == nil {
+				list = append(list, s)
+			}
+		case *commentStmt:
+			if s.data[0] != '#' {
+				list = append(list, s) // hashbangs are only kept for the entry module, at the start of the bundle
+			}
+		case *exportStmt:
+			if s.module != nil {
+				if m.deps[string(s.module)] == nil {
+					list = append(list, s)
+				}
+			} else if x, ok := s.decl.(*exprStmt); ok && s.def {
+				list = append(list, &varDecl{js.Const, []binding{{&ident{name: m.def.name, v: m.def}, x.x}}})
+			} else if s.decl != nil {
+				list = append(list, s.decl)
+			}
+		default:
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// hasTopLevelEffects returns true if the top-level statements of a module may have side effects, that is if they are anything but
+// imports, exports and declarations of functions, classes and variables whose values have no side effects.
+func hasTopLevelEffects(list []stmt) bool {
+	for _, s := range list {
+		if export, ok := s.(*exportStmt); ok {
+			if export.decl == nil {
+				continue
+			}
+			s = export.decl
+		}
+		switch s := s.(type) {
+		case *importStmt, *commentStmt, *emptyStmt, *funcDecl:
+		case *classDecl:
+			if s.c.extends != nil && hasSideEffects(s.c.extends) {
+				return true
+			}
+			for _, prop := range s.c.list {
+				if prop.computed && hasSideEffects(prop.key) || prop.static && prop.value != nil && hasSideEffects(prop.value) {
+					return true
+				}
+			}
+		case *varDecl:
+			for _, binding := range s.list {
+				if _, ok := binding.target.(*ident); !ok || binding.init != nil && hasSideEffects(binding.init) {
+					return true
+				}
+			}
+		case *exprStmt:
+			if hasSideEffects(s.x) {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// declaredIdents returns the identifiers declared by a declaration.
+func declaredIdents(s stmt) []*ident {
+	switch s := s.(type) {
+	case *varDecl:
+		var ids []*ident
+		for _, b := range s.list {
+			ids = bindingIdents(b.target, ids)
+		}
+		return ids
+	case *funcDecl:
+		return []*ident{s.f.name}
+	case *classDecl:
+		return []*ident{s.c.name}
+	}
+	return nil
+}
+
+// exportName returns the name of an import or export specifier, which is quoted if it is not an identifier.
+func exportName(name []byte) string {
+	if name[0] == '"' || name[0] == '\'' {
+		if s := string(name[1 : len(name)-1]); isDefineKey(s) && !strings.Contains(s, ".") {
+			return s
+		}
+		return `"` + string(name[1:len(name)-1]) + `"`
+	}
+	return string(name)
+}
@@ -1,8 +1,14 @@
-// Package js minifies ECMAScript5.1 following the specifications at http://www.ecma-international.org/ecma-262/5.1/.
+// Package js minifies ECMAScript 2020 following the specifications at https://262.ecma-international.org/11.0/.
 package js // import "github.com/tdewolff/minify/js"
 
 import (
+	"bytes"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/tdewolff/minify/v2"
 	"github.com/tdewolff/parse/v2"
@@ -20,7 +26,35 @@ var (
 var DefaultMinifier = &Minifier{}
 
 // Minifier is a JS minifier.
-type Minifier struct{}
+type Minifier struct {
+	MangleNames    bool // rename local variables and function parameters to short names
+	FoldConstants  bool // evaluate constant expressions such as 1+2 and write true and false as !0 and !1
+	RemoveDeadCode bool // remove unreachable code after return, throw, break and continue, and branches with constant conditions
+	DropDebugger   bool // remove debugger statements
+
+	// PureFuncs lists global functions or methods such as console.log or assert, whose calls are removed when their result is not used.
+	// Arguments that may have side effects are kept, where reading variables and properties is assumed to have none.
+	PureFuncs []string
+
+	// Define replaces global identifiers or member chains such as DEBUG or process.env.NODE_ENV by the given expression, such as false or "production"
+	Define map[string]string
+
+	// MangleProps renames object properties matching the regular expression, such as ^_ for private members, to short names.
+	// Quoted and computed properties such as a["_x"] are never renamed. Share a NameCache to use the same names across files.
+	MangleProps *regexp.Regexp
+	NameCache   *NameCache
+
+	// TemplateTags maps tags of template literals, such as html or css, to the mimetype of their contents, such as text/html or text/css.
+	// The contents are minified by the minifier registered for that mimetype, where substitutions are kept as is.
+	TemplateTags map[string]string
+
+	// Comments decides which comments are kept, where nil keeps only bang comments such as /*! ... */.
+	Comments *minify.Comments
+
+	// RenameSelectors renames the class names and ids in string literals passed to DOM methods such as querySelector, getElementById
+	// and classList.add, using the names of minify.M so that they match the renamed stylesheets and documents.
+	RenameSelectors bool
+}
 
 // Minify minifies JS data, it reads from r and writes to w.
 func Minify(m *minify.M, w io.Writer, r io.Reader, params map[string]string) error {
@@ -28,32 +62,63 @@ func Minify(m *minify.M, w io.Writer, r io.Reader, params map[string]string) err
 }
 
 // Minify minifies JS data, it reads from r and writes to w.
-func (o *Minifier) Minify(_ *minify.M, w io.Writer, r io.Reader, _ map[string]string) error {
+func (o *Minifier) Minify(m *minify.M, w io.Writer, r io.Reader, _ map[string]string) error {
+	src, err := readAll(r)
+	if err != nil {
+		return err
+	}
+	if o.MangleNames || o.MangleProps != nil || 0 < len(o.TemplateTags) || o.optimizes() || o.RenameSelectors && m != nil && m.Names != nil {
+		return o.minifyAST(m, w, src)
+	}
+
 	prev := js.LineTerminatorToken
+	prevInteger := false // previous token is a decimal integer that would absorb a following dot
 	prevLast := byte(' ')
 	lineTerminatorQueued := false
 	whitespaceQueued := false
+	mapper, _ := w.(minify.Mapper)
 
-	l := js.NewLexer(r)
-	defer l.Restore()
-
+	l := newLexer(src)
 	for {
 		tt, data := l.Next()
 		if tt == js.ErrorToken {
-			if l.Err() != io.EOF {
-				return l.Err()
-			}
 			return nil
 		} else if tt == js.LineTerminatorToken {
 			lineTerminatorQueued = true
 		} else if tt == js.WhitespaceToken {
 			whitespaceQueued = true
 		} else if tt == js.SingleLineCommentToken || tt == js.MultiLineCommentToken {
-			if len(data) > 5 && data[1] == '*' && data[2] == '!' {
-				if _, err := w.Write(data[:3]); err != nil {
+			if data[0] == '#' {
+				// hashbang
+				if _, err := w.Write(data); err != nil {
+					return err
+				}
+				if _, err := w.Write(newlineBytes); err != nil {
+					return err
+				}
+			} else if keepComment(o.Comments, data) {
+				if mapper != nil {
+					mapper.Map(data)
+				}
+				if data[1] == '/' {
+					// single-line comments must end with a line terminator
+					if _, err := w.Write(data); err != nil {
+						return err
+					}
+					if _, err := w.Write(newlineBytes); err != nil {
+						return err
+					}
+					prev = js.LineTerminatorToken
+					continue
+				}
+				n := 2
+				if data[2] == '!' {
+					n = 3
+				}
+				if _, err := w.Write(data[:n]); err != nil {
 					return err
 				}
-				comment := parse.ReplaceMultipleWhitespace(data[3 : len(data)-2])
+				comment := parse.ReplaceMultipleWhitespace(data[n : len(data)-2])
 				if tt != js.MultiLineCommentToken {
 					// don't trim newlines in multiline comments as that might change ASI
 					// (we could do a more expensive check post-factum but it's not worth it)
@@ -71,27 +136,223 @@ func (o *Minifier) Minify(_ *minify.M, w io.Writer, r io.Reader, _ map[string]st
 				whitespaceQueued = true
 			}
 		} else {
+			orig := data
+			if tt == js.NumericToken {
+				data = minifyNumber(data)
+			} else if tt == js.StringToken {
+				data = minifyString(data)
+			}
+
 			first := data[0]
-			if (prev == js.IdentifierToken || prev == js.NumericToken || prev == js.PunctuatorToken || prev == js.StringToken || prev == js.TemplateToken || prev == js.RegexpToken) &&
+			templateContinues := tt == js.TemplateToken && first == '}'
+			if !templateContinues && (prev == js.IdentifierToken || prev == js.NumericToken || prev == js.PunctuatorToken || prev == js.StringToken || prev == js.TemplateToken || prev == js.RegexpToken) &&
 				(tt == js.IdentifierToken || tt == js.NumericToken || tt == js.StringToken || tt == js.TemplateToken || tt == js.PunctuatorToken || tt == js.RegexpToken) {
 				if lineTerminatorQueued && (prev != js.PunctuatorToken || prevLast == '}' || prevLast == ']' || prevLast == ')' || prevLast == '+' || prevLast == '-' || prevLast == '"' || prevLast == '\'') &&
 					(tt != js.PunctuatorToken || first == '{' || first == '[' || first == '(' || first == '+' || first == '-' || first == '!' || first == '~') {
 					if _, err := w.Write(newlineBytes); err != nil {
 						return err
 					}
-				} else if whitespaceQueued && (prev != js.StringToken && prev != js.PunctuatorToken && tt != js.PunctuatorToken || (prevLast == '+' || prevLast == '-' || prevLast == '/') && first == prevLast) {
+				} else if (whitespaceQueued || lineTerminatorQueued) && (prev != js.StringToken && prev != js.TemplateToken && prev != js.PunctuatorToken && tt != js.PunctuatorToken ||
+					(prevLast == '+' || prevLast == '-' || prevLast == '/') && first == prevLast || // a+ +b, a/ /b/ and a/ /*comment*/
+					prevLast == '<' && first == '!') || // a< !--b would start a comment
+					prevInteger && first == '.' { // 1 .toString(), also when 1.0 was shortened
 					if _, err := w.Write(spaceBytes); err != nil {
 						return err
 					}
 				}
 			}
+			if mapper != nil && tt != js.PunctuatorToken {
+				mapper.Map(orig)
+			}
 			if _, err := w.Write(data); err != nil {
 				return err
 			}
 			prev = tt
+			prevInteger = tt == js.NumericToken && isDecimalInteger(data)
 			prevLast = data[len(data)-1]
 			lineTerminatorQueued = false
 			whitespaceQueued = false
 		}
 	}
 }
+
+// minifyNumber returns the shortest representation of a numeric literal, such as 1e6 for 1000000, .5 for 0.50 or 15 for 0x0F.
+func minifyNumber(num []byte) []byte {
+	if bytes.IndexByte(num, '_') != -1 {
+		num = bytes.Replace(num, []byte("_"), nil, -1)
+	}
+	if num[len(num)-1] == 'n' {
+		return num // BigInt
+	} else if 1 < len(num) && num[0] == '0' {
+		base := 0
+		switch num[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		case '.', 'e', 'E':
+		default:
+			return num // legacy octal literal
+		}
+		if base != 0 {
+			n, err := strconv.ParseUint(string(num[2:]), base, 64)
+			if err != nil {
+				return num
+			}
+			dec := minify.Number(strconv.AppendUint(nil, n, 10), -1)
+			if len(dec) <= len(num) {
+				return dec
+			}
+			return num
+		}
+	}
+	return minify.Number(append([]byte{}, num...), -1) // don't overwrite the input
+}
+
+// minifyString requotes a string literal with the quote that needs the fewest escapes, and removes escapes of the other quote.
+func minifyString(s []byte) []byte {
+	double, single, escaped := 0, 0, false
+	for i := 1; i < len(s)-1; i++ {
+		c := s[i]
+		if c == '\\' {
+			i++
+			c = s[i]
+			escaped = escaped || c == '"' || c == '\''
+		}
+		if c == '"' {
+			double++
+		} else if c == '\'' {
+			single++
+		}
+	}
+
+	quote := s[0]
+	if double < single {
+		quote = '"'
+	} else if single < double {
+		quote = '\''
+	}
+	if quote == s[0] && !escaped {
+		return s
+	}
+
+	b := make([]byte, 0, len(s))
+	b = append(b, quote)
+	for i := 1; i < len(s)-1; i++ {
+		c := s[i]
+		if c == '\\' {
+			i++
+			if next := s[i]; next != '"' && next != '\'' {
+				b = append(b, c, next)
+				continue
+			}
+			c = s[i]
+		}
+		if c == quote {
+			b = append(b, '\\')
+		}
+		b = append(b, c)
+	}
+	return append(b, quote)
+}
+
+// readAll returns the input, which is not copied if the reader holds a buffer so that source mappings can be found.
+func readAll(r io.Reader) ([]byte, error) {
+	if buf, ok := r.(interface {
+		Bytes() []byte
+	}); ok {
+		return buf.Bytes(), nil
+	}
+	return ioutil.ReadAll(r)
+}
+
+// keepComment returns true if the comment is kept by the policy, or if it is a bang comment such as /*! ... */ when the policy is nil.
+func keepComment(policy *minify.Comments, data []byte) bool {
+	if policy != nil {
+		return policy.Keep(data)
+	}
+	return len(data) > 5 && data[1] == '*' && data[2] == '!'
+}
+
+// optimizes returns true if any of the options of the optimizer pass is set.
+func (o *Minifier) optimizes() bool {
+	return o.FoldConstants || o.RemoveDeadCode || o.DropDebugger || 0 < len(o.PureFuncs) || 0 < len(o.Define)
+}
+
+// isDefineKey returns true for identifiers and member chains such as process.env.NODE_ENV.
+func isDefineKey(key string) bool {
+	for _, name := range strings.Split(key, ".") {
+		if name == "" || '0' <= name[0] && name[0] <= '9' {
+			return false
+		}
+		for i := 0; i < len(name); i++ {
+			if !isIdentChar(name[i]) || name[i] == '\\' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// minifyAST parses the entire input so that variables can be renamed within their scope.
+// Global variables are never renamed as other scripts may refer to them.
+func (o *Minifier) minifyAST(m *minify.M, w io.Writer, src []byte) error {
+	list, global, err := parseProgram(src, false, o.Comments)
+	if err != nil {
+		return err
+	}
+	if o.optimizes() {
+		opt := &optimizer{
+			fold:         o.FoldConstants,
+			deadCode:     o.RemoveDeadCode,
+			dropDebugger: o.DropDebugger,
+		}
+		if 0 < len(o.PureFuncs) {
+			opt.pure = make(map[string]bool, len(o.PureFuncs))
+			for _, name := range o.PureFuncs {
+				opt.pure[name] = true
+			}
+		}
+		if 0 < len(o.Define) {
+			opt.define = make(map[string][]byte, len(o.Define))
+			for key, value := range o.Define {
+				if !isDefineKey(key) {
+					return fmt.Errorf("invalid define %s, must be an identifier or member chain", key)
+				}
+				src := []byte("(" + value + ")")
+				_, scope, err := parseExpression(src)
+				if err != nil {
+					return fmt.Errorf("invalid define %s: %v", key, err)
+				}
+				for name := range scope.globals {
+					global.addGlobal(name) // keep variables from being renamed to names used by the value
+				}
+				opt.define[key] = src
+			}
+		}
+		list = opt.stmtList(list)
+	}
+	if 0 < len(o.TemplateTags) && m != nil {
+		minifyTemplates(m, list, o.TemplateTags)
+	}
+	if o.RenameSelectors && m != nil && m.Names != nil {
+		renameSelectors(list, m.Names)
+	}
+	if o.MangleProps != nil {
+		cache := o.NameCache
+		if cache == nil {
+			cache = NewNameCache()
+		}
+		mangleProps(list, o.MangleProps, cache)
+	}
+	if o.MangleNames {
+		global.rename(true)
+	}
+
+	p := newPrinter()
+	p.mapper, _ = w.(minify.Mapper)
+	p.stmtList(list)
+	return p.writeTo(w)
+}
@@ -2,11 +2,17 @@ package js // import "github.com/tdewolff/minify/js"
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
 	"github.com/tdewolff/test"
 )
 
@@ -52,6 +58,57 @@ func TestJS(t *testing.T) {
 		{"f()/*com\nment*/g()", "f()\ng()"},              // #185
 		{"f()/*!\n*/g()", "f()/*!\n*/g()"},               // #185
 
+		// ES2020
+		{"#!/usr/bin/env node\na", "#!/usr/bin/env node\na"},
+		{"if (x) /[ ]/.test(s)", "if(x)/[ ]/.test(s)"},
+		{"while (x) /a b/g.exec(s)", "while(x)/a b/g.exec(s)"},
+		{"a = (x) / 2 / b", "a=(x)/2/b"},
+		{"a = {} / 1 / b", "a={}/1/b"},
+		{"x = function(){} / 2 / b", "x=function(){}/2/b"},
+		{"x = async function(){} / 2 / b", "x=async function(){}/2/b"},
+		{"x = class { m(){} } / 2 / b", "x=class{m(){}}/2/b"},
+		{"function f(){}\n/a b/.test(s)", "function f(){}\n/a b/.test(s)"},
+		{"{} /a b/.test(s)", "{}/a b/.test(s)"},
+		{"async function f(){ await /[ ]/.test(s) }", "async function f(){await /[ ]/.test(s)}"},
+		{"function* f(){ yield /[ ]/ }", "function*f(){yield /[ ]/}"},
+		{"a.return / 2 / b", "a.return/2/b"},
+		{"`${ {a: 1}.a / 2 / b }` / 2 / c", "`${{a:1}.a/2/b}`/2/c"},
+		{"`a${ `b${ c }` }d` + e", "`a${`b${c}`}d`+e"},
+		{"1 .toString()", "1 .toString()"},
+		{"1..toString()", "1 .toString()"},
+		{"1.5 .toFixed()", "1.5.toFixed()"},
+		{"1_000 .toFixed()", "1e3.toFixed()"},
+		{"a < !--b", "a< !--b"},
+		{"a?.b ?? c?.[d]", "a?.b??c?.[d]"},
+		{"a ? .5 : b", "a?.5:b"},
+		{"a **= 2n ** 1_000n", "a**=2n**1000n"},
+		{"a = 0x7f_ff + 0b1010 + 0o17", "a=32767+10+15"},
+		{"class A { #x = 1; static #y; m() { return #x in this } }", "class A{#x=1;static #y;m(){return #x in this}}"},
+		{"a ||= b; c &&= d; e ??= f", "a||=b;c&&=d;e??=f"},
+		{"x = () => {}\n(a)", "x=()=>{}\n(a)"},
+		{"async\nfunction f(){}", "async\nfunction f(){}"},
+		{"function*f(){yield\n/a b/g}", "function*f(){yield\n/a b/g}"},
+		{"x = () => {}\n/a b/g.exec(s)", "x=()=>{}\n/a b/g.exec(s)"},
+		{"a = b\n/c/g", "a=b/c/g"},
+		{"let \u0061bc = 1", "let \u0061bc=1"},
+		{"a\u2028b", "a\nb"},
+
+		// literals
+		{"a=1000000", "a=1e6"},
+		{"a=0.50", "a=.5"},
+		{"a=0x0F", "a=15"},
+		{"a=0xFFFFFFFFFF", "a=0xFFFFFFFFFF"},
+		{"a=017", "a=017"},
+		{"a=0x10n", "a=0x10n"},
+		{"a=1.0.toFixed()", "a=1 .toFixed()"},
+		{"a=1.0\n.toFixed()", "a=1 .toFixed()"},
+		{`a="it's"`, `a="it's"`},
+		{`a="say \"hi\""`, `a='say "hi"'`},
+		{`a='a\"b'`, `a='a"b'`},
+		{`a='\'"\''`, `a="'\"'"`},
+		{`a="\\\""`, `a='\\"'`},
+		{`a="\n\x41<\/script>"`, `a="\n\x41<\/script>"`},
+
 		// go-fuzz
 		{`/\`, `/\`},
 	}
@@ -67,6 +124,463 @@ func TestJS(t *testing.T) {
 	}
 }
 
+func TestJSMangleNames(t *testing.T) {
+	jsTests := []struct {
+		js       string
+		expected string
+	}{
+		{"function compute(longParameterName){var intermediateResult=longParameterName*2;return intermediateResult}", "function compute(a){var b=a*2;return b}"},
+		{"var global=1;function f(){return global}", "var global=1;function f(){return global}"},
+		{"function f(a){let x=1;{let y=2;return x+y}}", "function f(b){let a=1;{let b=2;return a+b}}"},
+		{"function f(x){return function(y){return x+y}}", "function f(a){return function(b){return a+b}}"},
+		{"function f(x){return a+x}", "function f(b){return a+b}"},
+		{"function f(x){var y=1;return eval('x+y')}", "function f(x){var y=1;return eval('x+y')}"},
+		{"function f(x){return function(){eval('x')}}", "function f(x){return function(){eval('x')}}"},
+		{"function f(o){var x=1;with(o){return x}}", "function f(o){var x=1;with(o)return x}"},
+		{"function f(){var value=1;return {value}}", "function f(){var a=1;return{value:a}}"},
+		{"function f({key=1}){return key}", "function f({key:a=1}){return a}"},
+		{"function f(){return arguments}", "function f(){return arguments}"},
+		{"function f(x){try{}catch(err){return err+x}}", "function f(a){try{}catch(b){return b+a}}"},
+		{"const f=(first,...rest)=>first+rest.length", "const f=(a,...b)=>a+b.length"},
+		{"function f(){for(let i=0;i<3;i++)g(i)}", "function f(){for(let a=0;a<3;a++)g(a)}"},
+		{"(function(){var x=function inner(){return inner}})()", "(function(){var a=function a(){return a}}())"},
+		{"function f(x=y){var y=2;return x}", "function f(a=y){var b=2;return a}"},
+		{"function f(first=second,{third}={}){var second;return first+third}", "function f(a=second,{third:b}={}){var c;return a+b}"},
+		{"function f(x=1){var x;return x}", "function f(a=1){var a;return a}"},
+		{"const f=(x=y)=>{var y=x;return y}", "const f=(a=y)=>{var b=a;return b}"},
+		{"function f(p=q,q2){g(q);var q=1}", "function f(a=q,b){g(c);var c=1}"},
+		{"function f(x){return x}\n/*! license */", "function f(a){return a}/*! license */"},
+		{"function f(x){return [x.toFixed(1.0), 1.0.toFixed(), 'a\\'b']}", "function f(a){return[a.toFixed(1),1..toFixed(),\"a'b\"]}"},
+	}
+
+	m := minify.New()
+	jsMinifier := &Minifier{MangleNames: true}
+	for _, tt := range jsTests {
+		t.Run(tt.js, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.js)
+			w := &bytes.Buffer{}
+			err := jsMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.js, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestJSFoldConstants(t *testing.T) {
+	jsTests := []struct {
+		js       string
+		expected string
+	}{
+		{"a=1+2*3", "a=7"},
+		{"a=\"a\"+\"b\"+1", "a=\"ab1\""},
+		{"a=1+2+\"a\"", "a=\"3a\""},
+		{"a=true,b=false", "a=!0,b=!1"},
+		{"a=!true", "a=!1"},
+		{"a=1/3", "a=1/3"},
+		{"a=10/4", "a=2.5"},
+		{"a=0x10|1", "a=17"},
+		{"a=-1>>>0", "a=-1>>>0"},
+		{"a=1<2,b=\"a\"===\"b\",c=null==undefined", "a=!0,b=!1,c=!0"},
+		{"a=1==\"1\"", "a=1==\"1\""},
+		{"a=true&&b,c=0||d,e=null??f", "a=b,c=d,e=f"},
+		{"a=1?b:c", "a=b"},
+		{"(1&&a.b)()", "(0,a.b)()"},
+		{"(0||eval)(x)", "(0,eval)(x)"},
+		{"true.toString()", "(!0).toString()"},
+		{"a={true:1,[1+1]:2}", "a={true:1,[2]:2}"},
+		{"\"use \"+\"strict\"", "\"use \"+\"strict\""},
+		{"function f(undefined){return undefined==null}", "function f(undefined){return undefined==null}"},
+		{"a=(-2)**2", "a=4"},
+		{"a=2**-2", "a=.25"},
+	}
+
+	m := minify.New()
+	jsMinifier := &Minifier{FoldConstants: true}
+	for _, tt := range jsTests {
+		t.Run(tt.js, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.js)
+			w := &bytes.Buffer{}
+			err := jsMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.js, err, w.String(), tt.expected)
+		})
+	}
+
+	// the operand of ** cannot be a unary expression
+	err := jsMinifier.Minify(m, &bytes.Buffer{}, bytes.NewBufferString("a=-2**2"), nil)
+	test.That(t, err != nil, "must return error for -2**2")
+}
+
+func TestJSRemoveDeadCode(t *testing.T) {
+	jsTests := []struct {
+		js       string
+		expected string
+	}{
+		{"function f(){return 1;g()}", "function f(){return 1}"},
+		{"function f(){throw e;var a=1;function g(){}let b}", "function f(){throw e;var a;function g(){}}"},
+		{"for(;;){if(a)break;continue;b()}", "for(;;){if(a)break;continue}"},
+		{"switch(a){case 1:b();break;c();default:d()}", "switch(a){case 1:b();break;default:d()}"},
+		{"if(false){a()}b()", "b()"},
+		{"if(0){a()}else{b();c()}", "b();c()"},
+		{"if(1){a()}else{var b=1}", "a();var b"},
+		{"if(1){let a=1;b(a)}", "{let a=1;b(a)}"},
+		{"if(!1)a();else if(c)d()", "if(c)d()"},
+		{"while(0){var a}", "var a"},
+		{"if(false){function h(){}}g(h)", "var h;g(h)"},
+		{"if(1)a();else{var b;function c(){}}", "a();var b,c"},
+		{"function f(){return;{function g(){}}}", "function f(){return;var g}"},
+		{"a=0?b:c;d=1&&e", "a=c;d=e"},
+		{"false;0;a()", "a()"},
+		{"\"use strict\";a()", "\"use strict\";a()"},
+		{"if(DEBUG)a()", "if(DEBUG)a()"},
+	}
+
+	m := minify.New()
+	jsMinifier := &Minifier{RemoveDeadCode: true}
+	for _, tt := range jsTests {
+		t.Run(tt.js, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.js)
+			w := &bytes.Buffer{}
+			err := jsMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.js, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestJSDefine(t *testing.T) {
+	jsTests := []struct {
+		js       string
+		expected string
+	}{
+		{"if(DEBUG)a()", "if(false)a()"},
+		{"a=process.env.NODE_ENV", "a=\"production\""},
+		{"a=process.env.OTHER", "a=process.env.OTHER"},
+		{"a=process?.env.NODE_ENV", "a=process?.env.NODE_ENV"},
+		{"a=CONFIG.x", "a={x:1}.x"},
+		{"a={DEBUG}", "a={DEBUG:false}"},
+		{"LOG(1)", "console.log(1)"},
+		{"DEBUG=1;DEBUG++;[DEBUG]=a", "DEBUG=1;DEBUG++;[DEBUG]=a"},
+		{"function f(DEBUG){return DEBUG}", "function f(DEBUG){return DEBUG}"},
+	}
+
+	m := minify.New()
+	jsMinifier := &Minifier{Define: map[string]string{
+		"DEBUG":                "false",
+		"process.env.NODE_ENV": "\"production\"",
+		"CONFIG":               "{x: 1}",
+		"LOG":                  "console.log",
+	}}
+	for _, tt := range jsTests {
+		t.Run(tt.js, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.js)
+			w := &bytes.Buffer{}
+			err := jsMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.js, err, w.String(), tt.expected)
+		})
+	}
+
+	// combined with dead code removal and renaming
+	jsMinifier = &Minifier{MangleNames: true, RemoveDeadCode: true, Define: map[string]string{"DEBUG": "false", "CONFIG": "{a: window.a}"}}
+	w := &bytes.Buffer{}
+	err := jsMinifier.Minify(m, w, bytes.NewBufferString("function f(x){var window=x;if(DEBUG){log(x)}return CONFIG}"), nil)
+	test.Minify(t, "", err, w.String(), "function f(a){var b=a;return{a:window.a}}")
+
+	for _, define := range []map[string]string{{"a b": "1"}, {"1a": "1"}, {"a": "1;2"}, {"a": "}"}} {
+		err := (&Minifier{Define: define}).Minify(m, &bytes.Buffer{}, bytes.NewBufferString("a"), nil)
+		test.That(t, err != nil, "must return error for invalid define")
+	}
+}
+
+func TestJSPureFuncs(t *testing.T) {
+	jsTests := []struct {
+		js       string
+		expected string
+	}{
+		{"debugger;a()", "a()"},
+		{"if(a)debugger;else b()", "if(a);else b()"},
+		{"console.log(\"a\",b.c);d()", "d()"},
+		{"console.log(f(),g.h,i++)", "f(),i++"},
+		{"a\nconsole.log(1)\n;[1].map(f)", "a;[1].map(f)"},
+		{"a=console.log(1);f(assert(b))", "a=console.log(1);f(assert(b))"},
+		{"(console.debug(1),a)", "a"},
+		{"a&&console.debug(1);b?console.log(1):c()", "a;b?void 0:c()"},
+		{"for(;;console.log(i))i++", "for(;;)i++"},
+		{"console.log(...a)", "console.log(...a)"},
+		{"function assert(a){}assert(1)", "function assert(a){}assert(1)"},
+		{"console.info(1)", "console.info(1)"},
+	}
+
+	m := minify.New()
+	jsMinifier := &Minifier{DropDebugger: true, PureFuncs: []string{"console.log", "console.debug", "assert"}}
+	for _, tt := range jsTests {
+		t.Run(tt.js, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.js)
+			w := &bytes.Buffer{}
+			err := jsMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.js, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestJSMangleProps(t *testing.T) {
+	jsTests := []struct {
+		js       string
+		expected string
+	}{
+		{"a._x=1;b._x+a._y", "a.a=1;b.a+a.b"},
+		{"a.a;a._b;a.b;a._c;a._c", "a.a;a.d;a.b;a.c;a.c"},
+		{"var o={_x:1,y:2,\"_z\":3,[_w]:4,_v(){},get _u(){return 1}};o[\"_x\"]", "var o={c:1,y:2,\"_z\":3,[_w]:4,b(){},get a(){return 1}};o[\"_x\"]"},
+		{"var _x=1;var o={_x};({_x}=o)", "var _x=1;var o={a:_x};({a:_x}=o)"},
+		{"class A{_a=1;#_b;static _c(){}constructor(){this.#_b=this._a}}", "class A{a=1;#_b;static b(){}constructor(){this.#_b=this.a}}"},
+		{"a?._x;a.__proto__;o={__proto__:null,_y:1}", "a?.a;a.__proto__;o={__proto__:null,b:1}"},
+		{"import {_x} from \"a\";export {_y as _z}", "import{_x}from\"a\";export{_y as _z}"},
+	}
+
+	m := minify.New()
+	jsMinifier := &Minifier{MangleProps: regexp.MustCompile("^_")}
+	for _, tt := range jsTests {
+		t.Run(tt.js, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.js)
+			w := &bytes.Buffer{}
+			err := jsMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.js, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestJSNameCache(t *testing.T) {
+	cache := NewNameCache()
+	err := json.Unmarshal([]byte(`{"_x":"b"}`), cache)
+	test.Error(t, err)
+
+	m := minify.New()
+	jsMinifier := &Minifier{MangleProps: regexp.MustCompile("^_"), NameCache: cache}
+	w := &bytes.Buffer{}
+	err = jsMinifier.Minify(m, w, bytes.NewBufferString("a._y;a._y;a._x"), nil)
+	test.Minify(t, "", err, w.String(), "a.a;a.a;a.b")
+
+	w.Reset()
+	err = jsMinifier.Minify(m, w, bytes.NewBufferString("a.c;a._z;a._x;a._y"), nil)
+	test.Minify(t, "", err, w.String(), "a.c;a.d;a.b;a.a")
+
+	b, err := json.Marshal(cache)
+	test.Error(t, err)
+	test.String(t, string(b), `{"_x":"b","_y":"a","_z":"d"}`)
+
+	// names that are not mangled in a later file
+	jsMinifier.NameCache = NewNameCache()
+	w.Reset()
+	err = jsMinifier.Minify(m, w, bytes.NewBufferString("o._x"), nil)
+	test.Minify(t, "o._x", err, w.String(), "o.a")
+
+	w.Reset()
+	err = jsMinifier.Minify(m, w, bytes.NewBufferString("o.a;o._x"), nil)
+	test.Minify(t, "o.a;o._x", err, w.String(), "o.a;o.b")
+
+	w.Reset()
+	err = jsMinifier.Minify(m, w, bytes.NewBufferString("o._y;o._x"), nil)
+	test.Minify(t, "o._y;o._x", err, w.String(), "o.c;o.b")
+}
+
+func TestJSComments(t *testing.T) {
+	jsTests := []struct {
+		policy   string
+		js       string
+		expected string
+	}{
+		{"none", "/*! MIT */ a", "a"},
+		{"all", "/* x */ a // y\nb", "/*x*/a// y\nb"},
+		{"license", "/*! MIT */ /* x */ a", "/*!MIT*/a"},
+		{"license", "// @license MIT\na", "// @license MIT\na"},
+		{"license", "a // @license MIT\n+b", "a// @license MIT\n+b"},
+		{"^/\\*\\*", "/** x */ /* y */ a", "/** x*/a"},
+	}
+
+	m := minify.New()
+	for _, tt := range jsTests {
+		t.Run(tt.js, func(t *testing.T) {
+			comments, err := minify.NewComments(tt.policy)
+			test.Error(t, err)
+			jsMinifier := &Minifier{Comments: comments}
+			w := &bytes.Buffer{}
+			err = jsMinifier.Minify(m, w, bytes.NewBufferString(tt.js), nil)
+			test.Minify(t, tt.js, err, w.String(), tt.expected)
+
+			jsMinifier.MangleNames = true
+			w.Reset()
+			err = jsMinifier.Minify(m, w, bytes.NewBufferString(tt.js), nil)
+			test.Error(t, err)
+			test.That(t, strings.Contains(w.String(), "MIT") == strings.Contains(tt.expected, "MIT"))
+		})
+	}
+}
+
+func TestJSCommentsExtract(t *testing.T) {
+	extracted := []string{}
+	comments, _ := minify.NewComments("license")
+	comments.Extract = func(comment []byte) {
+		extracted = append(extracted, string(comment))
+	}
+
+	m := minify.New()
+	jsMinifier := &Minifier{Comments: comments}
+	w := &bytes.Buffer{}
+	err := jsMinifier.Minify(m, w, bytes.NewBufferString("/*! MIT */ a"), nil)
+	test.Minify(t, "", err, w.String(), "a")
+	test.That(t, len(extracted) == 1 && extracted[0] == "/*! MIT */")
+}
+
+func TestJSTemplateTags(t *testing.T) {
+	jsTests := []struct {
+		js       string
+		expected string
+	}{
+		{"x=html`<p class=\"${a}\">  a  ${b} </p>`", "x=html`<p class=\"${a}\">a ${b}`"},
+		{"x=html`<a href=\"${u}\" id=\"b\" .someProp=${v}>x</a>`", "x=html`<a href=\"${u}\" id=b .someProp=${v}>x</a>`"},
+		{"x=html`<ul>${a.map(i=>html`<li> ${i} </li>`)}</ul>`", "x=html`<ul>${a.map(i=>html`<li>${i}`)}</ul>`"},
+		{"x=css`a { color: ${c}; margin: 0px 0px; width: ${w}px }`", "x=css`a{color:${c};margin:0;width:${w}px}`"},
+		{"x=lit.html`<p> a </p>`;y=tag`<p> a </p>`", "x=lit.html`<p>a`;y=tag`<p> a </p>`"},
+		{"x=html`<p>\\n</p>`", "x=html`<p>\\n</p>`"},
+		{"x=html`<input disabled=${d}>`", "x=html`<input disabled=${d}>`"},
+		{"x=css`${a}{}`", "x=css`${a}{}`"},
+	}
+
+	m := minify.New()
+	m.AddFunc("text/css", css.Minify)
+	m.AddFunc("text/html", html.Minify)
+	jsMinifier := &Minifier{TemplateTags: map[string]string{"html": "text/html", "lit.html": "text/html", "css": "text/css"}}
+	for _, tt := range jsTests {
+		t.Run(tt.js, func(t *testing.T) {
+			r := bytes.NewBufferString(tt.js)
+			w := &bytes.Buffer{}
+			err := jsMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.js, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestJSRenameSelectors(t *testing.T) {
+	jsTests := []struct {
+		js       string
+		expected string
+	}{
+		{`document.querySelector("#main .navigation-header")`, `document.querySelector("#a .a")`},
+		{`el.closest('.btn');el.matches(".btn")`, `el.closest('.a');el.matches(".a")`},
+		{`document.getElementById("main");document.getElementsByClassName("btn  active")`, `document.getElementById("a");document.getElementsByClassName("a b")`},
+		{`el.classList.add("btn","active");el.classList.toggle("btn",true);el.classList.replace("btn","active")`, `el.classList.add("a","b");el.classList.toggle("a",true);el.classList.replace("a","b")`},
+		{`el.className="btn";el.querySelector(x);el.querySelector("\\.btn");list.add("btn")`, `el.className="btn";el.querySelector(x);el.querySelector("\\.btn");list.add("btn")`},
+	}
+
+	jsMinifier := &Minifier{RenameSelectors: true}
+	for _, tt := range jsTests {
+		t.Run(tt.js, func(t *testing.T) {
+			m := minify.New()
+			m.Names = minify.NewNames()
+			r := bytes.NewBufferString(tt.js)
+			w := &bytes.Buffer{}
+			err := jsMinifier.Minify(m, w, r, nil)
+			test.Minify(t, tt.js, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestBundle(t *testing.T) {
+	bundleTests := []struct {
+		files    map[string]string
+		format   string
+		expected string
+	}{
+		{map[string]string{"main.js": `import {a} from "./a.js";f(a)`, "a.js": `export const a=1`}, "", `(function(){"use strict";const a=1;f(a)}())`},
+		{map[string]string{"main.js": `import {a as b} from "./a";var a=2;function f(){var a;return b}`, "a.js": `export var a=1`}, "", `(function(){"use strict";var a$1=1;var a=2;function f(){var a;return a$1}}())`},
+		{map[string]string{"main.js": `import {a} from "./a.js";a()`, "a.js": `export function a(){return b}`, "b.js": `var b`}, "", `(function(){"use strict";function a(){return b}a()}())`},
+		{map[string]string{"main.js": `import * as ns from "./a.js";ns.a(ns)`, "a.js": `export function a(){}export default 5`}, "", `(function(){"use strict";const a$2={__proto__:null,get a(){return a},get default(){return a$1}};function a(){}const a$1=5;a$2.a(a$2)}())`},
+		{map[string]string{"main.js": `import x,{y} from "./lib/a.js";export {x,y as z};export default 1`, "lib/a.js": `export default class{};export {b as y} from "../b.js"`, "b.js": `export let b=2;b=console`}, "esm", `let b=2;b=console;class a{}const main=1;export{main as default,a as x,b as z}`},
+		{map[string]string{"main.js": `import "./a.js";export * from "./a.js"`, "a.js": `import "./main.js";export default function(){}export const c=1`}, "esm", `function a(){}const c=1;export{c}`},
+		{map[string]string{"main.js": "#!/usr/bin/env node\nimport {x} from 'x';import {a} from './a.js';x(a)", "a.js": `export let a`}, "esm", "#!/usr/bin/env node\nlet a;import{x}from'x';x(a)"},
+		{map[string]string{"main.js": `import "./d.js"`, "d.js": `import {f} from "./e.js";import "lodash";f()`, "e.js": `export function f(){}export const c=1`}, "esm", `function f(){}const c=1;import"lodash";f()`},
+	}
+
+	for _, tt := range bundleTests {
+		t.Run(tt.files["main.js"], func(t *testing.T) {
+			bundler := &Bundler{Format: tt.format, ReadFile: func(filename string) ([]byte, error) {
+				if src, ok := tt.files[filename]; ok {
+					return []byte(src), nil
+				}
+				return nil, os.ErrNotExist
+			}}
+			w := &bytes.Buffer{}
+			err := bundler.Bundle(w, "main.js")
+			test.Minify(t, tt.files["main.js"], err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestBundleErrors(t *testing.T) {
+	bundleTests := []struct {
+		files  map[string]string
+		format string
+		err    string
+	}{
+		{map[string]string{"main.js": `import x from "x"`}, "", "main.js: cannot import external module \"x\" in IIFE format"},
+		{map[string]string{"main.js": `export * from "x"`}, "esm", ""},
+		{map[string]string{"main.js": `import "./a.js"`, "a.js": `export * from "x"`}, "esm", "a.js: cannot re-export external module \"x\""},
+		{map[string]string{"main.js": `import "./d.js"`, "d.js": `import "./e.js";import "lodash"`, "e.js": `window.e=1`}, "esm", "d.js: cannot import external module \"lodash\" after e.js, which has side effects"},
+		{map[string]string{"main.js": `import "./b.js"`}, "", "main.js: cannot find module ./b.js"},
+		{map[string]string{"main.js": `import {b} from "./a.js"`, "a.js": `export var a`}, "", "main.js: a.js does not export b"},
+		{map[string]string{"main.js": `export {b}`}, "", "main.js: cannot export undeclared b"},
+		{map[string]string{"main.js": ``}, "cjs", "unknown bundle format cjs, must be iife or esm"},
+	}
+
+	for _, tt := range bundleTests {
+		t.Run(tt.files["main.js"], func(t *testing.T) {
+			bundler := &Bundler{Format: tt.format, ReadFile: func(filename string) ([]byte, error) {
+				if src, ok := tt.files[filename]; ok {
+					return []byte(src), nil
+				}
+				return nil, os.ErrNotExist
+			}}
+			err := bundler.Bundle(ioutil.Discard, "main.js")
+			if tt.err == "" {
+				test.Error(t, err)
+			} else {
+				test.That(t, err != nil && err.Error() == tt.err, err)
+			}
+		})
+	}
+}
+
+func TestBundleName(t *testing.T) {
+	bundler := &Bundler{Name: "lib", ReadFile: func(filename string) ([]byte, error) {
+		return []byte(`export let a=1;export default function(){return a}`), nil
+	}}
+	w := &bytes.Buffer{}
+	err := bundler.Bundle(w, "main.js")
+	test.Minify(t, "", err, w.String(), `var lib=function(){"use strict";const main$1={__proto__:null,get a(){return a},get default(){return main}};let a=1;function main(){return a}return main$1}()`)
+}
+
+func TestJSSourceMap(t *testing.T) {
+	jsTests := []struct {
+		js       string
+		mangle   bool
+		mappings string
+	}{
+		{"var  a = 5;\n  f(a)", false, "AAAA,IAAK,EAAI,EACP,EAAE"},
+		{"function f(name) {\n  return name;\n}", true, "SAAS,EAAE,UACF"},
+	}
+
+	m := minify.New()
+	for _, tt := range jsTests {
+		t.Run(tt.js, func(t *testing.T) {
+			m.Add("application/javascript", &Minifier{MangleNames: tt.mangle})
+			sm := minify.NewSourceMap("out.js")
+			sm.AddSource("in.js", 0)
+			err := m.MinifyWithSourceMap("application/javascript", ioutil.Discard, bytes.NewBufferString(tt.js), sm, "")
+			test.Error(t, err)
+
+			b, _ := sm.MarshalJSON()
+			test.String(t, string(b), `{"version":3,"file":"out.js","sources":["in.js"],"names":[],"mappings":"`+tt.mappings+`"}`)
+		})
+	}
+}
+
 func TestReaderErrors(t *testing.T) {
 	r := test.NewErrorReader(0)
 	w := &bytes.Buffer{}
@@ -0,0 +1,614 @@
+package js // import "github.com/tdewolff/minify/js"
+
+import (
+	"bytes"
+	"io"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// braceKind is the kind of an open brace, which determines whether a slash following the closing brace starts a regular expression.
+type braceKind int
+
+const (
+	blockBrace braceKind = iota
+	exprBrace
+	templateBrace
+	bodyBrace // body of a function or class expression, which is a block that ends an expression
+)
+
+type brace struct {
+	kind   braceKind
+	parens int // number of open parentheses when the brace was opened
+}
+
+// lexer tokenizes ECMAScript 2020 with the token types of the parse package. In contrast to the ECMAScript 5.1 lexer of the parse package,
+// it returns punctuators such as ?. ?? ** and => as a single token, it lexes numeric separators, BigInts and private names,
+// and it decides between regular expressions and divisions by keeping track of parentheses and braces.
+type lexer struct {
+	src []byte
+	pos int
+	err error
+
+	regexp     bool // a slash starts a regular expression
+	control    bool // the next parenthesis encloses the head of a control statement
+	propName   bool // the next identifier is a property name
+	asyncExpr  bool // the previous async keyword is in an expression
+	bodyExpr   bool // the next brace at bodyParens opens the body of a function or class expression
+	bodyParens int
+	prevTT     js.TokenType
+	prevData   []byte
+	parens     []bool // for each open parenthesis, whether it encloses the head of a control statement
+	braces     []brace
+	emptyLine  bool
+}
+
+func newLexer(src []byte) *lexer {
+	return &lexer{
+		src:       src,
+		regexp:    true,
+		prevTT:    js.ErrorToken,
+		emptyLine: true,
+	}
+}
+
+// Err returns io.EOF when the end of the input has been reached.
+func (l *lexer) Err() error {
+	return l.err
+}
+
+func (l *lexer) peek(n int) byte {
+	if l.pos+n < len(l.src) {
+		return l.src[l.pos+n]
+	}
+	return 0
+}
+
+func (l *lexer) peekRune(n int) (rune, int) {
+	if l.pos+n < len(l.src) {
+		return utf8.DecodeRune(l.src[l.pos+n:])
+	}
+	return utf8.RuneError, 0
+}
+
+// Next returns the next token.
+func (l *lexer) Next() (js.TokenType, []byte) {
+	if len(l.src) <= l.pos {
+		l.err = io.EOF
+		return js.ErrorToken, nil
+	}
+
+	start := l.pos
+	tt := js.UnknownToken
+	c := l.src[l.pos]
+	switch c {
+	case ' ', '\t', '\v', '\f':
+		l.pos++
+		for l.consumeWhitespace() {
+		}
+		return js.WhitespaceToken, l.src[start:l.pos]
+	case '\n', '\r':
+		l.pos++
+		for l.consumeLineTerminator() {
+		}
+		l.emptyLine = true
+		return js.LineTerminatorToken, l.src[start:l.pos]
+	case '/':
+		if c := l.peek(1); c == '/' {
+			l.consumeSingleLineComment()
+			return js.SingleLineCommentToken, l.src[start:l.pos]
+		} else if c == '*' {
+			// like the lexer of the parse package, comments without line terminators are single-line comments
+			if l.consumeMultiLineComment() {
+				l.emptyLine = true
+				return js.MultiLineCommentToken, l.src[start:l.pos]
+			}
+			return js.SingleLineCommentToken, l.src[start:l.pos]
+		} else if l.regexp && l.consumeRegexp() {
+			tt = js.RegexpToken
+		} else {
+			tt = l.consumePunctuator()
+		}
+	case '<':
+		if bytes.HasPrefix(l.src[l.pos:], []byte("<!--")) {
+			l.consumeSingleLineComment()
+			return js.SingleLineCommentToken, l.src[start:l.pos]
+		}
+		tt = l.consumePunctuator()
+	case '-':
+		if l.emptyLine && bytes.HasPrefix(l.src[l.pos:], []byte("-->")) {
+			l.consumeSingleLineComment()
+			return js.SingleLineCommentToken, l.src[start:l.pos]
+		}
+		tt = l.consumePunctuator()
+	case '#':
+		if l.pos == 0 && l.peek(1) == '!' {
+			// hashbang
+			l.consumeSingleLineComment()
+			return js.SingleLineCommentToken, l.src[start:l.pos]
+		}
+		l.pos++
+		if l.consumeIdentifierStart() {
+			for l.consumeIdentifierPart() {
+			}
+			tt = js.IdentifierToken
+		} else {
+			l.pos--
+		}
+	case '`':
+		if l.consumeTemplate() {
+			tt = js.TemplateToken
+		}
+	case '}':
+		if n := len(l.braces); 0 < n && l.braces[n-1].kind == templateBrace {
+			if l.consumeTemplate() {
+				tt = js.TemplateToken
+			}
+		} else {
+			tt = l.consumePunctuator()
+		}
+	case '"', '\'':
+		if l.consumeString() {
+			tt = js.StringToken
+		}
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		l.consumeNumber()
+		tt = js.NumericToken
+	case '.':
+		if c := l.peek(1); '0' <= c && c <= '9' {
+			l.consumeNumber()
+			tt = js.NumericToken
+		} else {
+			tt = l.consumePunctuator()
+		}
+	default:
+		if l.consumeIdentifierStart() {
+			for l.consumeIdentifierPart() {
+			}
+			tt = js.IdentifierToken
+		} else if 0x80 <= c {
+			if l.consumeWhitespace() {
+				for l.consumeWhitespace() {
+				}
+				return js.WhitespaceToken, l.src[start:l.pos]
+			} else if l.consumeLineTerminator() {
+				for l.consumeLineTerminator() {
+				}
+				l.emptyLine = true
+				return js.LineTerminatorToken, l.src[start:l.pos]
+			}
+		} else {
+			tt = l.consumePunctuator()
+		}
+	}
+
+	if tt == js.UnknownToken && l.pos == start {
+		_, n := l.peekRune(0)
+		l.pos += n
+	}
+	data := l.src[start:l.pos]
+	l.update(tt, data)
+	l.emptyLine = false
+	return tt, data
+}
+
+// update keeps track of the context after a significant token.
+func (l *lexer) update(tt js.TokenType, data []byte) {
+	control := false
+	propName := false
+	switch tt {
+	case js.IdentifierToken:
+		if l.propName {
+			l.regexp = false
+		} else {
+			switch string(data) {
+			case "if", "while", "for", "with":
+				control = true
+				l.regexp = false
+			case "await":
+				control = l.control // for await (
+				l.regexp = true
+			case "async", "function", "class":
+				expr := l.regexp && l.braceKind() == exprBrace
+				if string(data) == "async" {
+					l.asyncExpr = expr
+				} else if l.prevTT == js.IdentifierToken && string(l.prevData) == "async" {
+					expr = l.asyncExpr
+				}
+				if expr && string(data) != "async" {
+					l.bodyExpr = true
+					l.bodyParens = len(l.parens)
+				}
+				l.regexp = false
+			default:
+				l.regexp = regexpKeywords[string(data)]
+			}
+		}
+	case js.PunctuatorToken:
+		switch data[0] {
+		case '(':
+			l.parens = append(l.parens, l.control)
+			l.regexp = true
+		case ')':
+			l.regexp = false
+			if n := len(l.parens); 0 < n {
+				l.regexp = l.parens[n-1]
+				l.parens = l.parens[:n-1]
+			}
+		case '{':
+			kind := l.braceKind()
+			if l.bodyExpr && l.bodyParens == len(l.parens) {
+				kind = bodyBrace
+				l.bodyExpr = false
+			}
+			l.braces = append(l.braces, brace{kind, len(l.parens)})
+			l.regexp = true
+		case '}':
+			l.regexp = true
+			if n := len(l.braces); 0 < n {
+				l.regexp = l.braces[n-1].kind == blockBrace
+				l.braces = l.braces[:n-1]
+			}
+		case ']':
+			l.regexp = false
+		case '.':
+			propName = len(data) == 1
+			l.regexp = !propName // spread operator
+		case '?':
+			propName = len(data) == 2 && data[1] == '.'
+			l.regexp = !propName
+		default:
+			l.regexp = !bytes.Equal(data, []byte("++")) && !bytes.Equal(data, []byte("--"))
+		}
+	case js.TemplateToken:
+		if data[0] == '}' && 0 < len(l.braces) {
+			l.braces = l.braces[:len(l.braces)-1]
+		}
+		if bytes.HasSuffix(data, []byte("${")) {
+			l.braces = append(l.braces, brace{templateBrace, len(l.parens)})
+			l.regexp = true
+		} else {
+			l.regexp = false
+		}
+	case js.NumericToken, js.StringToken, js.RegexpToken:
+		l.regexp = false
+	default:
+		l.regexp = true
+	}
+	l.control = control
+	l.propName = propName
+	l.prevTT, l.prevData = tt, data
+}
+
+// braceKind determines whether an opening brace starts a block or an object literal, depending on the previous token.
+func (l *lexer) braceKind() braceKind {
+	switch l.prevTT {
+	case js.ErrorToken:
+		return blockBrace
+	case js.IdentifierToken:
+		if name := string(l.prevData); regexpKeywords[name] && name != "do" && name != "else" {
+			return exprBrace // such as return {...}
+		}
+		return blockBrace // class bodies and statements such as else, do, try and finally
+	case js.PunctuatorToken:
+		switch string(l.prevData) {
+		case ";", "{", "}", ")", "=>":
+			return blockBrace
+		case ":":
+			// labeled statements and case clauses contain blocks, properties and conditionals contain objects
+			if n := len(l.braces); n == 0 || (l.braces[n-1].kind == blockBrace || l.braces[n-1].kind == bodyBrace) && l.braces[n-1].parens == len(l.parens) {
+				return blockBrace
+			}
+		}
+	}
+	return exprBrace
+}
+
+// regexpKeywords are keywords after which a slash starts a regular expression.
+var regexpKeywords = map[string]bool{
+	"await":      true,
+	"case":       true,
+	"delete":     true,
+	"do":         true,
+	"else":       true,
+	"in":         true,
+	"instanceof": true,
+	"new":        true,
+	"of":         true,
+	"return":     true,
+	"throw":      true,
+	"typeof":     true,
+	"void":       true,
+	"yield":      true,
+}
+
+////////////////////////////////////////////////////////////////
+
+// punctuators ordered such that longer punctuators come before their prefixes.
+var punctuators = [][]byte{
+	[]byte(">>>="), []byte("..."), []byte("==="), []byte("!=="), []byte("**="), []byte("<<="), []byte(">>="), []byte(">>>"),
+	[]byte("&&="), []byte("||="), []byte("??="), []byte("=>"), []byte("=="), []byte("!="), []byte("<="), []byte(">="),
+	[]byte("&&"), []byte("||"), []byte("??"), []byte("?."), []byte("++"), []byte("--"), []byte("+="), []byte("-="),
+	[]byte("*="), []byte("/="), []byte("%="), []byte("&="), []byte("|="), []byte("^="), []byte("<<"), []byte(">>"),
+	[]byte("**"),
+}
+
+func (l *lexer) consumePunctuator() js.TokenType {
+	rest := l.src[l.pos:]
+	for _, punct := range punctuators {
+		if bytes.HasPrefix(rest, punct) {
+			if punct[0] == '?' && punct[1] == '.' && 2 < len(rest) && '0' <= rest[2] && rest[2] <= '9' {
+				continue // conditional followed by a number, such as a?.5:b
+			}
+			l.pos += len(punct)
+			return js.PunctuatorToken
+		}
+	}
+	switch rest[0] {
+	case '{', '}', '(', ')', '[', ']', '.', ';', ',', '<', '>', '+', '-', '*', '/', '%', '&', '|', '^', '!', '~', '?', ':', '=':
+		l.pos++
+		return js.PunctuatorToken
+	}
+	return js.UnknownToken
+}
+
+func (l *lexer) consumeWhitespace() bool {
+	c := l.peek(0)
+	if c == ' ' || c == '\t' || c == '\v' || c == '\f' {
+		l.pos++
+		return true
+	} else if 0x80 <= c {
+		if r, n := l.peekRune(0); r == '\u00A0' || r == '\uFEFF' || unicode.Is(unicode.Zs, r) {
+			l.pos += n
+			return true
+		}
+	}
+	return false
+}
+
+func (l *lexer) consumeLineTerminator() bool {
+	c := l.peek(0)
+	if c == '\n' || c == '\r' {
+		l.pos++
+		return true
+	} else if c == 0xE2 && l.peek(1) == 0x80 && (l.peek(2) == 0xA8 || l.peek(2) == 0xA9) {
+		l.pos += 3 // U+2028 and U+2029
+		return true
+	}
+	return false
+}
+
+func (l *lexer) isLineTerminator() bool {
+	c := l.peek(0)
+	return c == '\n' || c == '\r' || c == 0xE2 && l.peek(1) == 0x80 && (l.peek(2) == 0xA8 || l.peek(2) == 0xA9)
+}
+
+func (l *lexer) consumeSingleLineComment() {
+	for l.pos < len(l.src) && !l.isLineTerminator() {
+		l.pos++
+	}
+}
+
+// consumeMultiLineComment consumes the comment and returns true if it contains a line terminator.
+func (l *lexer) consumeMultiLineComment() bool {
+	l.pos += 2
+	newline := false
+	for l.pos < len(l.src) {
+		if l.peek(0) == '*' && l.peek(1) == '/' {
+			l.pos += 2
+			break
+		} else if l.consumeLineTerminator() {
+			newline = true
+		} else {
+			l.pos++
+		}
+	}
+	return newline
+}
+
+var idStart = []*unicode.RangeTable{unicode.L, unicode.Nl, unicode.Other_ID_Start}
+var idContinue = []*unicode.RangeTable{unicode.L, unicode.Nl, unicode.Other_ID_Start, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc, unicode.Other_ID_Continue}
+
+func (l *lexer) consumeIdentifierStart() bool {
+	c := l.peek(0)
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '$' || c == '_' {
+		l.pos++
+		return true
+	} else if c == '\\' {
+		return l.consumeUnicodeEscape()
+	} else if 0x80 <= c {
+		if r, n := l.peekRune(0); unicode.IsOneOf(idStart, r) {
+			l.pos += n
+			return true
+		}
+	}
+	return false
+}
+
+func (l *lexer) consumeIdentifierPart() bool {
+	c := l.peek(0)
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '$' || c == '_' {
+		l.pos++
+		return true
+	} else if c == '\\' {
+		return l.consumeUnicodeEscape()
+	} else if 0x80 <= c {
+		if r, n := l.peekRune(0); r == '\u200C' || r == '\u200D' || unicode.IsOneOf(idContinue, r) {
+			l.pos += n
+			return true
+		}
+	}
+	return false
+}
+
+// consumeUnicodeEscape consumes \uXXXX or \u{X...} in identifiers.
+func (l *lexer) consumeUnicodeEscape() bool {
+	if l.peek(1) != 'u' {
+		return false
+	}
+	n := 2
+	if l.peek(n) == '{' {
+		n++
+		for isHex(l.peek(n)) {
+			n++
+		}
+		if n == 3 || l.peek(n) != '}' {
+			return false
+		}
+		n++
+	} else {
+		for i := 0; i < 4; i++ {
+			if !isHex(l.peek(n)) {
+				return false
+			}
+			n++
+		}
+	}
+	l.pos += n
+	return true
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func (l *lexer) consumeDigits(valid func(byte) bool) {
+	for {
+		if c := l.peek(0); valid(c) {
+			l.pos++
+		} else if c == '_' && valid(l.peek(1)) && 0 < l.pos && valid(l.src[l.pos-1]) {
+			l.pos++ // numeric separator
+		} else {
+			return
+		}
+	}
+}
+
+func isDecimal(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isOctal(c byte) bool {
+	return '0' <= c && c <= '7'
+}
+
+func isBinary(c byte) bool {
+	return c == '0' || c == '1'
+}
+
+// consumeNumber consumes decimal, hexadecimal, octal and binary numbers and BigInts.
+func (l *lexer) consumeNumber() {
+	if l.peek(0) == '0' {
+		var valid func(byte) bool
+		switch l.peek(1) {
+		case 'x', 'X':
+			valid = isHex
+		case 'o', 'O':
+			valid = isOctal
+		case 'b', 'B':
+			valid = isBinary
+		}
+		if valid != nil && valid(l.peek(2)) {
+			l.pos += 2
+			l.consumeDigits(valid)
+			if l.peek(0) == 'n' {
+				l.pos++
+			}
+			return
+		}
+	}
+
+	l.consumeDigits(isDecimal)
+	integer := true
+	if l.peek(0) == '.' {
+		l.pos++
+		l.consumeDigits(isDecimal)
+		integer = false
+	}
+	if c := l.peek(0); c == 'e' || c == 'E' {
+		n := 1
+		if c := l.peek(1); c == '+' || c == '-' {
+			n++
+		}
+		if isDecimal(l.peek(n)) {
+			l.pos += n
+			l.consumeDigits(isDecimal)
+			integer = false
+		}
+	}
+	if integer && l.peek(0) == 'n' {
+		l.pos++
+	}
+}
+
+func (l *lexer) consumeString() bool {
+	quote := l.peek(0)
+	n := 1
+	for l.pos+n < len(l.src) {
+		c := l.src[l.pos+n]
+		if c == quote {
+			l.pos += n + 1
+			return true
+		} else if c == '\\' {
+			n++
+			if l.pos+n < len(l.src) && l.src[l.pos+n] == '\r' && l.peek(n+1) == '\n' {
+				n++
+			}
+		} else if c == '\n' || c == '\r' {
+			return false
+		}
+		n++
+	}
+	return false
+}
+
+// consumeTemplate consumes a template from a backtick or closing brace up to and including the next backtick or ${.
+func (l *lexer) consumeTemplate() bool {
+	n := 1
+	for l.pos+n < len(l.src) {
+		c := l.src[l.pos+n]
+		if c == '`' {
+			l.pos += n + 1
+			return true
+		} else if c == '$' && l.peek(n+1) == '{' {
+			l.pos += n + 2
+			return true
+		} else if c == '\\' {
+			n++
+		}
+		n++
+	}
+	return false
+}
+
+func (l *lexer) consumeRegexp() bool {
+	n := 1
+	class := false
+	for {
+		if len(l.src) <= l.pos+n {
+			return false
+		}
+		c := l.src[l.pos+n]
+		if c == '\n' || c == '\r' || c == 0xE2 && l.peek(n+1) == 0x80 && (l.peek(n+2) == 0xA8 || l.peek(n+2) == 0xA9) {
+			return false
+		} else if c == '\\' {
+			n++
+			if c := l.peek(n); c == '\n' || c == '\r' {
+				return false
+			}
+		} else if c == '[' {
+			class = true
+		} else if c == ']' {
+			class = false
+		} else if c == '/' && !class {
+			break
+		}
+		n++
+	}
+	l.pos += n + 1
+	for l.consumeIdentifierPart() {
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package js // import "github.com/tdewolff/minify/js"
+
+import (
+	"bytes"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// selectorMethods are the DOM methods whose first argument is a selector.
+var selectorMethods = map[string]bool{
+	"closest":          true,
+	"matches":          true,
+	"querySelector":    true,
+	"querySelectorAll": true,
+}
+
+// classListMethods are the methods of classList whose arguments are class names, where toggle has a boolean second argument.
+var classListMethods = map[string]bool{
+	"add":      true,
+	"contains": true,
+	"remove":   true,
+	"replace":  true,
+	"toggle":   true,
+}
+
+// renameSelectors renames the class names and ids in string literals passed to DOM methods such as querySelector, getElementById and classList.add.
+// Names in other strings, such as those assigned to className or built by concatenation, are not renamed.
+func renameSelectors(list []stmt, names *minify.Names) {
+	walkStmts(list, func(n interface{}) bool {
+		call, ok := n.(*callExpr)
+		if !ok {
+			return true
+		}
+		member, ok := call.callee.(*memberExpr)
+		if !ok || member.name == nil {
+			return true
+		}
+
+		method := string(member.name)
+		first := true // only the first argument has names
+		var rename func([]byte) []byte
+		if selectorMethods[method] {
+			rename = names.Selector
+		} else if method == "getElementById" {
+			rename = names.ID
+		} else if method == "getElementsByClassName" {
+			rename = func(val []byte) []byte {
+				return renameList(val, names.Class)
+			}
+		} else if obj, ok := member.obj.(*memberExpr); ok && classListMethods[method] && bytes.Equal(obj.name, []byte("classList")) {
+			rename = names.Class
+			first = method == "toggle"
+		} else {
+			return true
+		}
+
+		args := call.args
+		if first && 1 < len(args) {
+			args = args[:1]
+		}
+		for _, arg := range args {
+			if lit, ok := arg.(*literal); ok && lit.tt == js.StringToken {
+				renameString(lit, rename)
+			}
+		}
+		return true
+	})
+}
+
+// renameString renames the contents of a string literal, strings with escapes are kept as is.
+func renameString(lit *literal, rename func([]byte) []byte) {
+	val := lit.data[1 : len(lit.data)-1]
+	if len(val) == 0 || bytes.IndexByte(val, '\\') != -1 {
+		return
+	}
+	quote := lit.data[0]
+	data := append([]byte{quote}, rename(val)...)
+	lit.data = append(data, quote)
+}
+
+func renameList(val []byte, rename func([]byte) []byte) []byte {
+	b := []byte{}
+	for _, name := range bytes.Fields(val) {
+		if 0 < len(b) {
+			b = append(b, ' ')
+		}
+		b = append(b, rename(name)...)
+	}
+	return b
+}
@@ -0,0 +1,949 @@
+package js // import "github.com/tdewolff/minify/js"
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// optimizer rewrites the AST in place, it replaces defines, folds constant expressions and removes code that can never be executed or has no effect.
+type optimizer struct {
+	fold         bool              // fold constant expressions and write booleans as !0 and !1
+	deadCode     bool              // remove unreachable statements and branches with constant conditions
+	dropDebugger bool              // remove debugger statements
+	pure         map[string]bool   // global functions whose calls are removed when their result is not used
+	define       map[string][]byte // source of the expressions that replace global names
+}
+
+func (o *optimizer) stmtList(list []stmt) []stmt {
+	out := list[:0]
+	unreachable := false
+	for _, s := range list {
+		if unreachable {
+			// function and var declarations are hoisted and may be used before
+			switch s.(type) {
+			case *funcDecl, *commentStmt:
+				out = append(out, o.stmt(s))
+			default:
+				if decl := hoistedVars(s); decl != nil {
+					out = append(out, decl)
+				}
+			}
+			continue
+		}
+
+		_, isBlock := s.(*blockStmt)
+		s = o.stmt(s)
+		if block, ok := s.(*blockStmt); ok && !isBlock && !hasLexical(block.list) {
+			out = append(out, block.list...) // remaining branch of an if statement
+			continue
+		}
+		out = append(out, s)
+		if o.deadCode {
+			switch s.(type) {
+			case *returnStmt, *throwStmt, *branchStmt:
+				unreachable = true
+			}
+		}
+	}
+	return out
+}
+
+func (o *optimizer) stmt(s stmt) stmt {
+	switch s := s.(type) {
+	case *blockStmt:
+		s.list = o.stmtList(s.list)
+	case *exprStmt:
+		x := o.unused(o.expr(s.x))
+		if x == nil {
+			return &emptyStmt{}
+		} else if c := constantOf(x); o.deadCode && c.kind != constNone && c.kind != constString {
+			return &emptyStmt{}
+		} else if lit, ok := x.(*literal); !ok || lit.tt != js.StringToken {
+			s.x = x // folding into a string could create a directive such as "use strict"
+		}
+	case *varDecl:
+		for i := range s.list {
+			s.list[i].target = o.expr(s.list[i].target)
+			if s.list[i].init != nil {
+				s.list[i].init = o.expr(s.list[i].init)
+			}
+		}
+	case *funcDecl:
+		o.function(s.f)
+	case *classDecl:
+		o.class(s.c)
+	case *ifStmt:
+		s.cond = o.expr(s.cond)
+		s.body = o.stmt(s.body)
+		if s.els != nil {
+			s.els = o.stmt(s.els)
+		}
+		if o.deadCode {
+			if truthy, ok := constantOf(s.cond).truthy(); ok {
+				body, dropped := s.body, s.els
+				if !truthy {
+					body, dropped = s.els, s.body
+				}
+				list := []stmt{}
+				if block, ok := body.(*blockStmt); ok && !hasLexical(block.list) {
+					list = append(list, block.list...)
+				} else if body != nil {
+					list = append(list, body)
+				}
+				if decl := hoistedVars(dropped); decl != nil {
+					list = append(list, decl)
+				}
+				if len(list) == 0 {
+					return &emptyStmt{}
+				} else if len(list) == 1 {
+					return list[0]
+				}
+				return &blockStmt{list: list}
+			}
+		}
+	case *doWhileStmt:
+		s.body = o.stmt(s.body)
+		s.cond = o.expr(s.cond)
+	case *whileStmt:
+		s.cond = o.expr(s.cond)
+		s.body = o.stmt(s.body)
+		if truthy, ok := constantOf(s.cond).truthy(); o.deadCode && ok && !truthy {
+			if decl := hoistedVars(s.body); decl != nil {
+				return decl
+			}
+			return &emptyStmt{}
+		}
+	case *forStmt:
+		if x, ok := s.init.(*exprStmt); ok {
+			x.x = o.expr(x.x)
+		} else if s.init != nil {
+			s.init = o.stmt(s.init)
+		}
+		if s.cond != nil {
+			s.cond = o.expr(s.cond)
+		}
+		if s.post != nil {
+			s.post = o.unused(o.expr(s.post))
+		}
+		s.body = o.stmt(s.body)
+	case *forInStmt:
+		if x, ok := s.init.(*exprStmt); ok {
+			x.x = o.target(x.x)
+		} else {
+			s.init = o.stmt(s.init)
+		}
+		s.value = o.expr(s.value)
+		s.body = o.stmt(s.body)
+	case *returnStmt:
+		if s.x != nil {
+			s.x = o.expr(s.x)
+		}
+	case *throwStmt:
+		s.x = o.expr(s.x)
+	case *tryStmt:
+		s.body.list = o.stmtList(s.body.list)
+		if s.catch != nil {
+			if s.param != nil {
+				s.param = o.expr(s.param)
+			}
+			s.catch.list = o.stmtList(s.catch.list)
+		}
+		if s.finally != nil {
+			s.finally.list = o.stmtList(s.finally.list)
+		}
+	case *switchStmt:
+		s.x = o.expr(s.x)
+		for i := range s.cases {
+			if s.cases[i].test != nil {
+				s.cases[i].test = o.expr(s.cases[i].test)
+			}
+			s.cases[i].list = o.stmtList(s.cases[i].list)
+		}
+	case *labeledStmt:
+		s.body = o.stmt(s.body)
+	case *withStmt:
+		s.x = o.expr(s.x)
+		s.body = o.stmt(s.body)
+	case *debuggerStmt:
+		if o.dropDebugger {
+			return &emptyStmt{}
+		}
+	case *exportStmt:
+		if x, ok := s.decl.(*exprStmt); ok {
+			x.x = o.expr(x.x)
+		} else if s.decl != nil {
+			s.decl = o.stmt(s.decl)
+		}
+	}
+	return s
+}
+
+func (o *optimizer) function(f *funcNode) {
+	for i, param := range f.params {
+		f.params[i] = o.expr(param)
+	}
+	f.body = o.stmtList(f.body)
+	if f.exprBody != nil {
+		f.exprBody = o.expr(f.exprBody)
+	}
+}
+
+func (o *optimizer) class(c *classNode) {
+	if c.extends != nil {
+		c.extends = o.expr(c.extends)
+	}
+	o.properties(c.list)
+}
+
+func (o *optimizer) properties(list []property) {
+	for i := range list {
+		if list[i].computed {
+			list[i].key = o.expr(list[i].key)
+		}
+		if list[i].value != nil {
+			list[i].value = o.expr(list[i].value)
+		}
+	}
+}
+
+// callee optimizes the callee of a call, which must not become a member expression or eval as that changes the value of this or turns it into a direct eval.
+func (o *optimizer) callee(e expr) expr {
+	if x := o.defined(e); x != nil {
+		return x
+	}
+	x := o.expr(e)
+	if x != e {
+		switch y := x.(type) {
+		case *memberExpr:
+			return &seqExpr{[]expr{&literal{js.NumericToken, []byte("0"), 0}, y}}
+		case *ident:
+			if string(y.name) == "eval" {
+				return &seqExpr{[]expr{&literal{js.NumericToken, []byte("0"), 0}, y}}
+			}
+		}
+	}
+	return x
+}
+
+func (o *optimizer) exprList(list []expr) {
+	for i, item := range list {
+		if item != nil {
+			list[i] = o.expr(item)
+		}
+	}
+}
+
+// target optimizes an assignment target, which is never replaced by a define.
+func (o *optimizer) target(e expr) expr {
+	switch e := e.(type) {
+	case *ident:
+	case *memberExpr:
+		e.obj = o.expr(e.obj)
+		if e.index != nil {
+			e.index = o.expr(e.index)
+		}
+	case *arrayExpr:
+		for i, item := range e.list {
+			if item != nil {
+				e.list[i] = o.target(item)
+			}
+		}
+	case *objectExpr:
+		for i := range e.list {
+			if e.list[i].computed {
+				e.list[i].key = o.expr(e.list[i].key)
+			}
+			e.list[i].value = o.target(e.list[i].value)
+		}
+	case *assignExpr:
+		e.target = o.target(e.target)
+		e.value = o.expr(e.value)
+	case *spreadExpr:
+		e.x = o.target(e.x)
+	default:
+		return o.expr(e)
+	}
+	return e
+}
+
+func (o *optimizer) expr(e expr) expr {
+	switch e := e.(type) {
+	case *ident:
+		if x := o.defined(e); x != nil {
+			return x
+		}
+	case *literal:
+		if o.fold && e.tt == js.IdentifierToken && (string(e.data) == "true" || string(e.data) == "false") {
+			return constantOf(e).expr()
+		}
+	case *templateExpr:
+		if e.tag != nil {
+			e.tag = o.callee(e.tag)
+		}
+		o.exprList(e.list)
+	case *arrayExpr:
+		o.exprList(e.list)
+	case *objectExpr:
+		o.properties(e.list)
+	case *funcExpr:
+		o.function(e.f)
+	case *classExpr:
+		o.class(e.c)
+	case *unaryExpr:
+		if string(e.op) == "delete" {
+			e.x = o.callee(e.x)
+		} else if string(e.op) == "++" || string(e.op) == "--" {
+			e.x = o.target(e.x)
+		} else {
+			e.x = o.expr(e.x)
+		}
+		if c := constantOf(e); o.fold && c.kind != constNone {
+			if x := c.expr(); x != nil && exprLen(x) < exprLen(e) {
+				return x
+			}
+		}
+	case *postfixExpr:
+		e.x = o.target(e.x)
+	case *binaryExpr:
+		e.x = o.expr(e.x)
+		e.y = o.expr(e.y)
+		switch string(e.op) {
+		case "&&", "||", "??":
+			if c := constantOf(e.x); (o.fold || o.deadCode) && c.kind != constNone {
+				if keepLeft(string(e.op), c) {
+					return e.x
+				}
+				return e.y
+			}
+		default:
+			if c := constantOf(e); o.fold && c.kind != constNone {
+				if x := c.expr(); x != nil && exprLen(x) <= exprLen(e) {
+					return x
+				}
+			}
+		}
+	case *assignExpr:
+		e.target = o.target(e.target)
+		e.value = o.expr(e.value)
+	case *condExpr:
+		e.cond = o.expr(e.cond)
+		e.x = o.expr(e.x)
+		e.y = o.expr(e.y)
+		if truthy, ok := constantOf(e.cond).truthy(); (o.fold || o.deadCode) && ok {
+			if truthy {
+				return e.x
+			}
+			return e.y
+		}
+	case *seqExpr:
+		o.exprList(e.list)
+		if 0 < len(o.pure) {
+			list := e.list[:0]
+			for i, item := range e.list {
+				if i+1 == len(e.list) {
+					list = append(list, item)
+				} else if x := o.unused(item); x != nil {
+					list = append(list, x)
+				}
+			}
+			if len(list) == 1 {
+				return list[0]
+			}
+			e.list = list
+		}
+	case *callExpr:
+		e.callee = o.callee(e.callee)
+		o.exprList(e.args)
+	case *newExpr:
+		e.callee = o.expr(e.callee)
+		o.exprList(e.args)
+	case *memberExpr:
+		if x := o.defined(e); x != nil {
+			return x
+		}
+		e.obj = o.expr(e.obj)
+		if e.index != nil {
+			e.index = o.expr(e.index)
+		}
+	case *yieldExpr:
+		if e.x != nil {
+			e.x = o.expr(e.x)
+		}
+	case *spreadExpr:
+		e.x = o.expr(e.x)
+	}
+	return e
+}
+
+// unused removes calls to pure functions from an expression whose result is not used, it returns nil if nothing remains.
+func (o *optimizer) unused(e expr) expr {
+	if len(o.pure) == 0 {
+		return e
+	}
+	switch e := e.(type) {
+	case *callExpr:
+		if !o.pure[globalName(e.callee)] {
+			break
+		}
+		list := []expr{}
+		for _, arg := range e.args {
+			if _, ok := arg.(*spreadExpr); ok {
+				return e // spreading calls the iterator
+			} else if x := o.unused(arg); x != nil && hasSideEffects(x) {
+				list = append(list, x)
+			}
+		}
+		if len(list) == 0 {
+			return nil
+		} else if len(list) == 1 {
+			return list[0]
+		}
+		return &seqExpr{list}
+	case *seqExpr:
+		list := e.list[:0]
+		for _, item := range e.list {
+			if x := o.unused(item); x != nil {
+				list = append(list, x)
+			}
+		}
+		if len(list) == 0 {
+			return nil
+		} else if len(list) == 1 {
+			return list[0]
+		}
+		e.list = list
+	case *binaryExpr:
+		if op := string(e.op); op == "&&" || op == "||" || op == "??" {
+			if e.y = o.unused(e.y); e.y == nil {
+				return e.x
+			}
+		}
+	case *condExpr:
+		x, y := o.unused(e.x), o.unused(e.y)
+		if x == nil && y == nil {
+			return e.cond
+		} else if x == nil {
+			x = &unaryExpr{[]byte("void"), &literal{js.NumericToken, []byte("0"), 0}}
+		} else if y == nil {
+			y = &unaryExpr{[]byte("void"), &literal{js.NumericToken, []byte("0"), 0}}
+		}
+		e.x, e.y = x, y
+	}
+	return e
+}
+
+// hasSideEffects returns true if evaluating the expression may have effects other than its value, where reading variables and properties is assumed to have none.
+func hasSideEffects(e expr) bool {
+	switch e := e.(type) {
+	case *ident, *literal, *funcExpr:
+		return false
+	case *templateExpr:
+		if e.tag != nil {
+			return true
+		}
+		for _, item := range e.list {
+			if hasSideEffects(item) {
+				return true
+			}
+		}
+		return false
+	case *arrayExpr:
+		for _, item := range e.list {
+			if item != nil && hasSideEffects(item) {
+				return true
+			}
+		}
+		return false
+	case *objectExpr:
+		for _, prop := range e.list {
+			if prop.kind == propSpread || prop.computed && hasSideEffects(prop.key) || prop.value != nil && hasSideEffects(prop.value) {
+				return true
+			}
+		}
+		return false
+	case *unaryExpr:
+		switch string(e.op) {
+		case "delete", "++", "--", "await":
+			return true
+		}
+		return hasSideEffects(e.x)
+	case *binaryExpr:
+		return hasSideEffects(e.x) || hasSideEffects(e.y)
+	case *condExpr:
+		return hasSideEffects(e.cond) || hasSideEffects(e.x) || hasSideEffects(e.y)
+	case *seqExpr:
+		for _, item := range e.list {
+			if hasSideEffects(item) {
+				return true
+			}
+		}
+		return false
+	case *memberExpr:
+		return hasSideEffects(e.obj) || e.index != nil && hasSideEffects(e.index)
+	}
+	return true
+}
+
+// defined returns the expression that replaces a global identifier or member chain, or nil if it is not defined.
+func (o *optimizer) defined(e expr) expr {
+	if len(o.define) == 0 {
+		return nil
+	}
+	if src, ok := o.define[globalName(e)]; ok {
+		x, _, _ := parseExpression(src) // always succeeds as it was parsed before
+		return x
+	}
+	return nil
+}
+
+// globalName returns the dotted name of a global identifier or of a member chain on a global identifier, such as process.env.NODE_ENV.
+func globalName(e expr) string {
+	switch e := e.(type) {
+	case *ident:
+		if e.v == nil {
+			return string(e.name)
+		}
+	case *literal:
+		if string(e.data) == "import.meta" {
+			return "import.meta"
+		}
+	case *memberExpr:
+		if e.name != nil && !e.optional && !e.inChain {
+			if obj := globalName(e.obj); obj != "" {
+				return obj + "." + string(e.name)
+			}
+		}
+	}
+	return ""
+}
+
+// keepLeft returns true if a logical expression with a constant left operand evaluates to its left operand.
+func keepLeft(op string, c constant) bool {
+	if op == "??" {
+		return c.kind != constUndefined && c.kind != constNull
+	}
+	truthy, _ := c.truthy()
+	return truthy == (op == "||")
+}
+
+// hoistedVars returns a var declaration without initializers for all variables declared by var or by function declarations in blocks in s,
+// or nil if there are none.
+func hoistedVars(s stmt) stmt {
+	decl := &varDecl{tok: js.Var}
+	var walk func(stmt)
+	walk = func(s stmt) {
+		switch s := s.(type) {
+		case *blockStmt:
+			for _, item := range s.list {
+				walk(item)
+			}
+		case *varDecl:
+			if s.tok == js.Var {
+				for _, b := range s.list {
+					for _, id := range bindingIdents(b.target, nil) {
+						decl.list = append(decl.list, binding{id, nil})
+					}
+				}
+			}
+		case *funcDecl:
+			if s.f.name != nil {
+				decl.list = append(decl.list, binding{s.f.name, nil}) // functions in blocks also declare a var (Annex B)
+			}
+		case *ifStmt:
+			walk(s.body)
+			walk(s.els)
+		case *doWhileStmt:
+			walk(s.body)
+		case *whileStmt:
+			walk(s.body)
+		case *forStmt:
+			walk(s.init)
+			walk(s.body)
+		case *forInStmt:
+			walk(s.init)
+			walk(s.body)
+		case *tryStmt:
+			walk(s.body)
+			if s.catch != nil {
+				walk(s.catch)
+			}
+			if s.finally != nil {
+				walk(s.finally)
+			}
+		case *switchStmt:
+			for _, c := range s.cases {
+				for _, item := range c.list {
+					walk(item)
+				}
+			}
+		case *labeledStmt:
+			walk(s.body)
+		case *withStmt:
+			walk(s.body)
+		}
+	}
+	walk(s)
+	if len(decl.list) == 0 {
+		return nil
+	}
+	return decl
+}
+
+// bindingIdents appends the identifiers that are bound by a binding target or pattern.
+func bindingIdents(e expr, ids []*ident) []*ident {
+	switch e := e.(type) {
+	case *ident:
+		ids = append(ids, e)
+	case *arrayExpr:
+		for _, item := range e.list {
+			ids = bindingIdents(item, ids)
+		}
+	case *objectExpr:
+		for _, prop := range e.list {
+			ids = bindingIdents(prop.value, ids)
+		}
+	case *assignExpr:
+		ids = bindingIdents(e.target, ids)
+	case *spreadExpr:
+		ids = bindingIdents(e.x, ids)
+	}
+	return ids
+}
+
+// hasLexical returns true if the statements declare block scoped variables, so that they cannot be moved out of their block.
+func hasLexical(list []stmt) bool {
+	for _, s := range list {
+		switch s := s.(type) {
+		case *funcDecl, *classDecl:
+			return true
+		case *varDecl:
+			if s.tok != js.Var {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////////
+
+type constKind int
+
+const (
+	constNone constKind = iota // not a constant
+	constUndefined
+	constNull
+	constBool
+	constNumber
+	constString
+)
+
+// constant is the value of an expression that can be evaluated at compile time.
+type constant struct {
+	kind constKind
+	b    bool
+	f    float64
+	s    string
+}
+
+// constantOf evaluates an expression consisting of literals and operators, it returns constNone when the value is unknown or has side effects.
+func constantOf(e expr) constant {
+	switch e := e.(type) {
+	case *literal:
+		switch e.tt {
+		case js.NumericToken:
+			if f, ok := parseNumber(e.data); ok {
+				return constant{kind: constNumber, f: f}
+			}
+		case js.StringToken:
+			if 2 <= len(e.data) && !strings.ContainsRune(string(e.data), '\\') {
+				return constant{kind: constString, s: string(e.data[1 : len(e.data)-1])}
+			}
+		case js.IdentifierToken:
+			switch string(e.data) {
+			case "true":
+				return constant{kind: constBool, b: true}
+			case "false":
+				return constant{kind: constBool, b: false}
+			case "null":
+				return constant{kind: constNull}
+			}
+		}
+	case *ident:
+		if e.v == nil && string(e.name) == "undefined" {
+			return constant{kind: constUndefined}
+		}
+	case *unaryExpr:
+		x := constantOf(e.x)
+		switch string(e.op) {
+		case "!":
+			if truthy, ok := x.truthy(); ok {
+				return constant{kind: constBool, b: !truthy}
+			}
+		case "-", "+", "~":
+			if x.kind != constNumber {
+				break
+			} else if e.op[0] == '-' {
+				return constant{kind: constNumber, f: -x.f}
+			} else if e.op[0] == '~' {
+				return constant{kind: constNumber, f: float64(^toInt32(x.f))}
+			}
+			return x
+		case "void":
+			if x.kind != constNone {
+				return constant{kind: constUndefined}
+			}
+		}
+	case *binaryExpr:
+		x := constantOf(e.x)
+		if x.kind == constNone {
+			break
+		}
+		switch op := string(e.op); op {
+		case "&&", "||", "??":
+			if keepLeft(op, x) {
+				return x
+			}
+			return constantOf(e.y)
+		default:
+			if y := constantOf(e.y); y.kind != constNone {
+				return foldBinary(op, x, y)
+			}
+		}
+	}
+	return constant{}
+}
+
+func (c constant) truthy() (bool, bool) {
+	switch c.kind {
+	case constUndefined, constNull:
+		return false, true
+	case constBool:
+		return c.b, true
+	case constNumber:
+		return c.f != 0 && !math.IsNaN(c.f), true
+	case constString:
+		return c.s != "", true
+	}
+	return false, false
+}
+
+// toString converts the constant to a string as JS would, only integers are supported for numbers.
+func (c constant) toString() (string, bool) {
+	switch c.kind {
+	case constUndefined:
+		return "undefined", true
+	case constNull:
+		return "null", true
+	case constBool:
+		return strconv.FormatBool(c.b), true
+	case constNumber:
+		if c.f == 0 {
+			return "0", true // also for -0
+		} else if c.f == math.Trunc(c.f) && math.Abs(c.f) < 1e21 {
+			return strconv.FormatFloat(c.f, 'f', -1, 64), true
+		}
+	case constString:
+		return c.s, true
+	}
+	return "", false
+}
+
+// expr returns the shortest expression for the constant, or nil if it cannot be written as a literal.
+func (c constant) expr() expr {
+	switch c.kind {
+	case constUndefined:
+		return &unaryExpr{[]byte("void"), &literal{js.NumericToken, []byte("0"), 0}}
+	case constNull:
+		return &literal{js.IdentifierToken, []byte("null"), 0}
+	case constBool:
+		if c.b {
+			return &unaryExpr{[]byte("!"), &literal{js.NumericToken, []byte("0"), 0}}
+		}
+		return &unaryExpr{[]byte("!"), &literal{js.NumericToken, []byte("1"), 0}}
+	case constNumber:
+		if math.IsNaN(c.f) || math.IsInf(c.f, 0) {
+			return nil
+		}
+		var num []byte
+		if c.f == math.Trunc(c.f) && math.Abs(c.f) < 1e21 {
+			num = strconv.AppendFloat(nil, math.Abs(c.f), 'f', -1, 64)
+		} else {
+			num = strconv.AppendFloat(nil, math.Abs(c.f), 'g', -1, 64)
+		}
+		if math.Signbit(c.f) {
+			return &unaryExpr{[]byte("-"), &literal{js.NumericToken, num, 0}}
+		}
+		return &literal{js.NumericToken, num, 0}
+	case constString:
+		quote := "\""
+		if strings.Contains(c.s, quote) {
+			quote = "'"
+			if strings.Contains(c.s, quote) {
+				return nil
+			}
+		}
+		return &literal{js.StringToken, []byte(quote + c.s + quote), 0}
+	}
+	return nil
+}
+
+// exprLen returns the length of a constant expression when written out.
+func exprLen(e expr) int {
+	switch e := e.(type) {
+	case *literal:
+		return len(e.data)
+	case *ident:
+		return len(e.name)
+	case *unaryExpr:
+		if string(e.op) == "void" {
+			return len(e.op) + 1 + exprLen(e.x)
+		}
+		return len(e.op) + exprLen(e.x)
+	case *binaryExpr:
+		return exprLen(e.x) + len(e.op) + exprLen(e.y)
+	}
+	return math.MaxInt32
+}
+
+func foldBinary(op string, x, y constant) constant {
+	switch op {
+	case "===", "!==":
+		if equal, ok := strictEqual(x, y); ok {
+			return constant{kind: constBool, b: equal == (op == "===")}
+		}
+	case "==", "!=":
+		if x.kind == y.kind {
+			if equal, ok := strictEqual(x, y); ok {
+				return constant{kind: constBool, b: equal == (op == "==")}
+			}
+		} else if (x.kind == constUndefined || x.kind == constNull) && (y.kind == constUndefined || y.kind == constNull) {
+			return constant{kind: constBool, b: op == "=="}
+		}
+	case "<", ">", "<=", ">=":
+		var less, equal bool
+		if x.kind == constNumber && y.kind == constNumber {
+			if math.IsNaN(x.f) || math.IsNaN(y.f) {
+				return constant{kind: constBool, b: false}
+			}
+			less, equal = x.f < y.f, x.f == y.f
+		} else if x.kind == constString && y.kind == constString && isASCII(x.s) && isASCII(y.s) {
+			less, equal = x.s < y.s, x.s == y.s
+		} else {
+			break
+		}
+		switch op {
+		case "<":
+			return constant{kind: constBool, b: less}
+		case ">":
+			return constant{kind: constBool, b: !less && !equal}
+		case "<=":
+			return constant{kind: constBool, b: less || equal}
+		}
+		return constant{kind: constBool, b: !less}
+	case "+":
+		if x.kind == constString || y.kind == constString {
+			xs, okX := x.toString()
+			ys, okY := y.toString()
+			if okX && okY {
+				return constant{kind: constString, s: xs + ys}
+			}
+			break
+		}
+		fallthrough
+	default:
+		if x.kind != constNumber || y.kind != constNumber {
+			break
+		}
+		switch op {
+		case "+":
+			return constant{kind: constNumber, f: x.f + y.f}
+		case "-":
+			return constant{kind: constNumber, f: x.f - y.f}
+		case "*":
+			return constant{kind: constNumber, f: x.f * y.f}
+		case "/":
+			return constant{kind: constNumber, f: x.f / y.f}
+		case "%":
+			return constant{kind: constNumber, f: math.Mod(x.f, y.f)}
+		case "**":
+			return constant{kind: constNumber, f: math.Pow(x.f, y.f)}
+		case "|":
+			return constant{kind: constNumber, f: float64(toInt32(x.f) | toInt32(y.f))}
+		case "&":
+			return constant{kind: constNumber, f: float64(toInt32(x.f) & toInt32(y.f))}
+		case "^":
+			return constant{kind: constNumber, f: float64(toInt32(x.f) ^ toInt32(y.f))}
+		case "<<":
+			return constant{kind: constNumber, f: float64(toInt32(x.f) << (uint32(toInt32(y.f)) & 31))}
+		case ">>":
+			return constant{kind: constNumber, f: float64(toInt32(x.f) >> (uint32(toInt32(y.f)) & 31))}
+		case ">>>":
+			return constant{kind: constNumber, f: float64(uint32(toInt32(x.f)) >> (uint32(toInt32(y.f)) & 31))}
+		}
+	}
+	return constant{}
+}
+
+func strictEqual(x, y constant) (bool, bool) {
+	if x.kind != y.kind {
+		return false, true
+	}
+	switch x.kind {
+	case constUndefined, constNull:
+		return true, true
+	case constBool:
+		return x.b == y.b, true
+	case constNumber:
+		return x.f == y.f, true
+	case constString:
+		return x.s == y.s, true
+	}
+	return false, false
+}
+
+func toInt32(f float64) int32 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0
+	}
+	return int32(uint32(int64(math.Mod(math.Trunc(f), 4294967296))))
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if 0x80 <= s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseNumber returns the value of a numeric literal, BigInts and legacy octal literals are not supported.
+func parseNumber(b []byte) (float64, bool) {
+	s := strings.Replace(string(b), "_", "", -1)
+	if s == "" || s[len(s)-1] == 'n' {
+		return 0, false
+	} else if 1 < len(s) && s[0] == '0' {
+		base := 0
+		switch s[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		case '.', 'e', 'E':
+		default:
+			return 0, false
+		}
+		if base != 0 {
+			n, err := strconv.ParseUint(s[2:], base, 64)
+			return float64(n), err == nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
@@ -0,0 +1,1270 @@
+package js // import "github.com/tdewolff/minify/js"
+
+import (
+	"bytes"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/buffer"
+	"github.com/tdewolff/parse/v2/js"
+)
+
+type token struct {
+	tt   js.TokenType
+	data []byte
+	nl   bool // preceded by a line terminator
+	pos  int
+}
+
+// tokenize lexes the entire input and keeps the hashbang and the comments that are kept by the policy.
+func tokenize(src []byte, policy *minify.Comments) ([]token, []token, error) {
+	tokens := []token{}
+	comments := []token{}
+
+	l := newLexer(src)
+	pos := 0
+	nl := false
+	for {
+		tt, data := l.Next()
+		start := pos
+		pos += len(data)
+		switch tt {
+		case js.ErrorToken:
+			tokens = append(tokens, token{js.ErrorToken, nil, true, start})
+			return tokens, comments, nil
+		case js.UnknownToken:
+			return nil, nil, parse.NewError("unexpected "+string(data), buffer.NewReader(src), start)
+		case js.WhitespaceToken:
+			continue
+		case js.LineTerminatorToken:
+			nl = true
+			continue
+		case js.SingleLineCommentToken, js.MultiLineCommentToken:
+			if start == 0 && data[0] == '#' || keepComment(policy, data) {
+				comments = append(comments, token{tt, data, false, start})
+			}
+			if tt == js.MultiLineCommentToken {
+				nl = true
+			}
+			continue
+		}
+		tokens = append(tokens, token{tt, data, nl, start})
+		nl = false
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+type parser struct {
+	src      []byte
+	tokens   []token
+	comments []token
+	i        int
+	tok      token
+
+	scope     *scope
+	noIn      bool
+	async     bool
+	generator bool
+	module    bool
+}
+
+type parseError struct {
+	msg string
+	pos int
+}
+
+// parseProgram parses the JavaScript source into a list of statements and the global scope. Comments are kept by the policy, or only bang comments when it is nil.
+func parseProgram(src []byte, module bool, policy *minify.Comments) ([]stmt, *scope, error) {
+	tokens, comments, err := tokenize(src, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := &parser{
+		src:      src,
+		tokens:   tokens,
+		comments: comments,
+		module:   module,
+	}
+	p.scope = newScope(nil, true)
+	p.tok = p.tokens[0]
+
+	list, err := p.parseTop()
+	if err != nil {
+		return nil, nil, err
+	}
+	p.scope.resolve()
+	return list, p.scope, nil
+}
+
+// parseExpression parses a program that consists of a single expression, such as the value of a define.
+func parseExpression(src []byte) (expr, *scope, error) {
+	list, global, err := parseProgram(src, false, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(list) == 1 {
+		if x, ok := list[0].(*exprStmt); ok {
+			return x.x, global, nil
+		}
+	}
+	return nil, nil, parse.NewError("expected a single expression", buffer.NewReader(src), 0)
+}
+
+func (p *parser) parseTop() (list []stmt, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+			err = parse.NewError(perr.msg, buffer.NewReader(p.src), perr.pos)
+		}
+	}()
+	list = p.parseStmtList(false)
+	if p.tok.tt != js.ErrorToken {
+		p.fail("unexpected token")
+	}
+	return list, nil
+}
+
+func (p *parser) fail(msg string) {
+	if p.tok.tt == js.ErrorToken {
+		msg += " before end of file"
+	} else {
+		msg += " at " + string(p.tok.data)
+	}
+	panic(parseError{msg, p.tok.pos})
+}
+
+func (p *parser) next() {
+	if p.i+1 < len(p.tokens) {
+		p.i++
+	}
+	p.tok = p.tokens[p.i]
+}
+
+func (p *parser) peek(n int) token {
+	if p.i+n < len(p.tokens) {
+		return p.tokens[p.i+n]
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+func (p *parser) is(s string) bool {
+	return (p.tok.tt == js.PunctuatorToken || p.tok.tt == js.IdentifierToken) && string(p.tok.data) == s
+}
+
+func (p *parser) isPunct(s string) bool {
+	return p.tok.tt == js.PunctuatorToken && string(p.tok.data) == s
+}
+
+func (p *parser) consume(s string) bool {
+	if p.is(s) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) {
+	if !p.consume(s) {
+		p.fail("expected " + s)
+	}
+}
+
+func (p *parser) consumeSemicolon() {
+	if !p.consume(";") && !p.isPunct("}") && !p.tok.nl && p.tok.tt != js.ErrorToken {
+		p.fail("expected ;")
+	}
+}
+
+func (p *parser) pushScope(fn bool) *scope {
+	p.scope = newScope(p.scope, fn)
+	return p.scope
+}
+
+func (p *parser) popScope() {
+	p.scope = p.scope.parent
+}
+
+// flushComments adds all kept comments before the given position to the list.
+func (p *parser) flushComments(list []stmt, pos int) []stmt {
+	for len(p.comments) > 0 && p.comments[0].pos < pos {
+		list = append(list, &commentStmt{p.comments[0].data})
+		p.comments = p.comments[1:]
+	}
+	return list
+}
+
+////////////////////////////////////////////////////////////////
+
+// parseStmtList parses statements until the end of the file, a closing brace or, for switch cases, the next case clause.
+func (p *parser) parseStmtList(inCase bool) []stmt {
+	list := []stmt{}
+	for {
+		list = p.flushComments(list, p.tok.pos)
+		if p.tok.tt == js.ErrorToken || p.isPunct("}") || inCase && (p.is("case") || p.is("default")) {
+			return list
+		}
+		list = append(list, p.parseStmt())
+	}
+}
+
+func (p *parser) parseBlock() *blockStmt {
+	p.expect("{")
+	block := &blockStmt{scope: p.pushScope(false)}
+	block.list = p.parseStmtList(false)
+	p.popScope()
+	p.expect("}")
+	return block
+}
+
+// parseFuncBody parses the statements of a function body with the scope already set.
+func (p *parser) parseFuncBody() []stmt {
+	p.expect("{")
+	list := p.parseStmtList(false)
+	p.expect("}")
+	return list
+}
+
+func (p *parser) parseStmt() stmt {
+	if p.tok.tt == js.IdentifierToken {
+		switch string(p.tok.data) {
+		case "var":
+			s := p.parseVarDecl(js.Var)
+			p.consumeSemicolon()
+			return s
+		case "let":
+			if next := p.peek(1); next.tt == js.IdentifierToken && string(next.data) != "in" && string(next.data) != "instanceof" || next.tt == js.PunctuatorToken && (next.data[0] == '[' || next.data[0] == '{') {
+				s := p.parseVarDecl(js.Let)
+				p.consumeSemicolon()
+				return s
+			}
+		case "const":
+			s := p.parseVarDecl(js.Const)
+			p.consumeSemicolon()
+			return s
+		case "function":
+			return &funcDecl{p.parseFunc(false, true)}
+		case "async":
+			if next := p.peek(1); !next.nl && next.tt == js.IdentifierToken && string(next.data) == "function" {
+				p.next()
+				return &funcDecl{p.parseFunc(true, true)}
+			}
+		case "class":
+			return &classDecl{p.parseClass(true)}
+		case "if":
+			p.next()
+			s := &ifStmt{}
+			s.cond = p.parseParenExpr()
+			s.body = p.parseStmt()
+			if p.consume("else") {
+				s.els = p.parseStmt()
+			}
+			return s
+		case "for":
+			return p.parseFor()
+		case "while":
+			p.next()
+			s := &whileStmt{}
+			s.cond = p.parseParenExpr()
+			s.body = p.parseStmt()
+			return s
+		case "do":
+			p.next()
+			s := &doWhileStmt{}
+			s.body = p.parseStmt()
+			p.expect("while")
+			s.cond = p.parseParenExpr()
+			p.consume(";") // semicolon is always inserted after do-while
+			return s
+		case "continue", "break":
+			s := &branchStmt{tok: js.Break}
+			if p.tok.data[0] == 'c' {
+				s.tok = js.Continue
+			}
+			p.next()
+			if p.tok.tt == js.IdentifierToken && !p.tok.nl {
+				s.label = p.tok.data
+				p.next()
+			}
+			p.consumeSemicolon()
+			return s
+		case "return":
+			p.next()
+			s := &returnStmt{}
+			if !p.tok.nl && !p.isPunct(";") && !p.isPunct("}") && p.tok.tt != js.ErrorToken {
+				s.x = p.parseExpr()
+			}
+			p.consumeSemicolon()
+			return s
+		case "throw":
+			p.next()
+			s := &throwStmt{p.parseExpr()}
+			p.consumeSemicolon()
+			return s
+		case "try":
+			return p.parseTry()
+		case "switch":
+			p.next()
+			s := &switchStmt{}
+			s.x = p.parseParenExpr()
+			p.expect("{")
+			s.scope = p.pushScope(false)
+			for !p.consume("}") {
+				c := caseClause{}
+				if p.consume("case") {
+					c.test = p.parseExpr()
+				} else if !p.consume("default") {
+					p.fail("expected case or default")
+				}
+				p.expect(":")
+				c.list = p.parseStmtList(true)
+				s.cases = append(s.cases, c)
+			}
+			p.popScope()
+			return s
+		case "with":
+			p.next()
+			s := &withStmt{}
+			s.x = p.parseParenExpr()
+			p.scope.markUnsafe()
+			s.body = p.parseStmt()
+			return s
+		case "debugger":
+			p.next()
+			p.consumeSemicolon()
+			return &debuggerStmt{}
+		case "import":
+			if next := p.peek(1); next.tt != js.PunctuatorToken || next.data[0] != '(' && next.data[0] != '.' {
+				return p.parseImport()
+			}
+		case "export":
+			return p.parseExport()
+		default:
+			if next := p.peek(1); next.tt == js.PunctuatorToken && string(next.data) == ":" && !reservedNames[string(p.tok.data)] {
+				s := &labeledStmt{label: p.tok.data}
+				p.next()
+				p.next()
+				s.body = p.parseStmt()
+				return s
+			}
+		}
+	} else if p.isPunct("{") {
+		return p.parseBlock()
+	} else if p.isPunct(";") {
+		p.next()
+		return &emptyStmt{}
+	}
+
+	s := &exprStmt{p.parseExpr()}
+	p.consumeSemicolon()
+	return s
+}
+
+func (p *parser) parseVarDecl(tok js.Hash) *varDecl {
+	p.next()
+	s := &varDecl{tok: tok}
+	for {
+		b := binding{}
+		b.target = p.parseBindingTarget(tok)
+		if p.consume("=") {
+			b.init = p.parseAssign()
+		}
+		s.list = append(s.list, b)
+		if !p.consume(",") {
+			break
+		}
+	}
+	return s
+}
+
+func (p *parser) declare(id *ident, tok js.Hash) {
+	if tok == js.Var || tok == js.Function {
+		s := p.scope.funcScope()
+		if s.params != nil && s.params.names[string(id.name)] != nil {
+			s = s.params // redeclared parameters start with the value of the parameter
+		}
+		s.declare(id)
+	} else {
+		p.scope.declare(id)
+	}
+}
+
+func (p *parser) parseBindingIdent(tok js.Hash) *ident {
+	if p.tok.tt != js.IdentifierToken {
+		p.fail("expected identifier")
+	}
+	id := &ident{name: p.tok.data, pos: p.tok.pos}
+	p.declare(id, tok)
+	p.next()
+	return id
+}
+
+// parseBindingTarget parses an identifier or destructuring pattern that declares variables.
+func (p *parser) parseBindingTarget(tok js.Hash) expr {
+	if p.isPunct("[") {
+		p.next()
+		arr := &arrayExpr{}
+		for !p.consume("]") {
+			if p.isPunct(",") {
+				p.next()
+				arr.list = append(arr.list, nil)
+				continue
+			}
+			arr.list = append(arr.list, p.parseBindingElement(tok))
+			if !p.isPunct("]") {
+				p.expect(",")
+			}
+		}
+		return arr
+	} else if p.isPunct("{") {
+		p.next()
+		obj := &objectExpr{}
+		for !p.consume("}") {
+			prop := property{}
+			if p.consume("...") {
+				prop.kind = propSpread
+				prop.value = p.parseBindingIdent(tok)
+			} else {
+				if p.tok.tt == js.IdentifierToken && (p.peek(1).tt != js.PunctuatorToken || p.peek(1).data[0] != ':') {
+					prop.shorthand = true
+					prop.key = &literal{js.IdentifierToken, p.tok.data, p.tok.pos}
+					prop.value = p.parseBindingIdent(tok)
+				} else {
+					prop.key, prop.computed = p.parsePropertyKey()
+					p.expect(":")
+					prop.value = p.parseBindingTarget(tok)
+				}
+				if p.consume("=") {
+					prop.value = &assignExpr{[]byte("="), prop.value, p.parseAssign()}
+				}
+			}
+			obj.list = append(obj.list, prop)
+			if !p.isPunct("}") {
+				p.expect(",")
+			}
+		}
+		return obj
+	}
+	return p.parseBindingIdent(tok)
+}
+
+func (p *parser) parseBindingElement(tok js.Hash) expr {
+	if p.consume("...") {
+		return &spreadExpr{p.parseBindingTarget(tok)}
+	}
+	target := p.parseBindingTarget(tok)
+	if p.consume("=") {
+		return &assignExpr{[]byte("="), target, p.parseAssign()}
+	}
+	return target
+}
+
+func (p *parser) parseFor() stmt {
+	p.next()
+	await := p.consume("await")
+	p.expect("(")
+	scope := p.pushScope(false)
+
+	var init stmt
+	noIn := p.noIn
+	p.noIn = true
+	if p.isPunct(";") {
+		// no init
+	} else if p.is("var") || p.is("const") || p.is("let") && (p.peek(1).tt == js.IdentifierToken && string(p.peek(1).data) != "in" && string(p.peek(1).data) != "of" || p.peek(1).tt == js.PunctuatorToken && (p.peek(1).data[0] == '[' || p.peek(1).data[0] == '{')) {
+		tok := js.Var
+		if p.is("let") {
+			tok = js.Let
+		} else if p.is("const") {
+			tok = js.Const
+		}
+		init = p.parseVarDecl(tok)
+	} else {
+		init = &exprStmt{p.parseExpr()}
+	}
+	p.noIn = noIn
+
+	if p.is("of") || p.is("in") {
+		s := &forInStmt{init: init, of: p.is("of"), await: await, scope: scope}
+		p.next()
+		if s.of {
+			s.value = p.parseAssign()
+		} else {
+			s.value = p.parseExpr()
+		}
+		p.expect(")")
+		s.body = p.parseStmt()
+		p.popScope()
+		return s
+	}
+
+	s := &forStmt{init: init, scope: scope}
+	p.expect(";")
+	if !p.isPunct(";") {
+		s.cond = p.parseExpr()
+	}
+	p.expect(";")
+	if !p.isPunct(")") {
+		s.post = p.parseExpr()
+	}
+	p.expect(")")
+	s.body = p.parseStmt()
+	p.popScope()
+	return s
+}
+
+func (p *parser) parseTry() stmt {
+	p.next()
+	s := &tryStmt{}
+	s.body = p.parseBlock()
+	if p.consume("catch") {
+		s.scope = p.pushScope(false)
+		if p.consume("(") {
+			s.param = p.parseBindingTarget(js.Let)
+			p.expect(")")
+		}
+		s.catch = p.parseBlock()
+		p.popScope()
+	}
+	if p.consume("finally") {
+		s.finally = p.parseBlock()
+	}
+	if s.catch == nil && s.finally == nil {
+		p.fail("expected catch or finally")
+	}
+	return s
+}
+
+func (p *parser) parseModuleSpecifier() []byte {
+	if p.tok.tt != js.StringToken {
+		p.fail("expected module specifier")
+	}
+	module := p.tok.data
+	p.next()
+	return module
+}
+
+func (p *parser) parseImport() stmt {
+	p.next()
+	s := &importStmt{}
+	if p.tok.tt != js.StringToken {
+		if p.tok.tt == js.IdentifierToken {
+			s.def = p.parseBindingIdent(js.Let)
+			p.consume(",")
+		}
+		if p.consume("*") {
+			p.expect("as")
+			s.namespace = p.parseBindingIdent(js.Let)
+		} else if p.consume("{") {
+			s.braces = true
+			for !p.consume("}") {
+				spec := importSpec{name: p.tok.data}
+				if p.tok.tt != js.IdentifierToken && p.tok.tt != js.StringToken {
+					p.fail("expected import specifier")
+				}
+				if p.peek(1).tt == js.IdentifierToken && string(p.peek(1).data) == "as" {
+					p.next()
+					p.next()
+				}
+				spec.local = p.parseBindingIdent(js.Let)
+				s.specs = append(s.specs, spec)
+				if !p.isPunct("}") {
+					p.expect(",")
+				}
+			}
+		}
+		p.expect("from")
+	}
+	s.module = p.parseModuleSpecifier()
+	p.consumeSemicolon()
+	return s
+}
+
+func (p *parser) parseExport() stmt {
+	p.next()
+	s := &exportStmt{}
+	if p.consume("*") {
+		s.star = true
+		if p.consume("as") {
+			s.namespace = p.tok.data
+			p.next()
+		}
+		p.expect("from")
+		s.module = p.parseModuleSpecifier()
+		p.consumeSemicolon()
+	} else if p.consume("{") {
+		s.braces = true
+		for !p.consume("}") {
+			if p.tok.tt != js.IdentifierToken && p.tok.tt != js.StringToken {
+				p.fail("expected export specifier")
+			}
+			spec := exportSpec{}
+			id := &ident{name: p.tok.data, pos: p.tok.pos}
+			spec.local = id
+			spec.name = p.tok.data
+			p.next()
+			if p.consume("as") {
+				spec.name = p.tok.data
+				p.next()
+			}
+			s.specs = append(s.specs, spec)
+			if !p.isPunct("}") {
+				p.expect(",")
+			}
+		}
+		if p.consume("from") {
+			s.module = p.parseModuleSpecifier()
+		} else {
+			for _, spec := range s.specs {
+				p.scope.reference(spec.local.(*ident))
+			}
+		}
+		p.consumeSemicolon()
+	} else if p.consume("default") {
+		s.def = true
+		if p.is("function") {
+			s.decl = &funcDecl{p.parseFunc(false, true)}
+		} else if p.is("async") && !p.peek(1).nl && p.peek(1).tt == js.IdentifierToken && string(p.peek(1).data) == "function" {
+			p.next()
+			s.decl = &funcDecl{p.parseFunc(true, true)}
+		} else if p.is("class") {
+			s.decl = &classDecl{p.parseClass(true)}
+		} else {
+			s.decl = &exprStmt{p.parseAssign()}
+			p.consumeSemicolon()
+		}
+	} else {
+		s.decl = p.parseStmt()
+	}
+	return s
+}
+
+////////////////////////////////////////////////////////////////
+
+// parseFunc parses a function declaration or expression at the function keyword.
+// Declarations bind their name in the enclosing function scope, expressions bind their name in a scope of their own.
+func (p *parser) parseFunc(async, decl bool) *funcNode {
+	p.expect("function")
+	f := &funcNode{async: async}
+	f.generator = p.consume("*")
+
+	parent := p.scope
+	if p.tok.tt == js.IdentifierToken && !p.isPunct("(") {
+		if decl {
+			f.name = p.parseBindingIdent(js.Function)
+		} else {
+			p.pushScope(false)
+			f.name = p.parseBindingIdent(js.Let)
+		}
+	}
+	p.parseFuncRest(f)
+	p.scope = parent
+	return f
+}
+
+// parseFuncRest parses the parameters and body of a function or method.
+func (p *parser) parseFuncRest(f *funcNode) {
+	f.scope = p.pushScope(true)
+	async, generator, noIn := p.async, p.generator, p.noIn
+	p.async, p.generator, p.noIn = f.async, f.generator, false
+
+	p.expect("(")
+	for !p.consume(")") {
+		f.params = append(f.params, p.parseBindingElement(js.Var))
+		if !p.isPunct(")") {
+			p.expect(",")
+		}
+	}
+	p.pushBodyScope(f)
+	f.body = p.parseFuncBody()
+
+	p.async, p.generator, p.noIn = async, generator, noIn
+	p.scope = f.scope.parent
+}
+
+// pushBodyScope adds a scope for the body of a function whose parameters have expressions, since these cannot see the var declarations
+// of the body, such as y in function(x=y){var y}.
+func (p *parser) pushBodyScope(f *funcNode) {
+	if hasParamExprs(f.params) {
+		body := p.pushScope(true)
+		body.arrow = f.arrow
+		body.params = f.scope
+	}
+}
+
+// hasParamExprs returns true if the parameters have default values or computed keys.
+func hasParamExprs(params []expr) bool {
+	for _, param := range params {
+		switch param := param.(type) {
+		case *assignExpr:
+			return true
+		case *spreadExpr:
+			if hasParamExprs([]expr{param.x}) {
+				return true
+			}
+		case *arrayExpr:
+			if hasParamExprs(param.list) {
+				return true
+			}
+		case *objectExpr:
+			for _, prop := range param.list {
+				if prop.computed || hasParamExprs([]expr{prop.value}) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// parseArrow parses an arrow function at the parameters, which is either an identifier or a parenthesized list.
+func (p *parser) parseArrow(async bool) expr {
+	f := &funcNode{async: async, arrow: true}
+	f.scope = p.pushScope(true)
+	f.scope.arrow = true
+	prevAsync, generator := p.async, p.generator
+	p.async, p.generator = async, false
+
+	if p.tok.tt == js.IdentifierToken {
+		f.params = append(f.params, p.parseBindingIdent(js.Var))
+	} else {
+		noIn := p.noIn
+		p.noIn = false
+		p.expect("(")
+		for !p.consume(")") {
+			f.params = append(f.params, p.parseBindingElement(js.Var))
+			if !p.isPunct(")") {
+				p.expect(",")
+			}
+		}
+		p.noIn = noIn
+	}
+	if p.tok.nl {
+		p.fail("unexpected line terminator")
+	}
+	p.expect("=>")
+	if p.isPunct("{") {
+		noIn := p.noIn
+		p.noIn = false
+		p.pushBodyScope(f)
+		f.body = p.parseFuncBody()
+		p.noIn = noIn
+	} else {
+		f.exprBody = p.parseAssign()
+	}
+
+	p.async, p.generator = prevAsync, generator
+	p.scope = f.scope.parent
+	return &funcExpr{f}
+}
+
+func (p *parser) parseClass(decl bool) *classNode {
+	p.expect("class")
+	c := &classNode{}
+	parent := p.scope
+	if p.tok.tt == js.IdentifierToken && !p.is("extends") && !p.isPunct("{") {
+		if decl {
+			c.name = p.parseBindingIdent(js.Let)
+		} else {
+			p.pushScope(false)
+			c.name = p.parseBindingIdent(js.Let)
+		}
+	}
+	if p.consume("extends") {
+		c.extends = p.parseLHS()
+	}
+
+	c.scope = p.pushScope(false)
+	p.expect("{")
+	for !p.consume("}") {
+		if p.consume(";") {
+			continue
+		}
+		c.list = append(c.list, p.parseProperty(true))
+	}
+	p.scope = parent
+	return c
+}
+
+// parsePropertyKey parses the name of a property, method or class member.
+func (p *parser) parsePropertyKey() (expr, bool) {
+	if p.consume("[") {
+		noIn := p.noIn
+		p.noIn = false
+		key := p.parseAssign()
+		p.noIn = noIn
+		p.expect("]")
+		return key, true
+	} else if p.tok.tt == js.IdentifierToken || p.tok.tt == js.StringToken || p.tok.tt == js.NumericToken {
+		key := &literal{p.tok.tt, p.tok.data, p.tok.pos}
+		p.next()
+		return key, false
+	}
+	p.fail("expected property name")
+	return nil, false
+}
+
+// isPropertyName returns true if the token at n starts a property name, used to distinguish modifiers like get or static from names.
+func (p *parser) isPropertyName(n int) bool {
+	t := p.peek(n)
+	return t.tt == js.IdentifierToken || t.tt == js.StringToken || t.tt == js.NumericToken || t.tt == js.PunctuatorToken && (t.data[0] == '[' || string(t.data) == "*")
+}
+
+// parseProperty parses a property of an object literal or a member of a class body.
+func (p *parser) parseProperty(class bool) property {
+	prop := property{}
+	if !class && p.consume("...") {
+		prop.kind = propSpread
+		prop.value = p.parseAssign()
+		return prop
+	}
+	if class && p.is("static") && p.isPropertyName(1) {
+		p.next()
+		prop.static = true
+	}
+
+	async, generator := false, false
+	if p.is("async") && p.isPropertyName(1) && !p.peek(1).nl {
+		p.next()
+		async = true
+	}
+	if p.consume("*") {
+		generator = true
+	}
+	if !async && !generator && (p.is("get") || p.is("set")) && p.isPropertyName(1) && string(p.peek(1).data) != "*" {
+		prop.kind = propGet
+		if p.tok.data[0] == 's' {
+			prop.kind = propSet
+		}
+		p.next()
+	}
+
+	prop.key, prop.computed = p.parsePropertyKey()
+	if p.isPunct("(") {
+		if prop.kind == propInit {
+			prop.kind = propMethod
+		}
+		f := &funcNode{async: async, generator: generator}
+		p.parseFuncRest(f)
+		prop.value = &funcExpr{f}
+	} else if async || generator || prop.kind != propInit {
+		p.fail("expected (")
+	} else if class {
+		prop.kind = propField
+		if p.consume("=") {
+			prop.value = p.parseAssign()
+		}
+		p.consumeSemicolon()
+	} else if p.consume(":") {
+		prop.value = p.parseAssign()
+	} else if key, ok := prop.key.(*literal); ok && key.tt == js.IdentifierToken && !prop.computed {
+		// shorthand property, possibly with a default value when used as a destructuring pattern
+		prop.shorthand = true
+		id := &ident{name: key.data, pos: key.pos}
+		p.scope.reference(id)
+		prop.value = id
+		if p.consume("=") {
+			prop.value = &assignExpr{[]byte("="), id, p.parseAssign()}
+		}
+	} else {
+		p.fail("expected :")
+	}
+	return prop
+}
+
+////////////////////////////////////////////////////////////////
+
+func (p *parser) parseParenExpr() expr {
+	p.expect("(")
+	noIn := p.noIn
+	p.noIn = false
+	x := p.parseExpr()
+	p.noIn = noIn
+	p.expect(")")
+	return x
+}
+
+func (p *parser) parseExpr() expr {
+	x := p.parseAssign()
+	if p.isPunct(",") {
+		seq := &seqExpr{[]expr{x}}
+		for p.consume(",") {
+			seq.list = append(seq.list, p.parseAssign())
+		}
+		return seq
+	}
+	return x
+}
+
+// isArrow returns true if the parenthesis at n is the start of arrow function parameters.
+func (p *parser) isArrow(n int) bool {
+	level := 0
+	for i := p.i + n; i < len(p.tokens); i++ {
+		t := p.tokens[i]
+		if t.tt == js.ErrorToken {
+			return false
+		} else if t.tt == js.PunctuatorToken {
+			if t.data[0] == '(' {
+				level++
+			} else if t.data[0] == ')' {
+				level--
+				if level == 0 {
+					next := p.tokens[i+1]
+					return next.tt == js.PunctuatorToken && string(next.data) == "=>" && !next.nl
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (p *parser) parseAssign() expr {
+	if p.tok.tt == js.IdentifierToken {
+		next := p.peek(1)
+		if next.tt == js.PunctuatorToken && string(next.data) == "=>" {
+			return p.parseArrow(false)
+		} else if p.is("async") && !next.nl {
+			if next.tt == js.IdentifierToken && string(next.data) != "function" && string(p.peek(2).data) == "=>" {
+				p.next()
+				return p.parseArrow(true)
+			} else if next.tt == js.PunctuatorToken && next.data[0] == '(' && p.isArrow(1) {
+				p.next()
+				return p.parseArrow(true)
+			}
+		} else if p.is("yield") && p.generator {
+			p.next()
+			y := &yieldExpr{}
+			if !p.tok.nl {
+				y.delegate = p.consume("*")
+				if y.delegate || p.startsExpr() {
+					y.x = p.parseAssign()
+				}
+			}
+			return y
+		}
+	} else if p.isPunct("(") && p.isArrow(0) {
+		return p.parseArrow(false)
+	}
+
+	x := p.parseCond()
+	if p.tok.tt == js.PunctuatorToken && assignOps[string(p.tok.data)] {
+		op := p.tok.data
+		p.next()
+		return &assignExpr{op, x, p.parseAssign()}
+	}
+	return x
+}
+
+// startsExpr returns true if the current token can start an expression, used for the optional operand of yield.
+func (p *parser) startsExpr() bool {
+	switch p.tok.tt {
+	case js.ErrorToken:
+		return false
+	case js.PunctuatorToken:
+		switch string(p.tok.data) {
+		case ")", "]", "}", ",", ";", ":", "=>", "?", "=", "==", "===", "!=", "!==", "*", "/", "%", "&&", "||", "??", "&", "|", "^", "<", ">", "<=", ">=", "<<", ">>", ">>>", "**", "in", ".", "?.":
+			return false
+		}
+	case js.IdentifierToken:
+		switch string(p.tok.data) {
+		case "in", "of", "instanceof":
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) parseCond() expr {
+	x := p.parseBinary(precCoalesce)
+	if p.consume("?") {
+		noIn := p.noIn
+		p.noIn = false
+		cond := &condExpr{cond: x}
+		cond.x = p.parseAssign()
+		p.noIn = noIn
+		p.expect(":")
+		cond.y = p.parseAssign()
+		return cond
+	}
+	return x
+}
+
+func (p *parser) binaryOp() (int, bool) {
+	if p.tok.tt == js.PunctuatorToken || p.tok.tt == js.IdentifierToken {
+		if prec, ok := binaryPrec[string(p.tok.data)]; ok {
+			if p.tok.tt == js.IdentifierToken && (p.tok.data[0] != 'i' || p.noIn && string(p.tok.data) == "in") {
+				return 0, false
+			}
+			return prec, true
+		}
+	}
+	return 0, false
+}
+
+func (p *parser) parseBinary(minPrec int) expr {
+	group := p.isPunct("(")
+	x := p.parseUnary()
+	for {
+		prec, ok := p.binaryOp()
+		if !ok || prec < minPrec {
+			return x
+		}
+		op := p.tok.data
+		p.next()
+		var y expr
+		if prec == precExp {
+			if unary, ok := x.(*unaryExpr); ok && !group && string(unary.op) != "++" && string(unary.op) != "--" {
+				p.fail("unexpected ** after unary operator") // such as -2 ** 2, which must be written as (-2) ** 2
+			}
+			y = p.parseBinary(prec) // right-associative
+		} else {
+			y = p.parseBinary(prec + 1)
+		}
+		x = &binaryExpr{op, x, y}
+	}
+}
+
+func (p *parser) parseUnary() expr {
+	if p.tok.tt == js.PunctuatorToken {
+		switch string(p.tok.data) {
+		case "!", "~", "+", "-", "++", "--":
+			op := p.tok.data
+			p.next()
+			return &unaryExpr{op, p.parseUnary()}
+		}
+	} else if p.tok.tt == js.IdentifierToken {
+		switch string(p.tok.data) {
+		case "typeof", "void", "delete":
+			op := p.tok.data
+			p.next()
+			return &unaryExpr{op, p.parseUnary()}
+		case "await":
+			if p.async || p.module && p.scope.funcScope().parent == nil {
+				op := p.tok.data
+				p.next()
+				return &unaryExpr{op, p.parseUnary()}
+			}
+		}
+	}
+
+	x := p.parseLHS()
+	if p.tok.tt == js.PunctuatorToken && !p.tok.nl && (string(p.tok.data) == "++" || string(p.tok.data) == "--") {
+		op := p.tok.data
+		p.next()
+		return &postfixExpr{op, x}
+	}
+	return x
+}
+
+func (p *parser) parseArgs() []expr {
+	p.expect("(")
+	noIn := p.noIn
+	p.noIn = false
+	args := []expr{}
+	for !p.consume(")") {
+		if p.consume("...") {
+			args = append(args, &spreadExpr{p.parseAssign()})
+		} else {
+			args = append(args, p.parseAssign())
+		}
+		if !p.isPunct(")") {
+			p.expect(",")
+		}
+	}
+	p.noIn = noIn
+	return args
+}
+
+func (p *parser) parseTemplate(tag expr) expr {
+	t := &templateExpr{tag: tag}
+	for {
+		if p.tok.tt != js.TemplateToken {
+			p.fail("expected template")
+		}
+		data := p.tok.data
+		t.parts = append(t.parts, data)
+		p.next()
+		if !bytes.HasSuffix(data, []byte("${")) {
+			return t
+		}
+		noIn := p.noIn
+		p.noIn = false
+		t.list = append(t.list, p.parseExpr())
+		p.noIn = noIn
+	}
+}
+
+// parseLHS parses member expressions, calls, optional chains and new expressions.
+func (p *parser) parseLHS() expr {
+	var x expr
+	if p.is("new") {
+		x = p.parseNew()
+	} else {
+		x = p.parsePrimary()
+	}
+	return p.parseSuffixes(x, true)
+}
+
+func (p *parser) parseSuffixes(x expr, calls bool) expr {
+	inChain := false
+	for {
+		if !calls && p.isPunct("?.") {
+			return x
+		} else if p.isPunct(".") || p.isPunct("?.") {
+			optional := p.tok.data[0] == '?'
+			p.next()
+			if optional && p.isPunct("(") {
+				x = &callExpr{x, p.parseArgs(), true, inChain}
+			} else if optional && p.isPunct("[") {
+				p.next()
+				x = &memberExpr{obj: x, index: p.parseBracketExpr(), optional: true, inChain: inChain}
+			} else if p.tok.tt == js.IdentifierToken {
+				x = &memberExpr{obj: x, name: p.tok.data, optional: optional, inChain: inChain, pos: p.tok.pos}
+				p.next()
+			} else {
+				p.fail("expected property name")
+			}
+			inChain = inChain || optional
+		} else if p.isPunct("[") {
+			p.next()
+			x = &memberExpr{obj: x, index: p.parseBracketExpr(), inChain: inChain}
+		} else if p.tok.tt == js.TemplateToken && p.tok.data[0] == '`' {
+			if inChain {
+				p.fail("unexpected tagged template in optional chain")
+			}
+			x = p.parseTemplate(x)
+		} else if calls && p.isPunct("(") {
+			x = &callExpr{x, p.parseArgs(), false, inChain}
+			if id, ok := x.(*callExpr).callee.(*ident); ok && string(id.name) == "eval" {
+				p.scope.markUnsafe() // direct eval has access to all variables in scope
+			}
+		} else {
+			return x
+		}
+	}
+}
+
+func (p *parser) parseBracketExpr() expr {
+	noIn := p.noIn
+	p.noIn = false
+	x := p.parseExpr()
+	p.noIn = noIn
+	p.expect("]")
+	return x
+}
+
+func (p *parser) parseNew() expr {
+	pos := p.tok.pos
+	p.next()
+	if p.consume(".") {
+		if !p.is("target") {
+			p.fail("expected target")
+		}
+		p.next()
+		return &literal{js.IdentifierToken, []byte("new.target"), pos}
+	}
+
+	var callee expr
+	if p.is("new") {
+		callee = p.parseNew()
+	} else {
+		callee = p.parsePrimary()
+	}
+	callee = p.parseSuffixes(callee, false)
+	n := &newExpr{callee: callee}
+	if p.isPunct("(") {
+		n.args = p.parseArgs()
+	}
+	return n
+}
+
+func (p *parser) parsePrimary() expr {
+	t := p.tok
+	switch t.tt {
+	case js.NumericToken, js.StringToken, js.RegexpToken:
+		p.next()
+		return &literal{t.tt, t.data, t.pos}
+	case js.TemplateToken:
+		if t.data[0] == '`' {
+			return p.parseTemplate(nil)
+		}
+	case js.PunctuatorToken:
+		switch t.data[0] {
+		case '(':
+			return p.parseParenExpr()
+		case '[':
+			p.next()
+			noIn := p.noIn
+			p.noIn = false
+			arr := &arrayExpr{}
+			for !p.consume("]") {
+				if p.consume(",") {
+					arr.list = append(arr.list, nil)
+					continue
+				} else if p.consume("...") {
+					arr.list = append(arr.list, &spreadExpr{p.parseAssign()})
+				} else {
+					arr.list = append(arr.list, p.parseAssign())
+				}
+				if !p.isPunct("]") {
+					p.expect(",")
+				}
+			}
+			p.noIn = noIn
+			return arr
+		case '{':
+			p.next()
+			noIn := p.noIn
+			p.noIn = false
+			obj := &objectExpr{}
+			for !p.consume("}") {
+				obj.list = append(obj.list, p.parseProperty(false))
+				if !p.isPunct("}") {
+					p.expect(",")
+				}
+			}
+			p.noIn = noIn
+			return obj
+		}
+	case js.IdentifierToken:
+		switch string(t.data) {
+		case "function":
+			return &funcExpr{p.parseFunc(false, false)}
+		case "async":
+			if next := p.peek(1); !next.nl && next.tt == js.IdentifierToken && string(next.data) == "function" {
+				p.next()
+				return &funcExpr{p.parseFunc(true, false)}
+			}
+		case "class":
+			return &classExpr{p.parseClass(false)}
+		case "this", "super", "null", "true", "false":
+			p.next()
+			return &literal{t.tt, t.data, t.pos}
+		case "import":
+			p.next()
+			if p.consume(".") {
+				if !p.is("meta") {
+					p.fail("expected meta")
+				}
+				p.next()
+				return &literal{js.IdentifierToken, []byte("import.meta"), t.pos}
+			} else if !p.isPunct("(") {
+				p.fail("expected (")
+			}
+			return &literal{js.IdentifierToken, t.data, t.pos}
+		}
+		if t.data[0] == '#' {
+			p.next()
+			return &literal{t.tt, t.data, t.pos} // private name in `#x in obj`
+		}
+		p.next()
+		id := &ident{name: t.data, pos: t.pos}
+		p.scope.reference(id)
+		return id
+	}
+	p.fail("unexpected token")
+	return nil
+}
@@ -0,0 +1,890 @@
+package js // import "github.com/tdewolff/minify/js"
+
+import (
+	"io"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/parse/v2/buffer"
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// printer writes out the AST with the least amount of whitespace, semicolons and parentheses.
+type printer struct {
+	w        *buffer.Writer
+	last     byte
+	needSemi bool // the previous statement must be terminated before the next one
+
+	mapper minify.Mapper // nil when no source map is recorded
+	marks  []mark
+}
+
+// mark is a position in the output that originates from data in the input.
+type mark struct {
+	pos  int
+	data []byte
+}
+
+func newPrinter() *printer {
+	return &printer{
+		w: buffer.NewWriter(make([]byte, 0, 4096)),
+	}
+}
+
+func isIdentChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '$' || c == '\\' || c >= 0x80
+}
+
+// write writes a token and inserts a space when it would otherwise merge with the previous token.
+func (p *printer) write(b []byte) {
+	p.writeMapped(b, nil)
+}
+
+// writeMapped writes a token like write and marks its position for the source map as originating from data.
+func (p *printer) writeMapped(b, data []byte) {
+	if len(b) == 0 {
+		return
+	}
+	c := b[0]
+	if isIdentChar(p.last) && (isIdentChar(c) || c == '.' && 1 < len(b) && '0' <= b[1] && b[1] <= '9') ||
+		(p.last == '+' || p.last == '-') && c == p.last ||
+		p.last == '/' && (c == '/' || c == '*') ||
+		p.last == '<' && c == '!' {
+		p.w.Write(spaceBytes)
+	}
+	if p.mapper != nil && data != nil {
+		p.marks = append(p.marks, mark{p.w.Len(), data})
+	}
+	p.w.Write(b)
+	p.last = b[len(b)-1]
+}
+
+// writeTo writes the output to w and passes the marks to the source map in between.
+func (p *printer) writeTo(w io.Writer) error {
+	b := p.w.Bytes()
+	start := 0
+	for _, m := range p.marks {
+		if start < m.pos {
+			if _, err := w.Write(b[start:m.pos]); err != nil {
+				return err
+			}
+			start = m.pos
+		}
+		p.mapper.Map(m.data)
+	}
+	_, err := w.Write(b[start:])
+	return err
+}
+
+func (p *printer) writeString(s string) {
+	p.write([]byte(s))
+}
+
+func (p *printer) semicolon() {
+	if p.needSemi {
+		p.writeString(";")
+		p.needSemi = false
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+func (p *printer) stmtList(list []stmt) {
+	for _, s := range list {
+		switch s.(type) {
+		case *emptyStmt:
+			continue
+		case *commentStmt:
+		default:
+			p.semicolon()
+		}
+		p.stmt(s)
+	}
+}
+
+func (p *printer) block(list []stmt) {
+	p.writeString("{")
+	p.needSemi = false
+	p.stmtList(list)
+	p.writeString("}")
+	p.needSemi = false
+}
+
+// unwrap returns the single statement of a block, or the block itself when its braces cannot be omitted.
+func unwrap(s stmt) stmt {
+	if block, ok := s.(*blockStmt); ok {
+		list := []stmt{}
+		for _, s := range block.list {
+			if _, ok := s.(*emptyStmt); !ok {
+				list = append(list, s)
+			}
+		}
+		if len(list) == 0 {
+			return &emptyStmt{}
+		} else if len(list) == 1 {
+			switch s := list[0].(type) {
+			case *funcDecl, *classDecl, *commentStmt:
+				return block
+			case *varDecl:
+				if s.tok != js.Var {
+					return block
+				}
+			}
+			return list[0]
+		}
+	}
+	return s
+}
+
+// endsInIf returns true if the statement ends in an if statement without else, which would take the else of an enclosing if statement.
+func endsInIf(s stmt) bool {
+	switch s := unwrap(s).(type) {
+	case *ifStmt:
+		if s.els == nil {
+			return true
+		}
+		return endsInIf(s.els)
+	case *whileStmt:
+		return endsInIf(s.body)
+	case *forStmt:
+		return endsInIf(s.body)
+	case *forInStmt:
+		return endsInIf(s.body)
+	case *labeledStmt:
+		return endsInIf(s.body)
+	case *withStmt:
+		return endsInIf(s.body)
+	}
+	return false
+}
+
+// body writes the body of a compound statement, omitting the braces of blocks when possible.
+func (p *printer) body(s stmt) {
+	s = unwrap(s)
+	if _, ok := s.(*emptyStmt); ok {
+		p.writeString(";")
+		p.needSemi = false
+		return
+	}
+	p.stmt(s)
+}
+
+func (p *printer) stmt(s stmt) {
+	switch s := s.(type) {
+	case *blockStmt:
+		p.block(s.list)
+	case *emptyStmt:
+		p.writeString(";")
+		p.needSemi = false
+	case *exprStmt:
+		if startsAmbiguous(s.x, true) {
+			p.writeString("(")
+			p.expr(s.x, precSeq)
+			p.writeString(")")
+		} else {
+			p.expr(s.x, precSeq)
+		}
+		p.needSemi = true
+	case *varDecl:
+		p.varDecl(s, false)
+		p.needSemi = true
+	case *funcDecl:
+		p.function(s.f)
+		p.needSemi = false
+	case *classDecl:
+		p.class(s.c)
+		p.needSemi = false
+	case *ifStmt:
+		p.writeString("if(")
+		p.expr(s.cond, precSeq)
+		p.writeString(")")
+		if s.els != nil && endsInIf(s.body) {
+			p.block([]stmt{unwrap(s.body)})
+		} else {
+			p.body(s.body)
+		}
+		if s.els != nil {
+			p.semicolon()
+			p.writeString("else")
+			p.body(s.els)
+		}
+	case *doWhileStmt:
+		p.writeString("do")
+		p.body(s.body)
+		p.semicolon()
+		p.writeString("while(")
+		p.expr(s.cond, precSeq)
+		p.writeString(")")
+		p.needSemi = true
+	case *whileStmt:
+		p.writeString("while(")
+		p.expr(s.cond, precSeq)
+		p.writeString(")")
+		p.body(s.body)
+	case *forStmt:
+		p.writeString("for(")
+		if s.init != nil {
+			p.forInit(s.init)
+		}
+		p.writeString(";")
+		if s.cond != nil {
+			p.expr(s.cond, precSeq)
+		}
+		p.writeString(";")
+		if s.post != nil {
+			p.expr(s.post, precSeq)
+		}
+		p.writeString(")")
+		p.body(s.body)
+	case *forInStmt:
+		p.writeString("for")
+		if s.await {
+			p.writeString("await")
+		}
+		p.writeString("(")
+		p.forInit(s.init)
+		if s.of {
+			p.writeString("of")
+			p.expr(s.value, precAssign)
+		} else {
+			p.writeString("in")
+			p.expr(s.value, precSeq)
+		}
+		p.writeString(")")
+		p.body(s.body)
+	case *branchStmt:
+		if s.tok == js.Break {
+			p.writeString("break")
+		} else {
+			p.writeString("continue")
+		}
+		p.write(s.label)
+		p.needSemi = true
+	case *returnStmt:
+		p.writeString("return")
+		if s.x != nil {
+			p.expr(s.x, precSeq)
+		}
+		p.needSemi = true
+	case *throwStmt:
+		p.writeString("throw")
+		p.expr(s.x, precSeq)
+		p.needSemi = true
+	case *tryStmt:
+		p.writeString("try")
+		p.block(s.body.list)
+		if s.catch != nil {
+			p.writeString("catch")
+			if s.param != nil {
+				p.writeString("(")
+				p.expr(s.param, precAssign)
+				p.writeString(")")
+			}
+			p.block(s.catch.list)
+		}
+		if s.finally != nil {
+			p.writeString("finally")
+			p.block(s.finally.list)
+		}
+	case *switchStmt:
+		p.writeString("switch(")
+		p.expr(s.x, precSeq)
+		p.writeString("){")
+		p.needSemi = false
+		for _, c := range s.cases {
+			p.semicolon()
+			if c.test != nil {
+				p.writeString("case")
+				p.expr(c.test, precSeq)
+			} else {
+				p.writeString("default")
+			}
+			p.writeString(":")
+			p.stmtList(c.list)
+		}
+		p.writeString("}")
+		p.needSemi = false
+	case *labeledStmt:
+		p.write(s.label)
+		p.writeString(":")
+		p.body(s.body)
+	case *withStmt:
+		p.writeString("with(")
+		p.expr(s.x, precSeq)
+		p.writeString(")")
+		p.body(s.body)
+	case *debuggerStmt:
+		p.writeString("debugger")
+		p.needSemi = true
+	case *importStmt:
+		p.writeString("import")
+		if s.def != nil {
+			p.ident(s.def)
+			if s.namespace != nil || s.braces {
+				p.writeString(",")
+			}
+		}
+		if s.namespace != nil {
+			p.writeString("*as")
+			p.ident(s.namespace)
+		} else if s.braces {
+			p.writeString("{")
+			for i, spec := range s.specs {
+				if i != 0 {
+					p.writeString(",")
+				}
+				if name := spec.local.v.Name(); string(name) != string(spec.name) {
+					p.write(spec.name)
+					p.writeString("as")
+				}
+				p.ident(spec.local)
+			}
+			p.writeString("}")
+		}
+		if s.def != nil || s.namespace != nil || s.braces {
+			p.writeString("from")
+		}
+		p.write(s.module)
+		p.needSemi = true
+	case *exportStmt:
+		p.writeString("export")
+		if s.def {
+			p.writeString("default")
+			if x, ok := s.decl.(*exprStmt); ok {
+				if startsAmbiguous(x.x, true) {
+					p.writeString("(")
+					p.expr(x.x, precAssign)
+					p.writeString(")")
+				} else {
+					p.expr(x.x, precAssign)
+				}
+				p.needSemi = true
+			} else {
+				p.stmt(s.decl)
+			}
+		} else if s.decl != nil {
+			p.stmt(s.decl)
+		} else {
+			if s.star {
+				p.writeString("*")
+				if s.namespace != nil {
+					p.writeString("as")
+					p.write(s.namespace)
+				}
+			} else {
+				p.writeString("{")
+				for i, spec := range s.specs {
+					if i != 0 {
+						p.writeString(",")
+					}
+					local := spec.local.(*ident)
+					p.ident(local)
+					if string(p.identName(local)) != string(spec.name) {
+						p.writeString("as")
+						p.write(spec.name)
+					}
+				}
+				p.writeString("}")
+			}
+			if s.module != nil {
+				p.writeString("from")
+				p.write(s.module)
+			}
+			p.needSemi = true
+		}
+	case *commentStmt:
+		p.writeMapped(s.data, s.data)
+		if s.data[0] == '#' || s.data[1] == '/' {
+			p.writeString("\n") // hashbang or single-line comment
+		}
+	}
+}
+
+// forInit writes the initializer of a for statement, where the in operator must be parenthesized.
+func (p *printer) forInit(s stmt) {
+	switch s := s.(type) {
+	case *varDecl:
+		p.varDecl(s, true)
+	case *exprStmt:
+		if startsAmbiguous(s.x, false) && isLet(s.x) || containsIn(s.x) {
+			p.writeString("(")
+			p.expr(s.x, precSeq)
+			p.writeString(")")
+		} else {
+			p.expr(s.x, precSeq)
+		}
+	}
+}
+
+func (p *printer) varDecl(s *varDecl, forInit bool) {
+	switch s.tok {
+	case js.Var:
+		p.writeString("var")
+	case js.Let:
+		p.writeString("let")
+	case js.Const:
+		p.writeString("const")
+	}
+	for i, b := range s.list {
+		if i != 0 {
+			p.writeString(",")
+		}
+		p.expr(b.target, precAssign)
+		if b.init != nil {
+			p.writeString("=")
+			if forInit && containsIn(b.init) {
+				p.writeString("(")
+				p.expr(b.init, precSeq)
+				p.writeString(")")
+			} else {
+				p.expr(b.init, precAssign)
+			}
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+func (p *printer) identName(id *ident) []byte {
+	if id.v != nil {
+		return id.v.Name()
+	}
+	return id.name
+}
+
+func (p *printer) ident(id *ident) {
+	p.writeMapped(p.identName(id), id.name)
+}
+
+func (p *printer) params(f *funcNode) {
+	p.writeString("(")
+	for i, param := range f.params {
+		if i != 0 {
+			p.writeString(",")
+		}
+		p.expr(param, precAssign)
+	}
+	p.writeString(")")
+}
+
+func (p *printer) function(f *funcNode) {
+	if f.async {
+		p.writeString("async")
+	}
+	p.writeString("function")
+	if f.generator {
+		p.writeString("*")
+	}
+	if f.name != nil {
+		p.ident(f.name)
+	}
+	p.params(f)
+	p.block(f.body)
+}
+
+func (p *printer) arrow(f *funcNode) {
+	if f.async {
+		p.writeString("async")
+	}
+	if len(f.params) == 1 {
+		if id, ok := f.params[0].(*ident); ok {
+			p.ident(id)
+		} else {
+			p.params(f)
+		}
+	} else {
+		p.params(f)
+	}
+	p.writeString("=>")
+	if f.exprBody != nil {
+		if startsAmbiguous(f.exprBody, false) && isObject(f.exprBody) || exprPrec(f.exprBody) < precAssign {
+			p.writeString("(")
+			p.expr(f.exprBody, precSeq)
+			p.writeString(")")
+		} else {
+			p.expr(f.exprBody, precAssign)
+		}
+	} else {
+		p.block(f.body)
+	}
+}
+
+func (p *printer) class(c *classNode) {
+	p.writeString("class")
+	if c.name != nil {
+		p.ident(c.name)
+	}
+	if c.extends != nil {
+		p.writeString("extends")
+		p.expr(c.extends, precCall)
+	}
+	p.writeString("{")
+	for i, prop := range c.list {
+		if i != 0 && c.list[i-1].kind == propField {
+			p.writeString(";")
+		}
+		p.property(prop)
+	}
+	p.writeString("}")
+}
+
+func (p *printer) propertyKey(prop property) {
+	if prop.computed {
+		p.writeString("[")
+		p.expr(prop.key, precAssign)
+		p.writeString("]")
+	} else {
+		p.expr(prop.key, precPrimary)
+	}
+}
+
+func (p *printer) property(prop property) {
+	if prop.static {
+		p.writeString("static")
+	}
+	switch prop.kind {
+	case propSpread:
+		p.writeString("...")
+		p.expr(prop.value, precAssign)
+	case propInit:
+		if prop.shorthand && !prop.computed {
+			key := prop.key.(*literal).data
+			value := prop.value
+			var init expr
+			if assign, ok := value.(*assignExpr); ok {
+				value, init = assign.target, assign.value
+			}
+			if id, ok := value.(*ident); ok && string(p.identName(id)) == string(key) {
+				p.ident(id)
+				if init != nil {
+					p.writeString("=")
+					p.expr(init, precAssign)
+				}
+				return
+			}
+		}
+		p.propertyKey(prop)
+		p.writeString(":")
+		p.expr(prop.value, precAssign)
+	case propField:
+		p.propertyKey(prop)
+		if prop.value != nil {
+			p.writeString("=")
+			p.expr(prop.value, precAssign)
+		}
+	default:
+		f := prop.value.(*funcExpr).f
+		if f.async {
+			p.writeString("async")
+		}
+		if f.generator {
+			p.writeString("*")
+		}
+		if prop.kind == propGet {
+			p.writeString("get")
+		} else if prop.kind == propSet {
+			p.writeString("set")
+		}
+		p.propertyKey(prop)
+		p.params(f)
+		p.block(f.body)
+	}
+}
+
+func (p *printer) args(args []expr) {
+	p.writeString("(")
+	for i, arg := range args {
+		if i != 0 {
+			p.writeString(",")
+		}
+		p.expr(arg, precAssign)
+	}
+	p.writeString(")")
+}
+
+// expr writes an expression and wraps it in parentheses when its precedence is lower than prec.
+func (p *printer) expr(e expr, prec int) {
+	if exprPrec(e) < prec {
+		if n, ok := e.(*newExpr); !ok || exprPrec(n) != precNew {
+			p.writeString("(")
+			p.expr(e, precSeq)
+			p.writeString(")")
+			return
+		}
+	}
+
+	switch e := e.(type) {
+	case *ident:
+		p.ident(e)
+	case *literal:
+		if e.tt == js.NumericToken {
+			p.writeMapped(minifyNumber(e.data), e.data)
+		} else if e.tt == js.StringToken {
+			p.writeMapped(minifyString(e.data), e.data)
+		} else {
+			p.writeMapped(e.data, e.data)
+		}
+	case *templateExpr:
+		if e.tag != nil {
+			p.expr(e.tag, precCall)
+		}
+		for i, part := range e.parts {
+			p.write(part)
+			if i < len(e.list) {
+				p.expr(e.list[i], precSeq)
+			}
+		}
+	case *arrayExpr:
+		p.writeString("[")
+		for i, item := range e.list {
+			if i != 0 {
+				p.writeString(",")
+			}
+			if item != nil {
+				p.expr(item, precAssign)
+			}
+		}
+		if 0 < len(e.list) && e.list[len(e.list)-1] == nil {
+			p.writeString(",")
+		}
+		p.writeString("]")
+	case *objectExpr:
+		p.writeString("{")
+		for i, prop := range e.list {
+			if i != 0 {
+				p.writeString(",")
+			}
+			p.property(prop)
+		}
+		p.writeString("}")
+	case *funcExpr:
+		if e.f.arrow {
+			p.arrow(e.f)
+		} else {
+			p.function(e.f)
+		}
+	case *classExpr:
+		p.class(e.c)
+	case *unaryExpr:
+		p.write(e.op)
+		p.expr(e.x, precUnary)
+	case *postfixExpr:
+		p.expr(e.x, precNew)
+		p.write(e.op)
+	case *binaryExpr:
+		prec := binaryPrec[string(e.op)]
+		left, right := prec, prec+1
+		if prec == precExp {
+			left, right = precUpdate, precExp
+		}
+		p.binaryOperand(e.x, left, e.op)
+		p.write(e.op)
+		p.binaryOperand(e.y, right, e.op)
+	case *assignExpr:
+		p.expr(e.target, precNew)
+		p.write(e.op)
+		p.expr(e.value, precAssign)
+	case *condExpr:
+		p.expr(e.cond, precCoalesce)
+		p.writeString("?")
+		p.expr(e.x, precAssign)
+		p.writeString(":")
+		p.expr(e.y, precAssign)
+	case *seqExpr:
+		for i, item := range e.list {
+			if i != 0 {
+				p.writeString(",")
+			}
+			p.expr(item, precAssign)
+		}
+	case *callExpr:
+		p.chainObject(e.callee, e.optional || e.inChain)
+		if e.optional {
+			p.writeString("?.")
+		}
+		p.args(e.args)
+	case *newExpr:
+		p.writeString("new")
+		if hasCall(e.callee) {
+			p.writeString("(")
+			p.expr(e.callee, precSeq)
+			p.writeString(")")
+		} else {
+			p.expr(e.callee, precCall)
+		}
+		if 0 < len(e.args) || prec > precNew {
+			p.args(e.args)
+		}
+	case *memberExpr:
+		p.chainObject(e.obj, e.optional || e.inChain)
+		if lit, ok := e.obj.(*literal); ok && lit.tt == js.NumericToken && isDecimalInteger(minifyNumber(lit.data)) {
+			p.writeString(".") // 1..toString()
+		}
+		if e.optional {
+			p.writeString("?.")
+		} else if e.name != nil {
+			p.writeString(".")
+		}
+		if e.name != nil {
+			p.writeMapped(e.name, e.name)
+		} else {
+			p.writeString("[")
+			p.expr(e.index, precSeq)
+			p.writeString("]")
+		}
+	case *yieldExpr:
+		p.writeString("yield")
+		if e.delegate {
+			p.writeString("*")
+		}
+		if e.x != nil {
+			p.expr(e.x, precAssign)
+		}
+	case *spreadExpr:
+		p.writeString("...")
+		p.expr(e.x, precAssign)
+	}
+}
+
+// binaryOperand writes an operand of a binary expression, ?? cannot be mixed with || and && without parentheses.
+func (p *printer) binaryOperand(e expr, prec int, op []byte) {
+	if b, ok := e.(*binaryExpr); ok {
+		if op[0] == '?' && (b.op[0] == '|' && len(b.op) == 2 || b.op[0] == '&' && len(b.op) == 2) || b.op[0] == '?' && (op[0] == '|' && len(op) == 2 || op[0] == '&' && len(op) == 2) {
+			p.writeString("(")
+			p.expr(e, precSeq)
+			p.writeString(")")
+			return
+		}
+	}
+	p.expr(e, prec)
+}
+
+// chainObject writes the object of a member or call expression, which must be parenthesized to stop an optional chain.
+func (p *printer) chainObject(obj expr, inChain bool) {
+	if !inChain && isOptionalChain(obj) {
+		p.writeString("(")
+		p.expr(obj, precSeq)
+		p.writeString(")")
+		return
+	}
+	p.expr(obj, precCall)
+}
+
+func isOptionalChain(e expr) bool {
+	switch e := e.(type) {
+	case *memberExpr:
+		return e.optional || e.inChain
+	case *callExpr:
+		return e.optional || e.inChain
+	}
+	return false
+}
+
+// isDecimalInteger returns true if a numeric literal would absorb a following dot, as in 1 .toString().
+func isDecimalInteger(b []byte) bool {
+	for _, c := range b {
+		if (c < '0' || '9' < c) && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// hasCall returns true if the callee of a new expression contains a call, which must be parenthesized.
+func hasCall(e expr) bool {
+	for {
+		switch x := e.(type) {
+		case *callExpr:
+			return true
+		case *memberExpr:
+			e = x.obj
+		case *templateExpr:
+			if x.tag == nil {
+				return false
+			}
+			e = x.tag
+		default:
+			return false
+		}
+	}
+}
+
+// leftmost returns the expression that is written first.
+func leftmost(e expr) expr {
+	for {
+		switch x := e.(type) {
+		case *seqExpr:
+			e = x.list[0]
+		case *assignExpr:
+			e = x.target
+		case *condExpr:
+			e = x.cond
+		case *binaryExpr:
+			e = x.x
+		case *postfixExpr:
+			e = x.x
+		case *callExpr:
+			e = x.callee
+		case *memberExpr:
+			e = x.obj
+		case *templateExpr:
+			if x.tag == nil {
+				return e
+			}
+			e = x.tag
+		default:
+			return e
+		}
+	}
+}
+
+// startsAmbiguous returns true if the expression starts with a token that would be interpreted as a statement or block.
+func startsAmbiguous(e expr, stmt bool) bool {
+	switch x := leftmost(e).(type) {
+	case *objectExpr:
+		return true
+	case *funcExpr:
+		return stmt && !x.f.arrow
+	case *classExpr:
+		return stmt
+	case *ident:
+		return stmt && string(x.name) == "let"
+	}
+	return false
+}
+
+func isObject(e expr) bool {
+	_, ok := leftmost(e).(*objectExpr)
+	return ok
+}
+
+func isLet(e expr) bool {
+	id, ok := leftmost(e).(*ident)
+	return ok && string(id.name) == "let"
+}
+
+// containsIn returns true if the expression contains the in operator outside of parentheses, brackets and functions.
+func containsIn(e expr) bool {
+	switch x := e.(type) {
+	case *binaryExpr:
+		return string(x.op) == "in" || containsIn(x.x) || containsIn(x.y)
+	case *seqExpr:
+		for _, item := range x.list {
+			if containsIn(item) {
+				return true
+			}
+		}
+	case *assignExpr:
+		return containsIn(x.target) || containsIn(x.value)
+	case *condExpr:
+		return containsIn(x.cond) || containsIn(x.x) || containsIn(x.y)
+	case *unaryExpr:
+		return containsIn(x.x)
+	case *postfixExpr:
+		return containsIn(x.x)
+	case *yieldExpr:
+		return x.x != nil && containsIn(x.x)
+	case *funcExpr:
+		return x.f.arrow && x.f.exprBody != nil && containsIn(x.f.exprBody)
+	}
+	return false
+}
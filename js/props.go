@@ -0,0 +1,146 @@
+package js // import "github.com/tdewolff/minify/js"
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// NameCache holds the short names of mangled properties. Sharing a name cache between minifications keeps the names consistent across files,
+// and storing it as JSON keeps them stable between builds. It is safe for concurrent use.
+type NameCache struct {
+	mu       sync.Mutex
+	names    map[string]string // original name to short name
+	used     map[string]bool
+	reserved map[string]bool // properties that are not mangled in any file
+}
+
+// NewNameCache returns a new, empty name cache.
+func NewNameCache() *NameCache {
+	return &NameCache{
+		names:    map[string]string{},
+		used:     map[string]bool{},
+		reserved: map[string]bool{},
+	}
+}
+
+// MarshalJSON encodes the name cache as an object of original names to short names.
+func (c *NameCache) MarshalJSON() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return json.Marshal(c.names)
+}
+
+// UnmarshalJSON adds the names of an encoded name cache.
+func (c *NameCache) UnmarshalJSON(b []byte) error {
+	names := map[string]string{}
+	if err := json.Unmarshal(b, &names); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.names == nil {
+		c.names = map[string]string{}
+		c.used = map[string]bool{}
+		c.reserved = map[string]bool{}
+	}
+	for name, short := range names {
+		c.names[name] = short
+		c.used[short] = true
+	}
+	return nil
+}
+
+// rename returns the short names for the given names, new names are chosen in order and never equal to the names that are reserved
+// in this or earlier files. Names of the cache that are reserved in this file, such as a for a._x in an earlier file and a.a in this
+// file, are replaced by new names.
+func (c *NameCache) rename(names []string, reserved map[string]bool) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.names == nil {
+		c.names = map[string]string{}
+		c.used = map[string]bool{}
+		c.reserved = map[string]bool{}
+	}
+	for name := range reserved {
+		c.reserved[name] = true
+	}
+
+	renamed := make(map[string]string, len(names))
+	n := 0
+	for _, name := range names {
+		short, ok := c.names[name]
+		ok = ok && !reserved[short]
+		for !ok {
+			short = string(shortName(n))
+			n++
+			ok = !c.used[short] && !c.reserved[short] && !reservedNames[short]
+		}
+		c.names[name] = short
+		c.used[short] = true
+		renamed[name] = short
+	}
+	return renamed
+}
+
+// unmangledProps are never mangled as they have special meaning.
+var unmangledProps = map[string]bool{
+	"__proto__":   true,
+	"constructor": true,
+}
+
+// mangleProps renames the properties matching re in member expressions, object literals, destructuring patterns and classes.
+// Quoted and computed property names, such as a["_x"], are never renamed.
+func mangleProps(list []stmt, re *regexp.Regexp, cache *NameCache) {
+	refs := map[string][]*[]byte{}
+	reserved := map[string]bool{}
+	add := func(name *[]byte) {
+		if s := string(*name); (*name)[0] != '#' {
+			if re.MatchString(s) && !unmangledProps[s] {
+				refs[s] = append(refs[s], name)
+			} else {
+				reserved[s] = true
+			}
+		}
+	}
+	walkStmts(list, func(n interface{}) bool {
+		switch n := n.(type) {
+		case *memberExpr:
+			if n.name != nil {
+				add(&n.name)
+			}
+		case *property:
+			if lit, ok := n.key.(*literal); ok && !n.computed && lit.tt == js.IdentifierToken {
+				add(&lit.data)
+			}
+		}
+		return true
+	})
+	if len(refs) == 0 {
+		return
+	}
+
+	// names used more often get shorter names
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(refs[names[i]]) != len(refs[names[j]]) {
+			return len(refs[names[i]]) > len(refs[names[j]])
+		}
+		return names[i] < names[j]
+	})
+
+	renamed := cache.rename(names, reserved)
+	for name, list := range refs {
+		short := []byte(renamed[name])
+		for _, ref := range list {
+			*ref = short
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package js // import "github.com/tdewolff/minify/js"
+
+import (
+	"sort"
+)
+
+// variable is a declared binding, all its identifiers share the variable so that renaming it renames all occurrences.
+type variable struct {
+	name  []byte
+	short []byte // new name, nil if unchanged
+	uses  int
+	fixed bool // name must be kept
+}
+
+// Name returns the name that is written out.
+func (v *variable) Name() []byte {
+	if v.short != nil {
+		return v.short
+	}
+	return v.name
+}
+
+type scope struct {
+	parent   *scope
+	children []*scope
+	fn       bool   // function or program scope that receives var declarations
+	arrow    bool   // arrow functions have no arguments binding
+	unsafe   bool   // contains direct eval or with, names are observable
+	params   *scope // scope of the parameters when they have expressions, the body has a scope of its own
+
+	vars  []*variable
+	names map[string]*variable
+	refs  []*ident
+
+	outer   map[*variable]bool // variables declared in an ancestor that are referenced in this scope or its descendants
+	globals map[string]bool    // unresolved names referenced in this scope or its descendants
+}
+
+func newScope(parent *scope, fn bool) *scope {
+	s := &scope{
+		parent: parent,
+		fn:     fn,
+		names:  map[string]*variable{},
+	}
+	if parent != nil {
+		parent.children = append(parent.children, s)
+	}
+	return s
+}
+
+// funcScope returns the nearest scope that receives var declarations.
+func (s *scope) funcScope() *scope {
+	for !s.fn {
+		s = s.parent
+	}
+	return s
+}
+
+// declare adds a binding for the identifier to the scope, redeclarations share the same variable.
+func (s *scope) declare(id *ident) {
+	v, ok := s.names[string(id.name)]
+	if !ok {
+		v = &variable{name: id.name}
+		s.names[string(id.name)] = v
+		s.vars = append(s.vars, v)
+	}
+	v.uses++
+	id.v = v
+}
+
+func (s *scope) reference(id *ident) {
+	s.refs = append(s.refs, id)
+}
+
+// markUnsafe prevents renaming in this scope and all of its ancestors, which is required for direct eval and with statements.
+func (s *scope) markUnsafe() {
+	for ; s != nil; s = s.parent {
+		s.unsafe = true
+	}
+}
+
+// resolve binds all references to their declarations, it must be called on the root scope after parsing.
+func (s *scope) resolve() {
+	for _, id := range s.refs {
+		name := string(id.name)
+		var v *variable
+		decl := s
+		for ; decl != nil; decl = decl.parent {
+			if v = decl.names[name]; v != nil {
+				break
+			} else if name == "arguments" && decl.fn && !decl.arrow && decl.parent != nil {
+				break // implicit binding of functions, no variable is ever renamed to arguments
+			}
+		}
+		if v != nil {
+			id.v = v
+			v.uses++
+		}
+		for t := s; t != decl; t = t.parent {
+			if v != nil {
+				if t.outer == nil {
+					t.outer = map[*variable]bool{}
+				}
+				t.outer[v] = true
+			} else {
+				if t.globals == nil {
+					t.globals = map[string]bool{}
+				}
+				t.globals[name] = true
+			}
+		}
+	}
+	for _, child := range s.children {
+		child.resolve()
+	}
+}
+
+// addGlobal marks a global name as referenced in this scope and all of its descendants, so that no variable is renamed to it.
+func (s *scope) addGlobal(name string) {
+	if s.globals == nil {
+		s.globals = map[string]bool{}
+	}
+	s.globals[name] = true
+	for _, child := range s.children {
+		child.addGlobal(name)
+	}
+}
+
+// rename gives short names to all variables that are not global and not in an unsafe scope.
+// Variables used more often get shorter names. Names are reused between sibling scopes.
+func (s *scope) rename(keepTop bool) {
+	if !s.unsafe && !(keepTop && s.parent == nil) {
+		used := map[string]bool{}
+		for name := range s.globals {
+			used[name] = true
+		}
+		for v := range s.outer {
+			used[string(v.Name())] = true
+		}
+		if s.params != nil {
+			for _, v := range s.params.vars {
+				used[string(v.Name())] = true // a body variable with the name of a parameter starts with its value
+			}
+		}
+		for _, v := range s.vars {
+			if v.fixed {
+				used[string(v.name)] = true
+			}
+		}
+
+		vars := make([]*variable, 0, len(s.vars))
+		for _, v := range s.vars {
+			if !v.fixed {
+				vars = append(vars, v)
+			}
+		}
+		sort.SliceStable(vars, func(i, j int) bool {
+			return vars[i].uses > vars[j].uses
+		})
+
+		n := 0
+		for _, v := range vars {
+			for {
+				name := shortName(n)
+				n++
+				if !used[string(name)] && !reservedNames[string(name)] {
+					v.short = name
+					break
+				}
+			}
+		}
+	}
+	for _, child := range s.children {
+		child.rename(keepTop)
+	}
+}
+
+var nameStartChars = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ_$")
+var nameChars = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ_$0123456789")
+
+// shortName returns the n-th identifier in the sequence a, b, ..., $, aa, ba, ...
+func shortName(n int) []byte {
+	name := []byte{nameStartChars[n%len(nameStartChars)]}
+	n /= len(nameStartChars)
+	for n > 0 {
+		n--
+		name = append(name, nameChars[n%len(nameChars)])
+		n /= len(nameChars)
+	}
+	return name
+}
+
+var reservedNames = map[string]bool{
+	"await": true, "break": true, "case": true, "catch": true, "class": true, "const": true, "continue": true,
+	"debugger": true, "default": true, "delete": true, "do": true, "else": true, "enum": true, "export": true,
+	"extends": true, "false": true, "finally": true, "for": true, "function": true, "if": true, "implements": true,
+	"import": true, "in": true, "instanceof": true, "interface": true, "let": true, "new": true, "null": true,
+	"package": true, "private": true, "protected": true, "public": true, "return": true, "static": true,
+	"super": true, "switch": true, "this": true, "throw": true, "true": true, "try": true, "typeof": true,
+	"var": true, "void": true, "while": true, "with": true, "yield": true, "arguments": true, "eval": true,
+	"undefined": true, "NaN": true, "Infinity": true,
+}
@@ -0,0 +1,199 @@
+package js // import "github.com/tdewolff/minify/js"
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/buffer"
+)
+
+var placeholderBytes = []byte("_minify_tpl_")
+
+// minifyTemplates minifies the contents of tagged templates such as html`<p>${x}</p>` by the minifier registered for the mimetype of their tag.
+// Substitutions are replaced by placeholders while minifying. A template is kept as is when its contents cannot be minified,
+// or when the placeholders are not all kept in order, such as for substitutions that are removed or that make the contents invalid.
+func minifyTemplates(m *minify.M, list []stmt, tags map[string]string) {
+	walkStmts(list, func(n interface{}) bool {
+		if t, ok := n.(*templateExpr); ok && t.tag != nil {
+			if mediatype, ok := tags[tagName(t.tag)]; ok {
+				minifyTemplate(m, t, mediatype)
+			}
+		}
+		return true
+	})
+}
+
+// tagName returns the name of a template tag such as html or lit.html.
+func tagName(e expr) string {
+	switch e := e.(type) {
+	case *ident:
+		return string(e.name)
+	case *memberExpr:
+		if e.name != nil {
+			if obj := tagName(e.obj); obj != "" {
+				return obj + "." + string(e.name)
+			}
+		}
+	}
+	return ""
+}
+
+func minifyTemplate(m *minify.M, t *templateExpr, mediatype string) {
+	// join the contents of the template parts, which exclude the backticks and the ${ } delimiters
+	src := []byte{}
+	for i, part := range t.parts {
+		part = part[1:]
+		if i+1 < len(t.parts) {
+			part = part[:len(part)-2]
+		} else {
+			part = part[:len(part)-1]
+		}
+		if bytes.IndexByte(part, '\\') != -1 || bytes.Contains(part, placeholderBytes) {
+			return // escapes would be interpreted differently
+		}
+		src = append(src, part...)
+		if i+1 < len(t.parts) {
+			src = append(src, placeholder(i)...)
+		}
+	}
+
+	// the HTML minifier writes attribute names in lower case, but templates may depend on their case such as for .someProp=${x} in lit
+	mimetype, params := parse.Mediatype([]byte(mediatype))
+	isHTML := string(mimetype) == "text/html"
+	quoted := []bool{}
+	names := map[string][]byte{}
+	if isHTML {
+		quoted = scanHTML(src, func(name []byte) {
+			if lower := parse.ToLower(parse.Copy(name)); !bytes.Equal(lower, name) {
+				names[string(lower)] = parse.Copy(name) // the minifier may change the source in place
+			}
+		})
+	}
+
+	w := &bytes.Buffer{}
+	if err := m.MinifyMimetype(mimetype, w, buffer.NewReader(src), params); err != nil {
+		return
+	}
+	dst := w.Bytes()
+	if bytes.IndexByte(dst, '`') != -1 || bytes.IndexByte(dst, '\\') != -1 || bytes.Contains(dst, []byte("${")) {
+		return
+	}
+	if isHTML && 0 < len(names) {
+		scanHTML(dst, func(name []byte) {
+			if orig, ok := names[string(name)]; ok {
+				copy(name, orig)
+			}
+		})
+	}
+
+	parts := make([][]byte, 0, len(t.parts))
+	for i := range t.list {
+		ph := placeholder(i)
+		j := bytes.Index(dst, ph)
+		if j == -1 {
+			return
+		}
+		if i < len(quoted) && quoted[i] {
+			dst, j = quoteAttrVal(dst, j, len(ph))
+		}
+		parts = append(parts, dst[:j])
+		dst = dst[j+len(ph):]
+	}
+	if bytes.Contains(dst, placeholderBytes) {
+		return
+	}
+	parts = append(parts, dst)
+
+	for i, part := range parts {
+		b := []byte{'}'}
+		if i == 0 {
+			b[0] = '`'
+		}
+		b = append(b, part...)
+		if i+1 < len(parts) {
+			b = append(b, '$', '{')
+		} else {
+			b = append(b, '`')
+		}
+		t.parts[i] = b
+	}
+}
+
+func placeholder(i int) []byte {
+	b := strconv.AppendInt(append([]byte{}, placeholderBytes...), int64(i), 10)
+	return append(b, '_')
+}
+
+// scanHTML calls f for the name of every attribute in HTML and returns for every placeholder whether it is in a quoted attribute value.
+func scanHTML(b []byte, f func(name []byte)) []bool {
+	quoted := []bool{}
+	inTag := false
+	for i := 0; i < len(b); {
+		if bytes.HasPrefix(b[i:], placeholderBytes) {
+			quoted = append(quoted, false)
+			i += len(placeholderBytes)
+			continue
+		}
+
+		c := b[i]
+		if !inTag {
+			i++
+			if c == '<' && i < len(b) && 'a' <= b[i]|0x20 && b[i]|0x20 <= 'z' {
+				inTag = true
+				for i < len(b) && !isHTMLSpace(b[i]) && b[i] != '>' && b[i] != '/' {
+					i++
+				}
+			}
+		} else if c == '>' {
+			inTag = false
+			i++
+		} else if c == '"' || c == '\'' {
+			for i++; i < len(b) && b[i] != c; i++ {
+				if bytes.HasPrefix(b[i:], placeholderBytes) {
+					quoted = append(quoted, true)
+					i += len(placeholderBytes) - 1
+				}
+			}
+			i++
+		} else if isHTMLSpace(c) || c == '/' || c == '=' {
+			i++
+		} else {
+			start := i
+			for i < len(b) && !isHTMLSpace(b[i]) && b[i] != '=' && b[i] != '>' && !bytes.HasPrefix(b[i:], placeholderBytes) {
+				i++
+			}
+			if b[start-1] != '=' {
+				f(b[start:i])
+			}
+		}
+	}
+	return quoted
+}
+
+// quoteAttrVal adds quotes around the unquoted attribute value that contains the placeholder at position i.
+func quoteAttrVal(b []byte, i, n int) ([]byte, int) {
+	start := i
+	for 0 < start && !isHTMLSpace(b[start-1]) && b[start-1] != '=' && b[start-1] != '"' && b[start-1] != '\'' && b[start-1] != '<' && b[start-1] != '>' {
+		start--
+	}
+	if start == 0 || b[start-1] != '=' {
+		return b, i // already quoted
+	}
+	end := i + n
+	for end < len(b) && !isHTMLSpace(b[end]) && b[end] != '>' {
+		end++
+	}
+	quoted := make([]byte, 0, len(b)+2)
+	quoted = append(quoted, b[:start]...)
+	quoted = append(quoted, '"')
+	quoted = append(quoted, b[start:end]...)
+	quoted = append(quoted, '"')
+	quoted = append(quoted, b[end:]...)
+	return quoted, i + 1
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
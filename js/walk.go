@@ -0,0 +1,151 @@
+package js // import "github.com/tdewolff/minify/js"
+
+// walk traverses the AST in depth-first order. It calls f for every statement, expression, property, function and class, and visits their children only when f returns true.
+// Keys of properties are only visited when they are computed.
+func walk(n interface{}, f func(interface{}) bool) {
+	if !f(n) {
+		return
+	}
+
+	switch n := n.(type) {
+	case *blockStmt:
+		walkStmts(n.list, f)
+	case *exprStmt:
+		walk(n.x, f)
+	case *varDecl:
+		for _, b := range n.list {
+			walk(b.target, f)
+			walkExpr(b.init, f)
+		}
+	case *funcDecl:
+		walk(n.f, f)
+	case *classDecl:
+		walk(n.c, f)
+	case *ifStmt:
+		walk(n.cond, f)
+		walk(n.body, f)
+		walkStmt(n.els, f)
+	case *doWhileStmt:
+		walk(n.body, f)
+		walk(n.cond, f)
+	case *whileStmt:
+		walk(n.cond, f)
+		walk(n.body, f)
+	case *forStmt:
+		walkStmt(n.init, f)
+		walkExpr(n.cond, f)
+		walkExpr(n.post, f)
+		walk(n.body, f)
+	case *forInStmt:
+		walk(n.init, f)
+		walk(n.value, f)
+		walk(n.body, f)
+	case *returnStmt:
+		walkExpr(n.x, f)
+	case *throwStmt:
+		walk(n.x, f)
+	case *tryStmt:
+		walk(n.body, f)
+		walkExpr(n.param, f)
+		if n.catch != nil {
+			walk(n.catch, f)
+		}
+		if n.finally != nil {
+			walk(n.finally, f)
+		}
+	case *switchStmt:
+		walk(n.x, f)
+		for _, c := range n.cases {
+			walkExpr(c.test, f)
+			walkStmts(c.list, f)
+		}
+	case *labeledStmt:
+		walk(n.body, f)
+	case *withStmt:
+		walk(n.x, f)
+		walk(n.body, f)
+	case *exportStmt:
+		walkStmt(n.decl, f)
+
+	case *templateExpr:
+		walkExpr(n.tag, f)
+		walkExprs(n.list, f)
+	case *arrayExpr:
+		walkExprs(n.list, f)
+	case *objectExpr:
+		for i := range n.list {
+			walk(&n.list[i], f)
+		}
+	case *property:
+		if n.computed {
+			walk(n.key, f)
+		}
+		walkExpr(n.value, f)
+	case *funcExpr:
+		walk(n.f, f)
+	case *funcNode:
+		walkExprs(n.params, f)
+		walkStmts(n.body, f)
+		walkExpr(n.exprBody, f)
+	case *classExpr:
+		walk(n.c, f)
+	case *classNode:
+		walkExpr(n.extends, f)
+		for i := range n.list {
+			walk(&n.list[i], f)
+		}
+	case *unaryExpr:
+		walk(n.x, f)
+	case *postfixExpr:
+		walk(n.x, f)
+	case *binaryExpr:
+		walk(n.x, f)
+		walk(n.y, f)
+	case *assignExpr:
+		walk(n.target, f)
+		walk(n.value, f)
+	case *condExpr:
+		walk(n.cond, f)
+		walk(n.x, f)
+		walk(n.y, f)
+	case *seqExpr:
+		walkExprs(n.list, f)
+	case *callExpr:
+		walk(n.callee, f)
+		walkExprs(n.args, f)
+	case *newExpr:
+		walk(n.callee, f)
+		walkExprs(n.args, f)
+	case *memberExpr:
+		walk(n.obj, f)
+		walkExpr(n.index, f)
+	case *yieldExpr:
+		walkExpr(n.x, f)
+	case *spreadExpr:
+		walk(n.x, f)
+	}
+}
+
+func walkStmt(s stmt, f func(interface{}) bool) {
+	if s != nil {
+		walk(s, f)
+	}
+}
+
+func walkExpr(e expr, f func(interface{}) bool) {
+	if e != nil {
+		walk(e, f)
+	}
+}
+
+func walkStmts(list []stmt, f func(interface{}) bool) {
+	for _, s := range list {
+		walk(s, f)
+	}
+}
+
+func walkExprs(list []expr, f func(interface{}) bool) {
+	for _, e := range list {
+		walkExpr(e, f)
+	}
+}
@@ -61,7 +61,8 @@ type M struct {
 	literal map[string]Minifier
 	pattern []patternMinifier
 
-	URL *url.URL
+	URL   *url.URL
+	Names *Names // renames class names and ids when set, see NewNames
 }
 
 // New returns a new M.
@@ -70,6 +71,7 @@ func New() *M {
 		map[string]Minifier{},
 		[]patternMinifier{},
 		nil,
+		nil,
 	}
 }
 
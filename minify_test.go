@@ -272,6 +272,35 @@ func TestMiddleware(t *testing.T) {
 	test.String(t, b.String(), "test", "equal input after dummy minify middleware")
 }
 
+func TestSourceMap(t *testing.T) {
+	// removes whitespace and maps every word
+	m.AddFunc("dummy/words", func(m *M, w io.Writer, r io.Reader, _ map[string]string) error {
+		mapper := w.(Mapper)
+		src := r.(interface {
+			Bytes() []byte
+		}).Bytes()
+		for _, word := range bytes.Fields(src) {
+			mapper.Map(word)
+			w.Write(word)
+		}
+		w.Write([]byte("\ncopy"))
+		mapper.Map([]byte("copy"))
+		return nil
+	})
+
+	w := &bytes.Buffer{}
+	sm := NewSourceMap("out.txt")
+	sm.AddSource("a.txt", 0)
+	sm.AddSource("b.txt", 10)
+	err := m.MinifyWithSourceMap("dummy/words", w, bytes.NewBufferString("ab cd\n ef\n\n\u00e9 gh"), sm, "out.txt.map")
+	test.Error(t, err)
+	test.String(t, w.String(), "abcdef\u00e9gh\ncopy\n//# sourceMappingURL=out.txt.map")
+
+	b, err := sm.MarshalJSON()
+	test.Error(t, err)
+	test.String(t, string(b), `{"version":3,"file":"out.txt","sources":["a.txt","b.txt"],"names":[],"mappings":"AAAA,EAAG,EACF,ECAD,CAAE"}`)
+}
+
 func TestHelperProcess(*testing.T) {
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
 		return
@@ -0,0 +1,232 @@
+package minify // import "github.com/tdewolff/minify"
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// Names holds the short names of renamed class names, ids and custom properties. When set on M, the CSS, HTML and SVG minifiers rename all
+// class names and ids, and the references to ids such as label for attributes and url(#id), consistently across files. Store it as JSON to keep the names stable
+// between builds, or to let server-side templates look up the short names. It is safe for concurrent use.
+//
+// Class names and ids that are added or read by scripts must be renamed by the JS minifier as well, or be left out of the documents.
+// Attribute selectors that match part of a name, such as [class^=icon-], no longer match after renaming.
+type Names struct {
+	mu         sync.Mutex
+	classes    nameMap
+	ids        nameMap
+	properties nameMap
+}
+
+type nameMap struct {
+	names map[string]string // original name to short name
+	used  map[string]bool
+}
+
+// NewNames returns a new, empty set of names.
+func NewNames() *Names {
+	return &Names{
+		classes:    nameMap{map[string]string{}, map[string]bool{}},
+		ids:        nameMap{map[string]string{}, map[string]bool{}},
+		properties: nameMap{map[string]string{}, map[string]bool{}},
+	}
+}
+
+// Class returns the short name of a class name.
+func (n *Names) Class(name []byte) []byte {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.classes.rename(name)
+}
+
+// ID returns the short name of an id.
+func (n *Names) ID(name []byte) []byte {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ids.rename(name)
+}
+
+// Property returns the short name of a custom property such as --main-color, both without the leading dashes. Custom properties are
+// renamed by the CSS minifier only when its RenameCustomProperties option is set.
+func (n *Names) Property(name []byte) []byte {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.properties.rename(name)
+}
+
+func (m *nameMap) rename(name []byte) []byte {
+	if m.names == nil {
+		m.names = map[string]string{}
+		m.used = map[string]bool{}
+	}
+	short, ok := m.names[string(name)]
+	for i := len(m.names); !ok; i++ {
+		short = shortName(i)
+		ok = !m.used[short]
+	}
+	m.names[string(name)] = short
+	m.used[short] = true
+	return []byte(short)
+}
+
+// shortName returns the i-th name in a, b, ..., Z, aa, ab, ..., which are valid in CSS selectors without escapes.
+func shortName(i int) string {
+	const first = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	const rest = first + "0123456789_-"
+	b := []byte{first[i%len(first)]}
+	for i /= len(first); 0 < i; i /= len(rest) {
+		i--
+		b = append(b, rest[i%len(rest)])
+	}
+	return string(b)
+}
+
+// Selector renames the class names and ids of a selector such as #menu .item, including those of attribute selectors that match the
+// whole value such as [class=btn], where names with escapes are kept as is.
+func (n *Names) Selector(selector []byte) []byte {
+	b := make([]byte, 0, len(selector))
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		if c == '[' || c == '"' || c == '\'' {
+			end := byte(']')
+			if c != '[' {
+				end = c
+			}
+			start := i
+			for i++; i < len(selector) && selector[i] != end; i++ {
+				if selector[i] == '\\' {
+					i++
+				}
+			}
+			if len(selector) <= i {
+				return append(b, selector[start:]...)
+			} else if c == '[' {
+				b = append(b, '[')
+				b = append(b, n.attrSelector(selector[start+1:i])...)
+				b = append(b, ']')
+				continue
+			}
+			b = append(b, selector[start:i+1]...)
+			continue
+		} else if (c == '.' || c == '#') && i+1 < len(selector) && (isNameStart(selector[i+1]) || selector[i+1] == '\\') {
+			start := i + 1
+			escaped := false
+			for i++; i < len(selector) && (isNameChar(selector[i]) || selector[i] == '\\'); i++ {
+				if selector[i] == '\\' {
+					escaped = true
+					i++
+				}
+			}
+			if escaped {
+				b = append(b, selector[start-1:i]...) // names with escapes are kept as is
+				i--
+				continue
+			}
+			b = append(b, c)
+			if c == '.' {
+				b = append(b, n.Class(selector[start:i])...)
+			} else {
+				b = append(b, n.ID(selector[start:i])...)
+			}
+			i--
+			continue
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+// attrSelector renames the value of an attribute selector without brackets that matches a whole class name or id, such as class=btn,
+// id="main" or href="#main". Other attribute selectors, such as class^=icon- or those with escapes, are kept as is.
+func (n *Names) attrSelector(attr []byte) []byte {
+	i := bytes.IndexByte(attr, '=')
+	if i < 1 {
+		return attr
+	}
+	name, value := bytes.TrimSpace(attr[:i]), bytes.TrimSpace(attr[i+1:])
+	include := 0 < len(name) && name[len(name)-1] == '~'
+	if include {
+		name = bytes.TrimSpace(name[:len(name)-1])
+	} else if len(name) == 0 || !isNameChar(name[len(name)-1]) {
+		return attr // ^=, $=, *= or |=
+	}
+
+	quote := []byte{}
+	if 0 < len(value) && (value[0] == '"' || value[0] == '\'') {
+		if len(value) < 2 || value[len(value)-1] != value[0] {
+			return attr // flag after the string
+		}
+		quote = value[:1]
+		value = value[1 : len(value)-1]
+	}
+	if len(value) == 0 || bytes.IndexAny(value, " \t\n\r\f\\\"'") != -1 {
+		return attr
+	}
+
+	b := append([]byte{}, attr[:i+1]...)
+	switch string(bytes.ToLower(name)) {
+	case "class":
+		b = append(append(b, quote...), n.Class(value)...)
+	case "id":
+		if include {
+			return attr
+		}
+		b = append(append(b, quote...), n.ID(value)...)
+	case "href":
+		if include || len(quote) == 0 || len(value) < 2 || value[0] != '#' {
+			return attr
+		}
+		b = append(append(append(b, quote...), '#'), n.ID(value[1:])...)
+	default:
+		return attr
+	}
+	return append(b, quote...)
+}
+
+func isNameStart(c byte) bool {
+	return c == '-' || c == '_' || 'a' <= c|0x20 && c|0x20 <= 'z' || 0x80 <= c
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || '0' <= c && c <= '9'
+}
+
+type namesJSON struct {
+	Classes    map[string]string `json:"classes"`
+	IDs        map[string]string `json:"ids"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// MarshalJSON encodes the names as an object with the classes, ids and properties objects of original names to short names.
+func (n *Names) MarshalJSON() ([]byte, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return json.Marshal(namesJSON{n.classes.names, n.ids.names, n.properties.names})
+}
+
+// UnmarshalJSON adds the names of encoded names.
+func (n *Names) UnmarshalJSON(b []byte) error {
+	names := namesJSON{}
+	if err := json.Unmarshal(b, &names); err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.classes.add(names.Classes)
+	n.ids.add(names.IDs)
+	n.properties.add(names.Properties)
+	return nil
+}
+
+func (m *nameMap) add(names map[string]string) {
+	if m.names == nil {
+		m.names = map[string]string{}
+		m.used = map[string]bool{}
+	}
+	for name, short := range names {
+		m.names[name] = short
+		m.used[short] = true
+	}
+}
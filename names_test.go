@@ -0,0 +1,68 @@
+package minify // import "github.com/tdewolff/minify"
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tdewolff/test"
+)
+
+func TestNames(t *testing.T) {
+	names := NewNames()
+	test.String(t, string(names.Class([]byte("navigation-header"))), "a")
+	test.String(t, string(names.Class([]byte("btn"))), "b")
+	test.String(t, string(names.Class([]byte("navigation-header"))), "a")
+	test.String(t, string(names.ID([]byte("main"))), "a")
+	test.String(t, string(names.Property([]byte("main-color"))), "a")
+
+	seen := map[string]bool{}
+	for i := 0; i < 10000; i++ {
+		name := shortName(i)
+		test.That(t, !seen[name], "unique name", name)
+		test.That(t, 'a' <= name[0]|0x20 && name[0]|0x20 <= 'z', "valid name", name)
+		seen[name] = true
+	}
+	test.String(t, shortName(51), "Z")
+	test.String(t, shortName(52), "aa")
+}
+
+func TestNamesSelector(t *testing.T) {
+	selectorTests := []struct {
+		selector string
+		expected string
+	}{
+		{".navigation-header", ".a"},
+		{"#main .navigation-header>li.btn", "#a .a>li.b"},
+		{"a[href='#top'].btn", "a[href='#a'].a"},
+		{".col-1\\/2", ".col-1\\/2"},
+		{".col-1\\/2 .btn, #\\31 0>#main", ".col-1\\/2 .a, #\\31 0>#a"},
+		{"[class=btn],[class~='btn'],[id=main]", "[class=a],[class~='a'],[id=a]"},
+		{"[class^=icon-],[class*='col-'],[class=btn i]", "[class^=icon-],[class*='col-'],[class=btn i]"},
+		{"p:nth-child(2n+1)", "p:nth-child(2n+1)"},
+	}
+	for _, tt := range selectorTests {
+		t.Run(tt.selector, func(t *testing.T) {
+			names := NewNames()
+			test.String(t, string(names.Selector([]byte(tt.selector))), tt.expected)
+		})
+	}
+}
+
+func TestNamesJSON(t *testing.T) {
+	names := NewNames()
+	names.Class([]byte("btn"))
+	names.ID([]byte("main"))
+	b, err := json.Marshal(names)
+	test.Error(t, err)
+	test.String(t, string(b), `{"classes":{"btn":"a"},"ids":{"main":"a"}}`)
+
+	names = NewNames()
+	test.Error(t, json.Unmarshal([]byte(`{"classes":{"btn":"a"}}`), names))
+	test.String(t, string(names.Class([]byte("nav"))), "b")
+	test.String(t, string(names.Class([]byte("btn"))), "a")
+
+	names.Property([]byte("main-color"))
+	b, err = json.Marshal(names)
+	test.Error(t, err)
+	test.String(t, string(b), `{"classes":{"btn":"a","nav":"b"},"ids":{},"properties":{"main-color":"a"}}`)
+}
@@ -0,0 +1,238 @@
+package minify // import "github.com/tdewolff/minify"
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/buffer"
+)
+
+// Mapper is implemented by writers that record a source map.
+// Minifiers call Map right before writing output that originates from data, which must be a slice of the input buffer.
+// Data that was copied or rewritten cannot be mapped and is ignored.
+type Mapper interface {
+	io.Writer
+	Map(data []byte)
+}
+
+type mapping struct {
+	line, col int // generated position
+	offset    int // position in the input
+}
+
+// SourceMap records the positions of the minified output in the original sources, following the Source Map Revision 3 specification at https://sourcemaps.info/spec.html.
+// Sources may be added after minification, which is useful when the input is a concatenation of files.
+type SourceMap struct {
+	File string // name of the generated file
+
+	sources   []string
+	starts    []int
+	src       []byte
+	mappings  []mapping
+	line, col int // end of the generated output
+}
+
+// NewSourceMap returns a new source map for the generated file.
+func NewSourceMap(file string) *SourceMap {
+	return &SourceMap{
+		File: file,
+	}
+}
+
+// AddSource adds a source file that starts at the given offset in the input, sources must be added in order.
+func (sm *SourceMap) AddSource(name string, offset int) {
+	sm.sources = append(sm.sources, name)
+	sm.starts = append(sm.starts, offset)
+}
+
+// MarshalJSON encodes the source map.
+func (sm *SourceMap) MarshalJSON() ([]byte, error) {
+	sources := sm.sources
+	if sources == nil {
+		sources = []string{}
+	}
+	return json.Marshal(struct {
+		Version  int      `json:"version"`
+		File     string   `json:"file,omitempty"`
+		Sources  []string `json:"sources"`
+		Names    []string `json:"names"`
+		Mappings string   `json:"mappings"`
+	}{3, sm.File, sources, []string{}, string(sm.encode())})
+}
+
+// WriteTo writes the source map as JSON.
+func (sm *SourceMap) WriteTo(w io.Writer) (int64, error) {
+	b, err := sm.MarshalJSON()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// encode returns the mappings field with the base64 VLQ encoded segments.
+func (sm *SourceMap) encode() []byte {
+	lines := []int{0}
+	for i, c := range sm.src {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+
+	b := []byte{}
+	line, col := 0, 0
+	prevSource, prevLine, prevCol := 0, 0, 0
+	for _, m := range sm.mappings {
+		source := sort.SearchInts(sm.starts, m.offset+1) - 1
+		if source < 0 {
+			continue // not in any source
+		}
+
+		// position in the input relative to the start of the source
+		start := sm.starts[source]
+		srcLine := sort.SearchInts(lines, m.offset+1) - 1
+		lineStart := lines[srcLine]
+		if lineStart < start {
+			lineStart = start
+		}
+		srcCol := utf16Len(sm.src[lineStart:m.offset])
+		srcLine -= sort.SearchInts(lines, start+1) - 1
+
+		if line < m.line {
+			for ; line < m.line; line++ {
+				b = append(b, ';')
+			}
+			col = 0
+		} else if 0 < len(b) && b[len(b)-1] != ';' {
+			b = append(b, ',')
+		}
+		b = appendVLQ(b, m.col-col)
+		b = appendVLQ(b, source-prevSource)
+		b = appendVLQ(b, srcLine-prevLine)
+		b = appendVLQ(b, srcCol-prevCol)
+		col = m.col
+		prevSource, prevLine, prevCol = source, srcLine, srcCol
+	}
+	return b
+}
+
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+func appendVLQ(b []byte, n int) []byte {
+	v := n << 1
+	if n < 0 {
+		v = (-n << 1) | 1
+	}
+	for {
+		digit := v & 0x1F
+		v >>= 5
+		if v > 0 {
+			digit |= 0x20
+		}
+		b = append(b, base64Chars[digit])
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+// utf16Len returns the number of UTF-16 code units of UTF-8 encoded text, which is how columns are counted in source maps.
+func utf16Len(b []byte) int {
+	n := 0
+	for _, c := range b {
+		if c&0xC0 != 0x80 {
+			n++
+			if 0xF0 <= c {
+				n++ // surrogate pair
+			}
+		}
+	}
+	return n
+}
+
+////////////////////////////////////////////////////////////////
+
+// sourceMapWriter keeps track of the position in the output and records mappings for the source map.
+type sourceMapWriter struct {
+	io.Writer
+	sm        *SourceMap
+	line, col int
+}
+
+func (w *sourceMapWriter) Write(b []byte) (int, error) {
+	for _, c := range b {
+		if c == '\n' {
+			w.line++
+			w.col = 0
+		} else if c&0xC0 != 0x80 {
+			w.col++
+			if 0xF0 <= c {
+				w.col++
+			}
+		}
+	}
+	return w.Writer.Write(b)
+}
+
+// Map records a mapping from the current output position to the position of data in the input.
+func (w *sourceMapWriter) Map(data []byte) {
+	src := w.sm.src
+	if len(data) == 0 || len(src) == 0 {
+		return
+	}
+	offset := int(reflect.ValueOf(data).Pointer() - reflect.ValueOf(src).Pointer())
+	if offset < 0 || len(src) <= offset || &src[offset] != &data[0] {
+		return
+	}
+	if n := len(w.sm.mappings); 0 < n {
+		if prev := w.sm.mappings[n-1]; prev.line == w.line && prev.col == w.col {
+			w.sm.mappings[n-1].offset = offset
+			return
+		}
+	}
+	w.sm.mappings = append(w.sm.mappings, mapping{w.line, w.col, offset})
+}
+
+// MinifyWithSourceMap minifies the content of a Reader and writes it to a Writer, while recording the source map (safe for concurrent use when sm is not shared).
+// Sources must be added to the source map before or after minification, otherwise no mappings are written.
+// If url is not empty, a comment pointing to the source map is appended to the output.
+// Only minifiers that support source maps record mappings, which are CSS and JS.
+func (m *M) MinifyWithSourceMap(mediatype string, w io.Writer, r io.Reader, sm *SourceMap, url string) error {
+	mimetype, params := parse.Mediatype([]byte(mediatype))
+	return m.MinifyMimetypeWithSourceMap(mimetype, w, r, params, sm, url)
+}
+
+// MinifyMimetypeWithSourceMap minifies the content of a Reader and writes it to a Writer, while recording the source map (safe for concurrent use when sm is not shared).
+// It is a lower level version of MinifyWithSourceMap and requires the mediatype to be split up into mimetype and parameters.
+// Calling it again with the same source map appends to it, which is useful when files are minified one at a time into the same output,
+// in which case the offsets of the sources are positions in the concatenation of the inputs.
+func (m *M) MinifyMimetypeWithSourceMap(mimetype []byte, w io.Writer, r io.Reader, params map[string]string, sm *SourceMap, url string) error {
+	start := len(sm.src)
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	// reserve space for the NULL byte appended by the lexers so that they don't copy the buffer
+	sm.src = append(append(sm.src, src...), 0)[:start+len(src)]
+
+	mw := &sourceMapWriter{Writer: w, sm: sm, line: sm.line, col: sm.col}
+	err = m.MinifyMimetype(mimetype, mw, buffer.NewReader(sm.src[start:]), params)
+	sm.line, sm.col = mw.line, mw.col
+	if err != nil {
+		return err
+	}
+	if url != "" {
+		comment := "\n//# sourceMappingURL=" + url
+		if string(mimetype) == "text/css" {
+			comment = "\n/*# sourceMappingURL=" + url + " */"
+		}
+		if _, err := io.WriteString(w, comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package svg // import "github.com/tdewolff/minify/svg"
+
+import (
+	"bytes"
+
+	"github.com/tdewolff/minify/v2"
+)
+
+// renameAttrVal renames the class names and ids of an attribute value, including the ids of same-document references
+// such as href="#icon" and fill="url(#gradient)".
+func renameAttrVal(names *minify.Names, name, val []byte) []byte {
+	if bytes.Equal(name, []byte("class")) {
+		b := []byte{}
+		for _, class := range bytes.Fields(val) {
+			if 0 < len(b) {
+				b = append(b, ' ')
+			}
+			b = append(b, names.Class(class)...)
+		}
+		return b
+	} else if bytes.Equal(name, []byte("id")) {
+		return names.ID(val)
+	} else if bytes.Equal(name, []byte("href")) || bytes.Equal(name, []byte("xlink:href")) {
+		if isFragment(val) {
+			return append([]byte{'#'}, names.ID(val[1:])...)
+		}
+	} else if 7 < len(val) && bytes.Equal(val[:5], []byte("url(#")) && val[len(val)-1] == ')' && isFragment(val[4:len(val)-1]) {
+		b := append([]byte("url(#"), names.ID(val[5:len(val)-1])...)
+		return append(b, ')')
+	}
+	return val
+}
+
+// isFragment returns true for same-document references such as #icon.
+func isFragment(val []byte) bool {
+	if len(val) < 2 || val[0] != '#' {
+		return false
+	}
+	for _, c := range val[1:] {
+		if !(c == '-' || c == '_' || 'a' <= c|0x20 && c|0x20 <= 'z' || '0' <= c && c <= '9' || 0x80 <= c) {
+			return false
+		}
+	}
+	return true
+}
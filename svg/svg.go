@@ -34,6 +34,7 @@ var DefaultMinifier = &Minifier{Decimals: -1}
 // Minifier is an SVG minifier.
 type Minifier struct {
 	Decimals int
+	Comments *minify.Comments // nil removes all comments
 }
 
 // Minify minifies SVG data, it reads from r and writes to w.
@@ -103,6 +104,12 @@ func (o *Minifier) Minify(m *minify.M, w io.Writer, r io.Reader, _ map[string]st
 			} else if _, err := w.Write(t.Data); err != nil {
 				return err
 			}
+		case xml.CommentToken:
+			if o.Comments != nil && o.Comments.Keep(t.Data) {
+				if _, err := w.Write(t.Data); err != nil {
+					return err
+				}
+			}
 		case xml.StartTagPIToken:
 			for {
 				if t := *tb.Shift(); t.TokenType == xml.StartTagClosePIToken || t.TokenType == xml.ErrorToken {
@@ -209,6 +216,10 @@ func (o *Minifier) Minify(m *minify.M, w io.Writer, r io.Reader, _ map[string]st
 				}
 			}
 
+			if m.Names != nil {
+				val = renameAttrVal(m.Names, t.Text, val)
+			}
+
 			// prefer single or double quotes depending on what occurs more often in value
 			val = xml.EscapeAttrVal(&attrByteBuffer, val)
 			if _, err := w.Write(val); err != nil {
@@ -125,6 +125,52 @@ func TestSVGDecimals(t *testing.T) {
 	}
 }
 
+func TestSVGComments(t *testing.T) {
+	var svgTests = []struct {
+		policy   string
+		svg      string
+		expected string
+	}{
+		{"none", `<svg><!-- @license MIT --><path/></svg>`, `<svg><path/></svg>`},
+		{"license", `<svg><!-- @license MIT --><!-- x --><path/></svg>`, `<svg><!-- @license MIT --><path/></svg>`},
+	}
+
+	m := minify.New()
+	for _, tt := range svgTests {
+		t.Run(tt.svg, func(t *testing.T) {
+			comments, err := minify.NewComments(tt.policy)
+			test.Error(t, err)
+			o := &Minifier{Decimals: -1, Comments: comments}
+			r := bytes.NewBufferString(tt.svg)
+			w := &bytes.Buffer{}
+			err = o.Minify(m, w, r, nil)
+			test.Minify(t, tt.svg, err, w.String(), tt.expected)
+		})
+	}
+}
+
+func TestSVGNames(t *testing.T) {
+	var svgTests = []struct {
+		svg      string
+		expected string
+	}{
+		{`<svg><g class="icon  big" id="main"/></svg>`, `<svg><g class="a b" id="a"/></svg>`},
+		{`<svg><use href="#main"/><use xlink:href="#main"/><use href="icons.svg#main"/></svg>`, `<svg><use href="#a"/><use xlink:href="#a"/><use href="icons.svg#main"/></svg>`},
+		{`<svg><path fill="url(#gradient)" d="M0 0"/></svg>`, `<svg><path fill="url(#a)" d="M0 0"/></svg>`},
+	}
+
+	for _, tt := range svgTests {
+		t.Run(tt.svg, func(t *testing.T) {
+			m := minify.New()
+			m.Names = minify.NewNames()
+			r := bytes.NewBufferString(tt.svg)
+			w := &bytes.Buffer{}
+			err := Minify(m, w, r, nil)
+			test.Minify(t, tt.svg, err, w.String(), tt.expected)
+		})
+	}
+}
+
 func TestReaderErrors(t *testing.T) {
 	r := test.NewErrorReader(0)
 	w := &bytes.Buffer{}